@@ -1,12 +1,36 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/eventbus"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/eventstore"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/memory"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/postgres"
+	"github.com/darkonikolic/try_golang/internal/interfaces/http/handler"
 )
 
+// shutdownTimeout bounds how long the server waits for in-flight requests and
+// queued events when it is stopped
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
@@ -14,6 +38,122 @@ func main() {
 		port = "8080"
 	}
 
+	// Use PostgreSQL when configured, otherwise fall back to in-memory storage.
+	// USER_STORE=events keeps users as event streams instead of rows.
+	var (
+		userRepo      repository.UserRepository
+		outbox        repository.Outbox
+		auditRepo     audit.Repository
+		eventStore    eventstore.Store
+		refreshTokens auth.RefreshTokenStore
+		sessions      auth.SessionStore
+		deadLetters   eventbus.DeadLetterStore
+	)
+	if dsn := postgres.DSNFromEnv(); dsn != "" {
+		db, err := postgres.Open(dsn)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		if err := postgres.Migrate(context.Background(), db); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+
+		postgresRepo := postgres.NewUserRepository(db)
+		userRepo, outbox = postgresRepo, postgresRepo
+		auditRepo = postgres.NewAuditRepository(db)
+		eventStore = postgres.NewEventStore(db)
+		refreshTokens = postgres.NewRefreshTokenStore(db)
+		sessions = postgres.NewSessionStore(db)
+		deadLetters = postgres.NewDeadLetterStore(db)
+		log.Printf("Using PostgreSQL user repository")
+	} else {
+		memoryRepo := memory.NewUserRepository()
+		userRepo, outbox = memoryRepo, memoryRepo
+		auditRepo = memory.NewAuditRepository()
+		eventStore = eventstore.NewMemoryStore()
+		refreshTokens = memory.NewRefreshTokenStore()
+		sessions = memory.NewSessionStore()
+		deadLetters = eventbus.NewMemoryDeadLetterStore()
+		log.Printf("DATABASE_URL not set, using in-memory user repository")
+	}
+
+	if os.Getenv("USER_STORE") == "events" {
+		eventSourcedRepo, err := eventstore.NewUserRepository(context.Background(), eventStore)
+		if err != nil {
+			log.Fatalf("Failed to load users from the event store: %v", err)
+		}
+		userRepo, outbox = eventSourcedRepo, eventSourcedRepo
+		log.Printf("Using event-sourced user repository")
+	}
+
+	// Deliver user events in-process; subscribers register on the bus
+	bus := eventbus.New(eventbus.WithDeadLetterStore(deadLetters))
+
+	eventbus.Subscribe(bus, "log", func(ctx context.Context, event entity.Event) error {
+		log.Printf("User event %s for user %s", event.EventName(), event.AggregateID())
+		return nil
+	}, eventbus.WithMode(eventbus.Async))
+
+	// Wire dependencies
+	auditLog := audit.NewLog(auditRepo)
+	serviceOpts := []service.Option{
+		service.WithAuditLog(auditLog),
+		service.WithSessions(sessions),
+		service.WithAuthorizer(authorizerFromEnv()),
+	}
+	if raw := os.Getenv("USER_DELETION_GRACE_PERIOD"); raw != "" {
+		gracePeriod, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("Invalid USER_DELETION_GRACE_PERIOD: %v", err)
+		}
+		serviceOpts = append(serviceOpts, service.WithDeletionGracePeriod(gracePeriod))
+	}
+
+	userService := service.NewUserService(userRepo, serviceOpts...)
+	if email := os.Getenv("ADMIN_EMAIL"); email != "" {
+		if err := bootstrapAdmin(context.Background(), userService, email); err != nil {
+			log.Printf("Failed to make %s an admin: %v", email, err)
+		}
+	}
+	userHandler := handler.NewUserHandler(userService)
+	auditHandler := handler.NewAuditHandler(userService)
+	authzHandler := handler.NewAuthzHandler(userService)
+
+	keyring, err := keyringFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid JWT configuration: %v", err)
+	}
+	authService := auth.NewAuthService(userService, keyring, refreshTokens, auth.WithIssuer(os.Getenv("JWT_ISSUER")))
+	authHandler := handler.NewAuthHandler(authService)
+
+	var sessionOpts []auth.SessionOption
+	for env, option := range map[string]func(time.Duration) auth.SessionOption{
+		"SESSION_IDLE_TIMEOUT":     auth.WithIdleTimeout,
+		"SESSION_ABSOLUTE_TIMEOUT": auth.WithAbsoluteTimeout,
+	} {
+		if raw := os.Getenv(env); raw != "" {
+			timeout, err := time.ParseDuration(raw)
+			if err != nil {
+				log.Fatalf("Invalid %s: %v", env, err)
+			}
+			sessionOpts = append(sessionOpts, option(timeout))
+		}
+	}
+	sessionService := auth.NewSessionService(userService, sessions, sessionOpts...)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+
+	// Background jobs run until the server is stopped with SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Permanently remove soft-deleted users once their grace period has passed
+	go service.NewPurger(userService, service.DefaultPurgeInterval).Run(ctx)
+
+	// Publish the events stored in the outbox to the bus
+	go service.NewOutboxRelay(outbox, bus, service.DefaultRelayInterval).Run(ctx)
+
 	// Create HTTP server
 	mux := http.NewServeMux()
 
@@ -35,15 +175,142 @@ func main() {
 	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `{"message":"API v1 is working","endpoints":["GET /health","GET /","GET /api/v1/"]}`)
+		fmt.Fprintf(w, `{"message":"API v1 is working"}`)
 	})
 
+	// User endpoints
+	userHandler.RegisterRoutes(mux)
+
+	// Audit endpoints
+	auditHandler.RegisterRoutes(mux)
+
+	// Authentication endpoints
+	authHandler.RegisterRoutes(mux)
+
+	// Session endpoints
+	sessionHandler.RegisterRoutes(mux)
+
+	// Authorization endpoints
+	authzHandler.RegisterRoutes(mux)
+
 	// Start server
 	log.Printf("Starting server on port %s", port)
 	log.Printf("Health check: http://localhost:%s/health", port)
 	log.Printf("API docs: http://localhost:%s/api/v1/", port)
 
-	if err := http.ListenAndServe(fmt.Sprintf(":%s", port), mux); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Identify callers that present an access token or a session cookie; the
+	// token wins when a request carries both
+	server := handler.Authenticate(authService)(handler.AuthenticateSession(sessionService)(mux))
+
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%s", port), Handler: server}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Once stopped, finish in-flight requests and then deliver the events
+	// already queued for async subscribers
+	<-ctx.Done()
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to finish in-flight requests: %v", err)
+	}
+	if err := bus.Close(shutdownCtx); err != nil {
+		log.Printf("Failed to deliver queued events: %v", err)
+	}
+}
+
+// authorizerFromEnv returns the authorizer for the policy in the file named by
+// POLICY_FILE, or grants by role when it is unset
+func authorizerFromEnv() authz.Authorizer {
+	path := os.Getenv("POLICY_FILE")
+	if path == "" {
+		return authz.NewRoleAuthorizer(authz.DefaultGrants)
+	}
+
+	policy, err := authz.LoadPolicy(path)
+	if err != nil {
+		log.Fatalf("Failed to load POLICY_FILE: %v", err)
+	}
+	log.Printf("Authorizing with %d policy rules from %s", len(policy.Rules), path)
+	return authz.NewPolicyAuthorizer(policy)
+}
+
+// bootstrapAdmin gives the admin role to the user registered with email, so
+// that a new deployment has someone who can assign roles to others
+func bootstrapAdmin(ctx context.Context, users *service.UserService, email string) error {
+	ctx = service.ContextWithSystem(ctx)
+
+	user, err := users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user.HasRole(entity.RoleAdmin) {
+		return nil
+	}
+	return users.AssignRoles(ctx, user.ID, append(user.Roles, entity.RoleAdmin))
+}
+
+// keyringFromEnv builds the keys that sign access tokens from
+// JWT_SIGNING_KEY, JWT_KEY_ID and JWT_ALGORITHM. JWT_PREVIOUS_SIGNING_KEY and
+// JWT_PREVIOUS_KEY_ID name a retired key whose tokens are still accepted.
+// Without JWT_SIGNING_KEY a random key is generated, so tokens don't survive
+// a restart.
+func keyringFromEnv() (*auth.Keyring, error) {
+	algorithm := os.Getenv("JWT_ALGORITHM")
+
+	active, ok, err := signingKeyFromEnv(algorithm, "JWT_SIGNING_KEY", "JWT_KEY_ID")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		secret := make([]byte, auth.MinHS256SecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		if active, err = auth.NewHS256Key("ephemeral", secret); err != nil {
+			return nil, err
+		}
+		log.Printf("JWT_SIGNING_KEY not set, signing tokens with a random key")
+	}
+
+	previous, ok, err := signingKeyFromEnv(algorithm, "JWT_PREVIOUS_SIGNING_KEY", "JWT_PREVIOUS_KEY_ID")
+	if err != nil || !ok {
+		return auth.NewKeyring(active), err
+	}
+	return auth.NewKeyring(active, previous), nil
+}
+
+// signingKeyFromEnv reads a base64 encoded key and its ID. For HS256 the key
+// is the secret; for EdDSA it is the 32 byte Ed25519 seed.
+func signingKeyFromEnv(algorithm, keyVar, idVar string) (auth.SigningKey, bool, error) {
+	encoded := os.Getenv(keyVar)
+	if encoded == "" {
+		return auth.SigningKey{}, false, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return auth.SigningKey{}, false, fmt.Errorf("%s is not valid base64: %w", keyVar, err)
+	}
+	id := os.Getenv(idVar)
+	if id == "" {
+		id = "default"
+	}
+
+	switch algorithm {
+	case "", auth.AlgorithmHS256:
+		signingKey, err := auth.NewHS256Key(id, key)
+		return signingKey, err == nil, err
+	case auth.AlgorithmEdDSA:
+		if len(key) != ed25519.SeedSize {
+			return auth.SigningKey{}, false, fmt.Errorf("%s must be a %d byte Ed25519 seed", keyVar, ed25519.SeedSize)
+		}
+		signingKey, err := auth.NewEdDSAKey(id, ed25519.NewKeyFromSeed(key))
+		return signingKey, err == nil, err
+	default:
+		return auth.SigningKey{}, false, fmt.Errorf("unsupported JWT_ALGORITHM %q", algorithm)
 	}
 }
@@ -0,0 +1,75 @@
+// Command audit-verify checks the hash chain of the audit log stored in
+// PostgreSQL and exits with status 1 if it has been tampered with.
+//
+// The chain is a plain SHA-256 chain, not a keyed one: anyone who can write
+// to the database can drop entries from its end, or rewrite entries and
+// recompute every hash after them, and still have it verify. Only an anchor
+// kept outside the database catches that, so the command requires one. It
+// prints the head of the chain; keep it somewhere the database's users can't
+// change and pass it back with -anchor on the next run to check that the log
+// has only grown since. The very first run, which has no anchor yet, must
+// say so with -init.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/postgres"
+)
+
+func main() {
+	anchorFlag := flag.String("anchor", "", "head printed by an earlier run, as <sequence>:<hash>")
+	initFlag := flag.Bool("init", false, "verify without an anchor, to print the first one")
+	flag.Parse()
+
+	var anchor audit.Head
+	switch {
+	case *anchorFlag != "" && *initFlag:
+		log.Fatalf("-anchor and -init can't be used together")
+	case *anchorFlag != "":
+		var err error
+		if anchor, err = audit.ParseHead(*anchorFlag); err != nil {
+			log.Fatalf("Invalid -anchor: %v", err)
+		}
+	case !*initFlag:
+		log.Fatalf("-anchor is required: without it a rewritten log still verifies; pass -init on the first run")
+	}
+
+	dsn := postgres.DSNFromEnv()
+	if dsn == "" {
+		log.Fatalf("DATABASE_URL not set")
+	}
+
+	db, err := postgres.Open(dsn)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	auditLog := audit.NewLog(postgres.NewAuditRepository(db))
+
+	head, err := auditLog.Verify(ctx)
+	if errors.Is(err, audit.ErrChainBroken) {
+		log.Fatalf("Audit log tampered with after %d valid entries: %v", head.Sequence, err)
+	}
+	if err != nil {
+		log.Fatalf("Failed to verify audit log: %v", err)
+	}
+
+	if anchor != (audit.Head{}) {
+		err := auditLog.CheckAnchor(ctx, anchor)
+		if errors.Is(err, audit.ErrChainBroken) {
+			log.Fatalf("Audit log tampered with since head %s: %v", anchor, err)
+		}
+		if err != nil {
+			log.Fatalf("Failed to check audit log anchor: %v", err)
+		}
+	}
+
+	log.Printf("Audit log intact: %d entries verified, head %s", head.Sequence, head)
+}
@@ -1,3 +1,10 @@
 module github.com/darkonikolic/try_golang
 
 go 1.24
+
+require (
+	github.com/lib/pq v1.10.9
+	golang.org/x/net v0.40.0
+)
+
+require golang.org/x/text v0.25.0 // indirect
@@ -0,0 +1,72 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+
+	if !fake.Now().Equal(start) {
+		t.Errorf("Now() = %v, want %v", fake.Now(), start)
+	}
+
+	fake.Advance(90 * time.Second)
+	if want := start.Add(90 * time.Second); !fake.Now().Equal(want) {
+		t.Errorf("Now() after Advance = %v, want %v", fake.Now(), want)
+	}
+
+	later := start.Add(24 * time.Hour)
+	fake.Set(later)
+	if !fake.Now().Equal(later) {
+		t.Errorf("Now() after Set = %v, want %v", fake.Now(), later)
+	}
+}
+
+func TestSystem(t *testing.T) {
+	before := time.Now()
+	now := System.Now()
+	after := time.Now()
+
+	if now.Before(before) || now.After(after) {
+		t.Errorf("System.Now() = %v, want between %v and %v", now, before, after)
+	}
+}
+
+func TestFake_After(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+
+	fired := fake.After(time.Minute)
+	if fake.Waiters() != 1 {
+		t.Fatalf("Waiters() = %d, want 1", fake.Waiters())
+	}
+
+	fake.Advance(30 * time.Second)
+	select {
+	case <-fired:
+		t.Fatal("After() fired before its time")
+	default:
+	}
+
+	fake.Advance(30 * time.Second)
+	select {
+	case at := <-fired:
+		if !at.Equal(start.Add(time.Minute)) {
+			t.Errorf("After() sent %v, want %v", at, start.Add(time.Minute))
+		}
+	default:
+		t.Fatal("After() did not fire once its time had come")
+	}
+	if fake.Waiters() != 0 {
+		t.Errorf("Waiters() = %d after firing, want 0", fake.Waiters())
+	}
+
+	select {
+	case <-fake.After(0):
+	default:
+		t.Error("After(0) did not fire immediately")
+	}
+}
@@ -0,0 +1,132 @@
+// Package audit keeps an append-only, hash-chained record of changes to users.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Action names the kind of change an entry records
+type Action string
+
+// Audited actions
+const (
+	ActionCreate         Action = "create"
+	ActionUpdate         Action = "update"
+	ActionChangePassword Action = "change_password"
+	ActionRehashPassword Action = "rehash_password"
+	ActionActivate       Action = "activate"
+	ActionSuspend        Action = "suspend"
+	ActionReactivate     Action = "reactivate"
+	ActionDeactivate     Action = "deactivate"
+	ActionDelete         Action = "delete"
+	ActionRestore        Action = "restore"
+	ActionPurge          Action = "purge"
+	ActionAssignRoles    Action = "assign_roles"
+	ActionAssignTenant   Action = "assign_tenant"
+)
+
+// ErrChainBroken is matched by every *ChainError
+var ErrChainBroken = errors.New("audit chain broken")
+
+// FieldChange is one field's value before and after a change
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Entry records who changed which user, how and when. Each entry carries
+// the hash of the one before it, so altering, removing or reordering stored
+// entries breaks the chain. The hashes are not keyed, so whoever can rewrite
+// entries can recompute the hashes after them too; only a Head kept
+// elsewhere and checked with Log.CheckAnchor catches that.
+type Entry struct {
+	// Sequence numbers entries from 1 without gaps
+	Sequence int64
+	At       time.Time
+	Actor    string
+	Action   Action
+	// UserID is the changed user; empty for actions on many users, like purges
+	UserID  entity.UserID
+	Changes []FieldChange
+	// PrevHash is the Hash of the previous entry, empty for the first one
+	PrevHash string
+	Hash     string
+}
+
+// Seal places e after prev, or first in the chain if prev is nil, and
+// computes its hash. At is truncated to microseconds, the precision it is
+// stored with.
+func (e *Entry) Seal(prev *Entry) {
+	e.Sequence, e.PrevHash = 1, ""
+	if prev != nil {
+		e.Sequence, e.PrevHash = prev.Sequence+1, prev.Hash
+	}
+
+	e.At = e.At.UTC().Truncate(time.Microsecond)
+	e.Hash = e.ComputeHash()
+}
+
+// ComputeHash returns the SHA-256 of everything in the entry except Hash
+func (e Entry) ComputeHash() string {
+	changes := e.Changes
+	if len(changes) == 0 {
+		changes = nil
+	}
+
+	// A struct, not a map, so fields are always encoded in the same order
+	data, _ := json.Marshal(struct {
+		Sequence int64         `json:"sequence"`
+		At       string        `json:"at"`
+		Actor    string        `json:"actor"`
+		Action   Action        `json:"action"`
+		UserID   entity.UserID `json:"user_id"`
+		Changes  []FieldChange `json:"changes"`
+		PrevHash string        `json:"prev_hash"`
+	}{e.Sequence, e.At.UTC().Format(time.RFC3339Nano), e.Actor, e.Action, e.UserID, changes, e.PrevHash})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAfter checks that e is intact and directly follows prev, or starts
+// the chain if prev is nil
+func (e Entry) VerifyAfter(prev *Entry) error {
+	wantSequence, wantPrevHash := int64(1), ""
+	if prev != nil {
+		wantSequence, wantPrevHash = prev.Sequence+1, prev.Hash
+	}
+
+	switch {
+	case e.Sequence != wantSequence:
+		return &ChainError{Sequence: wantSequence, Reason: fmt.Sprintf("entry missing, found %d instead", e.Sequence)}
+	case e.PrevHash != wantPrevHash:
+		return &ChainError{Sequence: e.Sequence, Reason: "previous hash does not match"}
+	case e.Hash != e.ComputeHash():
+		return &ChainError{Sequence: e.Sequence, Reason: "contents do not match hash"}
+	}
+	return nil
+}
+
+// ChainError reports the first entry at which the chain is broken.
+// It matches ErrChainBroken with errors.Is.
+type ChainError struct {
+	Sequence int64
+	Reason   string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%s at entry %d: %s", ErrChainBroken, e.Sequence, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrChainBroken) report true
+func (e *ChainError) Is(target error) bool {
+	return target == ErrChainBroken
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// sealedChain returns n entries sealed one after the other
+func sealedChain(n int) []Entry {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 123456789, time.UTC)
+
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i] = Entry{
+			At:      at.Add(time.Duration(i) * time.Minute),
+			Actor:   "admin",
+			Action:  ActionUpdate,
+			UserID:  "user_1",
+			Changes: []FieldChange{{Field: "name", From: "Old", To: "New"}},
+		}
+		if i == 0 {
+			entries[i].Seal(nil)
+		} else {
+			entries[i].Seal(&entries[i-1])
+		}
+	}
+	return entries
+}
+
+// verifyChain checks entries in order and returns the first error
+func verifyChain(entries []Entry) error {
+	var prev *Entry
+	for i := range entries {
+		if err := entries[i].VerifyAfter(prev); err != nil {
+			return err
+		}
+		prev = &entries[i]
+	}
+	return nil
+}
+
+func TestEntry_Seal(t *testing.T) {
+	entries := sealedChain(3)
+
+	if entries[0].Sequence != 1 || entries[0].PrevHash != "" {
+		t.Errorf("first entry = %d after %q", entries[0].Sequence, entries[0].PrevHash)
+	}
+	if entries[2].Sequence != 3 || entries[2].PrevHash != entries[1].Hash {
+		t.Errorf("third entry not linked to the second")
+	}
+	if entries[0].At.Nanosecond() != 123456000 {
+		t.Errorf("Seal() did not truncate At to microseconds: %v", entries[0].At)
+	}
+	if err := verifyChain(entries); err != nil {
+		t.Errorf("VerifyAfter() unexpected error: %v", err)
+	}
+}
+
+func TestEntry_VerifyAfterDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name         string
+		tamper       func(entries []Entry) []Entry
+		wantSequence int64
+	}{
+		{"changed field", func(e []Entry) []Entry { e[1].Changes[0].To = "Forged"; return e }, 2},
+		{"changed actor", func(e []Entry) []Entry { e[2].Actor = "someone"; return e }, 3},
+		{"removed entry", func(e []Entry) []Entry { return append(e[:1], e[2:]...) }, 2},
+		{"reordered entries", func(e []Entry) []Entry { e[1], e[2] = e[2], e[1]; return e }, 2},
+		{"rehashed entry", func(e []Entry) []Entry {
+			e[1].Actor = "someone"
+			e[1].Hash = e[1].ComputeHash()
+			return e
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChain(tt.tamper(sealedChain(4)))
+
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) || !errors.Is(err, ErrChainBroken) {
+				t.Fatalf("VerifyAfter() expected ChainError, got: %v", err)
+			}
+			if chainErr.Sequence != tt.wantSequence {
+				t.Errorf("ChainError.Sequence = %d, want %d", chainErr.Sequence, tt.wantSequence)
+			}
+		})
+	}
+}
+
+func TestEntry_ComputeHashIgnoresEmptyChanges(t *testing.T) {
+	withNil := Entry{Action: ActionPurge}
+	withEmpty := Entry{Action: ActionPurge, Changes: []FieldChange{}}
+
+	if withNil.ComputeHash() != withEmpty.ComputeHash() {
+		t.Errorf("ComputeHash() differs for nil and empty changes")
+	}
+}
@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+)
+
+// verifyBatchSize is how many entries Verify reads at a time
+const verifyBatchSize = MaxQueryLimit
+
+// Log records and reads the audit trail
+type Log struct {
+	repo  Repository
+	clock clock.Clock
+}
+
+// Option configures a Log
+type Option func(*Log)
+
+// WithClock sets the clock used to timestamp entries recorded without a time
+func WithClock(c clock.Clock) Option {
+	return func(l *Log) {
+		l.clock = c
+	}
+}
+
+// NewLog creates a Log backed by repo
+func NewLog(repo Repository, opts ...Option) *Log {
+	l := &Log{
+		repo:  repo,
+		clock: clock.System,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Record appends entry to the audit trail. A zero At is set to now.
+func (l *Log) Record(ctx context.Context, entry Entry) error {
+	if entry.At.IsZero() {
+		entry.At = l.clock.Now()
+	}
+
+	if err := l.repo.Append(ctx, &entry); err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	return nil
+}
+
+// Query returns the entries matching query in sequence order
+func (l *Log) Query(ctx context.Context, query Query) ([]Entry, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := l.repo.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// Head identifies the last entry of a chain. Since entries are numbered from
+// 1 without gaps, Sequence is also the number of entries.
+type Head struct {
+	Sequence int64
+	Hash     string
+}
+
+// String formats the head as "<sequence>:<hash>", the form ParseHead reads
+func (h Head) String() string {
+	return fmt.Sprintf("%d:%s", h.Sequence, h.Hash)
+}
+
+// ParseHead reads a head formatted by Head.String
+func ParseHead(s string) (Head, error) {
+	sequence, hash, ok := strings.Cut(s, ":")
+	n, err := strconv.ParseInt(sequence, 10, 64)
+	if !ok || err != nil || n < 1 || hash == "" {
+		return Head{}, fmt.Errorf("invalid head %q, want <sequence>:<hash>", s)
+	}
+	return Head{Sequence: n, Hash: hash}, nil
+}
+
+// Verify walks the whole chain and returns its head. If the chain is broken
+// the error is a *ChainError naming the first bad entry, and the head is the
+// last good one.
+//
+// The chain only vouches for the entries before its head: dropping entries
+// from the end leaves a shorter chain that verifies. Keep the head somewhere
+// the log's writers can't change and pass it to CheckAnchor later to catch that.
+func (l *Log) Verify(ctx context.Context) (Head, error) {
+	var (
+		prev *Entry
+		head Head
+	)
+
+	for {
+		entries, err := l.repo.Query(ctx, Query{AfterSequence: head.Sequence, Limit: verifyBatchSize})
+		if err != nil {
+			return head, fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		for i := range entries {
+			if err := entries[i].VerifyAfter(prev); err != nil {
+				return head, err
+			}
+			prev = &entries[i]
+			head = Head{Sequence: prev.Sequence, Hash: prev.Hash}
+		}
+
+		if len(entries) < verifyBatchSize {
+			return head, nil
+		}
+	}
+}
+
+// CheckAnchor checks that the log still holds the entry anchor names, a
+// head returned by an earlier Verify. Together with a Verify of the current
+// chain this shows that the log has only grown since. A missing or changed
+// entry is reported as a *ChainError.
+func (l *Log) CheckAnchor(ctx context.Context, anchor Head) error {
+	entries, err := l.repo.Query(ctx, Query{AfterSequence: anchor.Sequence - 1, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	switch {
+	case len(entries) == 0 || entries[0].Sequence != anchor.Sequence:
+		return &ChainError{Sequence: anchor.Sequence, Reason: "anchored entry missing, the log has been truncated"}
+	case entries[0].Hash != anchor.Hash:
+		return &ChainError{Sequence: anchor.Sequence, Reason: "hash does not match the anchor"}
+	}
+	return nil
+}
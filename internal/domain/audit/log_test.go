@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// MockRepository keeps entries in memory for testing
+type MockRepository struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (m *MockRepository) Append(ctx context.Context, entry *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var prev *Entry
+	if len(m.entries) > 0 {
+		prev = &m.entries[len(m.entries)-1]
+	}
+	entry.Seal(prev)
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+
+func (m *MockRepository) Query(ctx context.Context, query Query) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []Entry
+	for _, entry := range m.entries {
+		if len(entries) < query.Limit && query.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func TestLog_RecordAndQuery(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFake(start)
+	log := NewLog(&MockRepository{}, WithClock(fakeClock))
+
+	for i, userID := range []string{"user_1", "user_2", "user_1"} {
+		fakeClock.Set(start.Add(time.Duration(i) * time.Hour))
+		if err := log.Record(ctx, Entry{Actor: "admin", Action: ActionUpdate, UserID: entity.UserID(userID)}); err != nil {
+			t.Fatalf("Record() unexpected error: %v", err)
+		}
+	}
+
+	byUser, err := log.Query(ctx, Query{UserID: "user_1"})
+	if err != nil {
+		t.Fatalf("Query() unexpected error: %v", err)
+	}
+	if len(byUser) != 2 || byUser[0].Sequence != 1 || byUser[1].Sequence != 3 {
+		t.Errorf("Query(user_1) returned %d entries", len(byUser))
+	}
+
+	inRange, _ := log.Query(ctx, Query{From: start.Add(time.Hour), To: start.Add(2 * time.Hour)})
+	if len(inRange) != 1 || inRange[0].Sequence != 2 {
+		t.Errorf("Query(time range) returned %d entries", len(inRange))
+	}
+
+	if _, err := log.Query(ctx, Query{From: start, To: start}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Query() with empty range expected ErrInvalidQuery, got: %v", err)
+	}
+}
+
+func TestLog_Verify(t *testing.T) {
+	ctx := context.Background()
+	repo := &MockRepository{}
+	log := NewLog(repo)
+
+	if head, err := log.Verify(ctx); err != nil || head != (Head{}) {
+		t.Fatalf("Verify() of an empty log = %v, %v, want the zero head", head, err)
+	}
+
+	for i := 0; i < 5; i++ {
+		_ = log.Record(ctx, Entry{Actor: "admin", Action: ActionUpdate, UserID: "user_1"})
+	}
+
+	head, err := log.Verify(ctx)
+	if err != nil || head.Sequence != 5 || head.Hash != repo.entries[4].Hash {
+		t.Fatalf("Verify() = %v, %v, want the fifth entry and no error", head, err)
+	}
+
+	repo.entries[3].Actor = "someone"
+
+	head, err = log.Verify(ctx)
+	var chainErr *ChainError
+	if !errors.As(err, &chainErr) || chainErr.Sequence != 4 || head.Sequence != 3 {
+		t.Errorf("Verify() after tampering = %v, %v", head, err)
+	}
+}
+
+func TestLog_CheckAnchor(t *testing.T) {
+	ctx := context.Background()
+	repo := &MockRepository{}
+	log := NewLog(repo)
+
+	for i := 0; i < 3; i++ {
+		_ = log.Record(ctx, Entry{Actor: "admin", Action: ActionUpdate, UserID: "user_1"})
+	}
+	anchor, _ := log.Verify(ctx)
+	_ = log.Record(ctx, Entry{Actor: "admin", Action: ActionUpdate, UserID: "user_2"})
+
+	if err := log.CheckAnchor(ctx, anchor); err != nil {
+		t.Errorf("CheckAnchor() after the log grew unexpected error: %v", err)
+	}
+
+	// Dropping entries from the end leaves a chain that verifies, but not the anchor
+	repo.entries = repo.entries[:2]
+	if _, err := log.Verify(ctx); err != nil {
+		t.Fatalf("Verify() of a truncated log unexpected error: %v", err)
+	}
+	var chainErr *ChainError
+	if err := log.CheckAnchor(ctx, anchor); !errors.As(err, &chainErr) || chainErr.Sequence != 3 {
+		t.Errorf("CheckAnchor() after truncation expected ChainError at 3, got: %v", err)
+	}
+
+	if err := log.CheckAnchor(ctx, Head{Sequence: 2, Hash: "other"}); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("CheckAnchor() with another hash expected ErrChainBroken, got: %v", err)
+	}
+}
+
+func TestParseHead(t *testing.T) {
+	head := Head{Sequence: 42, Hash: "abc123"}
+	if parsed, err := ParseHead(head.String()); err != nil || parsed != head {
+		t.Errorf("ParseHead(%q) = %v, %v, want %v", head.String(), parsed, err, head)
+	}
+
+	for _, s := range []string{"", "42", "abc:123", "0:abc", "42:"} {
+		if _, err := ParseHead(s); err == nil {
+			t.Errorf("ParseHead(%q) expected an error", s)
+		}
+	}
+}
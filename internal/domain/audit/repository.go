@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Query limits
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// ErrInvalidQuery is returned for queries that can't be run
+var ErrInvalidQuery = domainerr.New(domainerr.KindInvalid, "invalid_audit_query", "invalid audit query")
+
+// Repository stores audit entries. It has no way to change or remove them.
+type Repository interface {
+	// Append seals entry after the last stored entry and stores it. Appends
+	// are serialized, so concurrent appends never fork the chain.
+	Append(ctx context.Context, entry *Entry) error
+
+	// Query returns the entries matching the query in sequence order.
+	// The query must be normalized with Query.Normalize.
+	Query(ctx context.Context, query Query) ([]Entry, error)
+}
+
+// Query selects audit entries. Zero fields don't filter.
+type Query struct {
+	UserID entity.UserID
+	// From and To bound At; From is inclusive, To exclusive
+	From time.Time
+	To   time.Time
+	// AfterSequence skips entries up to and including this sequence number,
+	// for paging through results
+	AfterSequence int64
+	Limit         int
+}
+
+// Normalize applies defaults and validates the query
+func (q Query) Normalize() (Query, error) {
+	if q.Limit == 0 {
+		q.Limit = DefaultQueryLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxQueryLimit {
+		return q, ErrInvalidQuery.Withf("limit must be between 1 and %d", MaxQueryLimit)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, ErrInvalidQuery.Withf("from must be before to")
+	}
+	if q.AfterSequence < 0 {
+		return q, ErrInvalidQuery.Withf("after must not be negative")
+	}
+	return q, nil
+}
+
+// Matches reports whether entry is selected by the query's filters
+func (q Query) Matches(entry Entry) bool {
+	switch {
+	case entry.Sequence <= q.AfterSequence:
+		return false
+	case q.UserID != "" && entry.UserID != q.UserID:
+		return false
+	case !q.From.IsZero() && entry.At.Before(q.From):
+		return false
+	case !q.To.IsZero() && !entry.At.Before(q.To):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testSecret is a valid HS256 secret
+var testSecret = []byte(strings.Repeat("s", MinHS256SecretLength))
+
+func TestKeyring_SignAndVerify(t *testing.T) {
+	hs256, err := NewHS256Key("hs", testSecret)
+	if err != nil {
+		t.Fatalf("NewHS256Key() unexpected error: %v", err)
+	}
+	eddsa, err := NewEdDSAKey("ed", ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	if err != nil {
+		t.Fatalf("NewEdDSAKey() unexpected error: %v", err)
+	}
+
+	for _, key := range []SigningKey{hs256, eddsa} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			keyring := NewKeyring(key)
+			claims := Claims{Subject: "user_01HV6X5Q9Z3K8M2N4P7R0T1W2Y", IssuedAt: 100, ExpiresAt: 200, ID: "jti"}
+
+			token, err := keyring.Sign(claims)
+			if err != nil {
+				t.Fatalf("Sign() unexpected error: %v", err)
+			}
+			got, err := keyring.Verify(token)
+			if err != nil || got != claims {
+				t.Errorf("Verify() = %+v, %v, want %+v", got, err, claims)
+			}
+
+			segments := strings.Split(token, ".")
+			forged, _ := NewKeyring(key).Sign(Claims{Subject: "user_01ARZ3NDEKTSV4RRFFQ69G5FAV", ExpiresAt: 200})
+			tampered := segments[0] + "." + strings.Split(forged, ".")[1] + "." + segments[2]
+			if _, err := keyring.Verify(tampered); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify() of tampered claims expected ErrInvalidToken, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyring_RejectsOtherKeysAndAlgorithms(t *testing.T) {
+	key, _ := NewHS256Key("current", testSecret)
+	keyring := NewKeyring(key)
+
+	other, _ := NewHS256Key("other", []byte(strings.Repeat("o", MinHS256SecretLength)))
+	token, _ := NewKeyring(other).Sign(Claims{Subject: "user_01HV6X5Q9Z3K8M2N4P7R0T1W2Y"})
+	if _, err := keyring.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() of a token from an unknown key expected ErrInvalidToken, got: %v", err)
+	}
+
+	// alg "none" with the right kid must not skip the signature check
+	unsigned := encodeSegment([]byte(`{"alg":"none","typ":"JWT","kid":"current"}`)) + "." +
+		encodeSegment([]byte(`{"sub":"user_01HV6X5Q9Z3K8M2N4P7R0T1W2Y"}`)) + "."
+	if _, err := keyring.Verify(unsigned); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() of an unsigned token expected ErrInvalidToken, got: %v", err)
+	}
+
+	if _, err := keyring.Verify("not-a-token"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() of garbage expected ErrInvalidToken, got: %v", err)
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	old, _ := NewHS256Key("2024", testSecret)
+	current, _ := NewEdDSAKey("2025", ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+
+	token, _ := NewKeyring(old).Sign(Claims{Subject: "user_01HV6X5Q9Z3K8M2N4P7R0T1W2Y"})
+
+	if _, err := NewKeyring(current, old).Verify(token); err != nil {
+		t.Errorf("Verify() with the old key kept as previous unexpected error: %v", err)
+	}
+	if _, err := NewKeyring(current).Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() after dropping the old key expected ErrInvalidToken, got: %v", err)
+	}
+}
+
+func TestNewHS256Key_RejectsShortSecret(t *testing.T) {
+	if _, err := NewHS256Key("short", []byte("secret")); err == nil {
+		t.Error("NewHS256Key() expected an error for a short secret")
+	}
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// RefreshToken is the stored record of a refresh token handed to a client.
+// Only a hash of the token is kept, so a leaked table can't be replayed.
+// Each refresh uses up the presented token and issues the next one in the
+// same family; presenting a used token again means it was stolen, and the
+// whole family is revoked.
+type RefreshToken struct {
+	Hash      string
+	UserID    entity.UserID
+	FamilyID  string // shared by every token rotated from the same login
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	UsedAt    time.Time // zero until the token is exchanged
+	RevokedAt time.Time // zero unless the family was revoked
+}
+
+// Usable reports whether the token can still be exchanged at now
+func (t RefreshToken) Usable(now time.Time) bool {
+	return t.UsedAt.IsZero() && t.RevokedAt.IsZero() && now.Before(t.ExpiresAt)
+}
+
+// HashRefreshToken returns the hash a refresh token is stored under
+func HashRefreshToken(token string) string {
+	return hashToken(token)
+}
+
+// RefreshTokenStore stores refresh tokens by hash
+type RefreshTokenStore interface {
+	// Save stores a newly issued token
+	Save(ctx context.Context, token RefreshToken) error
+
+	// Use marks the token as used at the given time, unless it already is,
+	// and returns it as it was before. Concurrent calls for the same token
+	// see it unused at most once. It fails with ErrRefreshTokenNotFound if
+	// there is no such token.
+	Use(ctx context.Context, hash string, at time.Time) (RefreshToken, error)
+
+	// RevokeFamily revokes every token of a family that isn't revoked yet
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+}
@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+)
+
+// Token lifetimes used unless configured otherwise
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// refreshTokenBytes is the amount of randomness in a refresh token
+const refreshTokenBytes = 32
+
+// Users is the part of the user service that authentication relies on.
+// *service.UserService implements it.
+type Users interface {
+	Authenticate(ctx context.Context, email string, password string) (*entity.User, error)
+	GetUserByID(ctx context.Context, id entity.UserID) (*entity.User, error)
+}
+
+// AuthService logs users in with their email and password and issues the
+// tokens that identify them afterwards
+type AuthService struct {
+	users           Users
+	signer          TokenSigner
+	refreshTokens   RefreshTokenStore
+	clock           clock.Clock
+	issuer          string
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+// Option configures an AuthService
+type Option func(*AuthService)
+
+// WithClock sets the clock used to issue and check tokens
+func WithClock(c clock.Clock) Option {
+	return func(s *AuthService) {
+		s.clock = c
+	}
+}
+
+// WithIssuer sets the iss claim of access tokens; tokens from another issuer are rejected
+func WithIssuer(issuer string) Option {
+	return func(s *AuthService) {
+		s.issuer = issuer
+	}
+}
+
+// WithAccessTokenTTL sets how long access tokens are valid
+func WithAccessTokenTTL(ttl time.Duration) Option {
+	return func(s *AuthService) {
+		s.accessTokenTTL = ttl
+	}
+}
+
+// WithRefreshTokenTTL sets how long refresh tokens are valid
+func WithRefreshTokenTTL(ttl time.Duration) Option {
+	return func(s *AuthService) {
+		s.refreshTokenTTL = ttl
+	}
+}
+
+// NewAuthService creates a new AuthService
+func NewAuthService(users Users, signer TokenSigner, refreshTokens RefreshTokenStore, opts ...Option) *AuthService {
+	s := &AuthService{
+		users:           users,
+		signer:          signer,
+		refreshTokens:   refreshTokens,
+		clock:           clock.System,
+		accessTokenTTL:  DefaultAccessTokenTTL,
+		refreshTokenTTL: DefaultRefreshTokenTTL,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Login verifies an email/password pair and issues tokens for the user.
+// Suspended and deactivated users can't log in.
+func (s *AuthService) Login(ctx context.Context, email string, password string) (*TokenPair, error) {
+	user, err := s.users.Authenticate(ctx, email, password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		return nil, ErrLoginFailed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate user: %w", err)
+	}
+	if !user.CanLogIn() {
+		return nil, ErrUserInactive
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token family: %w", err)
+	}
+
+	return s.issue(ctx, user.ID, familyID)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// token is used up; presenting it again revokes every token descended from
+// the same login, since only a thief would hold on to a used token.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	now := s.clock.Now()
+
+	stored, err := s.refreshTokens.Use(ctx, HashRefreshToken(refreshToken), now)
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to use refresh token: %w", err)
+	}
+
+	if !stored.UsedAt.IsZero() && stored.RevokedAt.IsZero() {
+		log.Printf("Refresh token reused for user %s, revoking its family", stored.UserID)
+		if err := s.refreshTokens.RevokeFamily(ctx, stored.FamilyID, now); err != nil {
+			return nil, fmt.Errorf("failed to revoke refresh token family: %w", err)
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if !stored.Usable(now) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.users.GetUserByID(service.ContextWithSystem(ctx), stored.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user for refresh: %w", err)
+	}
+	if !user.CanLogIn() {
+		return nil, ErrUserInactive
+	}
+
+	return s.issue(ctx, user.ID, stored.FamilyID)
+}
+
+// Logout revokes a refresh token and every token rotated from the same login.
+// Access tokens already issued stay valid until they expire.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	now := s.clock.Now()
+
+	stored, err := s.refreshTokens.Use(ctx, HashRefreshToken(refreshToken), now)
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to use refresh token: %w", err)
+	}
+
+	if err := s.refreshTokens.RevokeFamily(ctx, stored.FamilyID, now); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// AuthenticateToken checks an access token and returns the ID of the user it
+// was issued to. Like Refresh it looks the user up, so a token stops working
+// as soon as its user is suspended, deactivated or deleted.
+func (s *AuthService) AuthenticateToken(ctx context.Context, accessToken string) (entity.UserID, error) {
+	claims, err := s.signer.Verify(accessToken)
+	if err != nil {
+		return "", err
+	}
+	if claims.Subject == "" || claims.Issuer != s.issuer {
+		return "", ErrInvalidToken
+	}
+	if claims.Expired(s.clock.Now()) {
+		return "", ErrTokenExpired
+	}
+
+	user, err := s.users.GetUserByID(service.ContextWithSystem(ctx), claims.Subject)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get user of token: %w", err)
+	}
+	if !user.CanLogIn() {
+		return "", ErrUserInactive
+	}
+
+	return user.ID, nil
+}
+
+// issue signs a new access token and stores a new refresh token in the given family
+func (s *AuthService) issue(ctx context.Context, id entity.UserID, familyID string) (*TokenPair, error) {
+	now := s.clock.Now()
+
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	pair := &TokenPair{
+		IssuedAt:              now,
+		AccessTokenExpiresAt:  now.Add(s.accessTokenTTL),
+		RefreshTokenExpiresAt: now.Add(s.refreshTokenTTL),
+	}
+
+	pair.AccessToken, err = s.signer.Sign(Claims{
+		Issuer:    s.issuer,
+		Subject:   id,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: pair.AccessTokenExpiresAt.Unix(),
+		ID:        tokenID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	pair.RefreshToken, err = randomToken(refreshTokenBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	err = s.refreshTokens.Save(ctx, RefreshToken{
+		Hash:      HashRefreshToken(pair.RefreshToken),
+		UserID:    id,
+		FamilyID:  familyID,
+		IssuedAt:  now,
+		ExpiresAt: pair.RefreshTokenExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return pair, nil
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+)
+
+// testPassword is the password of the user returned by fakeUsers
+const testPassword = "Secret-passw0rd"
+
+// fakeUsers knows a single user, logging in with testPassword
+type fakeUsers struct {
+	user *entity.User
+}
+
+func (f *fakeUsers) Authenticate(ctx context.Context, email string, password string) (*entity.User, error) {
+	if email != f.user.Email.String() || password != testPassword {
+		return nil, service.ErrInvalidCredentials
+	}
+	return f.user, nil
+}
+
+func (f *fakeUsers) GetUserByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	if id != f.user.ID {
+		return nil, repository.ErrUserNotFound
+	}
+	return f.user, nil
+}
+
+// fakeRefreshTokens keeps refresh tokens in a map
+type fakeRefreshTokens struct {
+	mu     sync.Mutex
+	tokens map[string]RefreshToken
+}
+
+func (f *fakeRefreshTokens) Save(ctx context.Context, token RefreshToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[token.Hash] = token
+	return nil
+}
+
+func (f *fakeRefreshTokens) Use(ctx context.Context, hash string, at time.Time) (RefreshToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[hash]
+	if !ok {
+		return RefreshToken{}, ErrRefreshTokenNotFound
+	}
+	if token.UsedAt.IsZero() {
+		used := token
+		used.UsedAt = at
+		f.tokens[hash] = used
+	}
+	return token, nil
+}
+
+func (f *fakeRefreshTokens) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for hash, token := range f.tokens {
+		if token.FamilyID == familyID && token.RevokedAt.IsZero() {
+			token.RevokedAt = at
+			f.tokens[hash] = token
+		}
+	}
+	return nil
+}
+
+// newTestUser returns a pending user logging in with testPassword
+func newTestUser(t *testing.T) *entity.User {
+	t.Helper()
+
+	hashed, _ := entity.PasswordHasher{Iterations: 1000, SaltLength: 16, KeyLength: 32}.Hash(testPassword)
+	user, err := entity.NewUser("test@example.com", "Test User", hashed)
+	if err != nil {
+		t.Fatalf("NewUser() unexpected error: %v", err)
+	}
+	return user
+}
+
+func newTestAuthService(t *testing.T, c clock.Clock) (*AuthService, *entity.User) {
+	t.Helper()
+
+	user := newTestUser(t)
+	key, _ := NewHS256Key("test", testSecret)
+	authService := NewAuthService(&fakeUsers{user: user}, NewKeyring(key),
+		&fakeRefreshTokens{tokens: map[string]RefreshToken{}},
+		WithClock(c), WithIssuer("test"),
+	)
+	return authService, user
+}
+
+func TestAuthService_LoginAndAuthenticateToken(t *testing.T) {
+	ctx := context.Background()
+	fakeClock := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	authService, user := newTestAuthService(t, fakeClock)
+
+	if _, err := authService.Login(ctx, "test@example.com", "wrong"); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("Login() with a wrong password expected ErrLoginFailed, got: %v", err)
+	}
+
+	pair, err := authService.Login(ctx, "test@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("Login() unexpected error: %v", err)
+	}
+	if !pair.AccessTokenExpiresAt.Equal(fakeClock.Now().Add(DefaultAccessTokenTTL)) {
+		t.Errorf("Login() access token expires at %v", pair.AccessTokenExpiresAt)
+	}
+
+	id, err := authService.AuthenticateToken(ctx, pair.AccessToken)
+	if err != nil || id != user.ID {
+		t.Errorf("AuthenticateToken() = %s, %v, want %s", id, err, user.ID)
+	}
+
+	fakeClock.Advance(DefaultAccessTokenTTL)
+	if _, err := authService.AuthenticateToken(ctx, pair.AccessToken); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("AuthenticateToken() after expiry expected ErrTokenExpired, got: %v", err)
+	}
+
+	fresh, _ := authService.Login(ctx, "test@example.com", testPassword)
+	_ = user.Activate("verified", "admin")
+	_ = user.Suspend("abuse", "admin")
+	if _, err := authService.Login(ctx, "test@example.com", testPassword); !errors.Is(err, ErrUserInactive) {
+		t.Errorf("Login() of a suspended user expected ErrUserInactive, got: %v", err)
+	}
+	if _, err := authService.AuthenticateToken(ctx, fresh.AccessToken); !errors.Is(err, ErrUserInactive) {
+		t.Errorf("AuthenticateToken() of a suspended user expected ErrUserInactive, got: %v", err)
+	}
+}
+
+func TestAuthService_PendingUsersLogIn(t *testing.T) {
+	ctx := context.Background()
+	authService, user := newTestAuthService(t, clock.System)
+	if user.Status != entity.StatusPending {
+		t.Fatalf("newTestUser() status = %s, want %s", user.Status, entity.StatusPending)
+	}
+
+	pair, err := authService.Login(ctx, "test@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("Login() of a pending user unexpected error: %v", err)
+	}
+	if id, err := authService.AuthenticateToken(ctx, pair.AccessToken); err != nil || id != user.ID {
+		t.Errorf("AuthenticateToken() of a pending user = %s, %v, want %s", id, err, user.ID)
+	}
+	if _, err := authService.Refresh(ctx, pair.RefreshToken); err != nil {
+		t.Errorf("Refresh() of a pending user unexpected error: %v", err)
+	}
+}
+
+func TestAuthService_RefreshRotatesTokens(t *testing.T) {
+	ctx := context.Background()
+	fakeClock := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	authService, _ := newTestAuthService(t, fakeClock)
+
+	first, _ := authService.Login(ctx, "test@example.com", testPassword)
+
+	second, err := authService.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Refresh() returned the same refresh token")
+	}
+
+	// Replaying the used token revokes the whole family, including second
+	if _, err := authService.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() with a used token expected ErrInvalidRefreshToken, got: %v", err)
+	}
+	if _, err := authService.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() after reuse expected ErrInvalidRefreshToken, got: %v", err)
+	}
+
+	third, _ := authService.Login(ctx, "test@example.com", testPassword)
+	fakeClock.Advance(DefaultRefreshTokenTTL)
+	if _, err := authService.Refresh(ctx, third.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() with an expired token expected ErrInvalidRefreshToken, got: %v", err)
+	}
+}
+
+func TestAuthService_Logout(t *testing.T) {
+	ctx := context.Background()
+	authService, _ := newTestAuthService(t, clock.System)
+
+	pair, _ := authService.Login(ctx, "test@example.com", testPassword)
+	if err := authService.Logout(ctx, pair.RefreshToken); err != nil {
+		t.Fatalf("Logout() unexpected error: %v", err)
+	}
+	if _, err := authService.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() after logout expected ErrInvalidRefreshToken, got: %v", err)
+	}
+	if err := authService.Logout(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Logout() with an unknown token expected ErrInvalidRefreshToken, got: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Session errors
+var (
+	ErrInvalidSession  = domainerr.New(domainerr.KindUnauthenticated, "invalid_session", "invalid session")
+	ErrSessionExpired  = domainerr.New(domainerr.KindUnauthenticated, "session_expired", "session has expired")
+	ErrSessionNotFound = domainerr.New(domainerr.KindNotFound, "session_not_found", "session not found")
+)
+
+// Session is a server-side login. The client holds a random session token;
+// only its hash is stored, next to the device the session was opened from.
+// Unlike an access token a session can be revoked at any time, and it ends
+// once it has been idle for too long or has reached its absolute lifetime.
+type Session struct {
+	ID         string // public handle used to list and revoke the session
+	TokenHash  string
+	UserID     entity.UserID
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time // absolute end of the session, however active it is
+}
+
+// Expiry returns when the session ends if it stays idle from now on
+func (s Session) Expiry(idleTimeout time.Duration) time.Time {
+	idle := s.LastSeenAt.Add(idleTimeout)
+	if idle.Before(s.ExpiresAt) {
+		return idle
+	}
+	return s.ExpiresAt
+}
+
+// Active reports whether the session is still valid at now
+func (s Session) Active(now time.Time, idleTimeout time.Duration) bool {
+	return now.Before(s.Expiry(idleTimeout))
+}
+
+// Device describes where a session is opened from
+type Device struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionStore stores sessions
+type SessionStore interface {
+	// Create stores a new session
+	Create(ctx context.Context, session Session) error
+
+	// FindByTokenHash returns the session with the given token hash. It fails
+	// with ErrSessionNotFound if there is none.
+	FindByTokenHash(ctx context.Context, hash string) (Session, error)
+
+	// Touch records that the session was used at the given time
+	Touch(ctx context.Context, id string, at time.Time) error
+
+	// ListByUser returns the sessions of a user, oldest first
+	ListByUser(ctx context.Context, userID entity.UserID) ([]Session, error)
+
+	// Delete removes a session of the given user. It fails with
+	// ErrSessionNotFound if the user has no session with that ID.
+	Delete(ctx context.Context, userID entity.UserID, id string) error
+
+	// DeleteByUser removes every session of a user
+	DeleteByUser(ctx context.Context, userID entity.UserID) error
+}
+
+// sessionIDKey is the context key for the session a request was authenticated with
+type sessionIDKey struct{}
+
+// ContextWithSessionID returns a context carrying the ID of the caller's session
+func ContextWithSessionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, id)
+}
+
+// SessionIDFromContext returns the ID of the caller's session, if the
+// request was authenticated with one
+func SessionIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(sessionIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// HashSessionToken returns the hash a session token is stored under
+func HashSessionToken(token string) string {
+	return hashToken(token)
+}
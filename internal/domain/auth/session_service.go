@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+)
+
+// Session lifetimes used unless configured otherwise
+const (
+	DefaultSessionIdleTimeout     = 30 * time.Minute
+	DefaultSessionAbsoluteTimeout = 24 * time.Hour
+)
+
+const (
+	// sessionTokenBytes is the amount of randomness in a session token
+	sessionTokenBytes = 32
+
+	// lastSeenResolution is how stale a session's last-seen time may get
+	// before a request updates it, so that a busy client doesn't cause a
+	// write on every request
+	lastSeenResolution = time.Minute
+
+	// maxUserAgentLength caps the user agent stored with a session
+	maxUserAgentLength = 512
+)
+
+// SessionService opens server-side sessions for users logging in with their
+// email and password, resolves session tokens back to their sessions and
+// lets users see and end their sessions
+type SessionService struct {
+	users           Users
+	store           SessionStore
+	clock           clock.Clock
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
+}
+
+// SessionOption configures a SessionService
+type SessionOption func(*SessionService)
+
+// WithSessionClock sets the clock used to time sessions
+func WithSessionClock(c clock.Clock) SessionOption {
+	return func(s *SessionService) {
+		s.clock = c
+	}
+}
+
+// WithIdleTimeout sets how long a session may go unused before it ends
+func WithIdleTimeout(timeout time.Duration) SessionOption {
+	return func(s *SessionService) {
+		s.idleTimeout = timeout
+	}
+}
+
+// WithAbsoluteTimeout sets how long a session lasts at most, however active it is
+func WithAbsoluteTimeout(timeout time.Duration) SessionOption {
+	return func(s *SessionService) {
+		s.absoluteTimeout = timeout
+	}
+}
+
+// NewSessionService creates a new SessionService
+func NewSessionService(users Users, store SessionStore, opts ...SessionOption) *SessionService {
+	s := &SessionService{
+		users:           users,
+		store:           store,
+		clock:           clock.System,
+		idleTimeout:     DefaultSessionIdleTimeout,
+		absoluteTimeout: DefaultSessionAbsoluteTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// IdleTimeout returns how long a session may go unused before it ends
+func (s *SessionService) IdleTimeout() time.Duration {
+	return s.idleTimeout
+}
+
+// Login verifies an email/password pair and opens a session for the user on
+// the given device. It returns the session and the token that identifies it;
+// the token is not stored and can't be recovered later.
+func (s *SessionService) Login(ctx context.Context, email string, password string, device Device) (string, *Session, error) {
+	user, err := s.users.Authenticate(ctx, email, password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		return "", nil, ErrLoginFailed
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to authenticate user: %w", err)
+	}
+	if !user.CanLogIn() {
+		return "", nil, ErrUserInactive
+	}
+
+	id, err := randomToken(16)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	token, err := randomToken(sessionTokenBytes)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate session token: %w", err)
+	}
+
+	now := s.clock.Now()
+	session := &Session{
+		ID:         id,
+		TokenHash:  HashSessionToken(token),
+		UserID:     user.ID,
+		UserAgent:  truncate(device.UserAgent, maxUserAgentLength),
+		IP:         device.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.absoluteTimeout),
+	}
+	if err := s.store.Create(ctx, *session); err != nil {
+		return "", nil, fmt.Errorf("failed to save session: %w", err)
+	}
+
+	return token, session, nil
+}
+
+// Authenticate returns the session a token identifies and records that it
+// was used. A session that has run out, or whose user has since been
+// suspended, deactivated or deleted, is removed.
+func (s *SessionService) Authenticate(ctx context.Context, token string) (*Session, error) {
+	session, err := s.store.FindByTokenHash(ctx, HashSessionToken(token))
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, ErrInvalidSession
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+
+	now := s.clock.Now()
+	if !session.Active(now, s.idleTimeout) {
+		return nil, s.remove(ctx, session, ErrSessionExpired)
+	}
+
+	user, err := s.users.GetUserByID(service.ContextWithSystem(ctx), session.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, s.remove(ctx, session, ErrInvalidSession)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user of session: %w", err)
+	}
+	if !user.CanLogIn() {
+		return nil, s.remove(ctx, session, ErrUserInactive)
+	}
+
+	if now.Sub(session.LastSeenAt) >= lastSeenResolution {
+		if err := s.store.Touch(ctx, session.ID, now); err != nil {
+			return nil, fmt.Errorf("failed to update session: %w", err)
+		}
+		session.LastSeenAt = now
+	}
+
+	return &session, nil
+}
+
+// remove deletes a session that may no longer be used and returns reason,
+// or the error deleting it
+func (s *SessionService) remove(ctx context.Context, session Session, reason error) error {
+	if err := s.store.Delete(ctx, session.UserID, session.ID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return fmt.Errorf("failed to remove session: %w", err)
+	}
+	return reason
+}
+
+// ListSessions returns the active sessions of a user, oldest first
+func (s *SessionService) ListSessions(ctx context.Context, userID entity.UserID) ([]Session, error) {
+	sessions, err := s.store.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	now := s.clock.Now()
+	active := sessions[:0]
+	for _, session := range sessions {
+		if session.Active(now, s.idleTimeout) {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// RevokeSession ends one of the user's sessions
+func (s *SessionService) RevokeSession(ctx context.Context, userID entity.UserID, sessionID string) error {
+	if err := s.store.Delete(ctx, userID, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllSessions ends every session of the user
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID entity.UserID) error {
+	if err := s.store.DeleteByUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
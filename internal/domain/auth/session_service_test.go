@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// fakeSessions keeps sessions in a map by ID
+type fakeSessions struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func (f *fakeSessions) Create(ctx context.Context, session Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions[session.ID] = session
+	return nil
+}
+
+func (f *fakeSessions) FindByTokenHash(ctx context.Context, hash string) (Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, session := range f.sessions {
+		if session.TokenHash == hash {
+			return session, nil
+		}
+	}
+	return Session{}, ErrSessionNotFound
+}
+
+func (f *fakeSessions) Touch(ctx context.Context, id string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session := f.sessions[id]
+	session.LastSeenAt = at
+	f.sessions[id] = session
+	return nil
+}
+
+func (f *fakeSessions) ListByUser(ctx context.Context, userID entity.UserID) ([]Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sessions []Session
+	for _, session := range f.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (f *fakeSessions) Delete(ctx context.Context, userID entity.UserID, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if session, ok := f.sessions[id]; !ok || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	delete(f.sessions, id)
+	return nil
+}
+
+func (f *fakeSessions) DeleteByUser(ctx context.Context, userID entity.UserID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, session := range f.sessions {
+		if session.UserID == userID {
+			delete(f.sessions, id)
+		}
+	}
+	return nil
+}
+
+func newTestSessionService(t *testing.T, c clock.Clock) (*SessionService, *entity.User) {
+	t.Helper()
+
+	user := newTestUser(t)
+	sessionService := NewSessionService(&fakeUsers{user: user}, &fakeSessions{sessions: map[string]Session{}},
+		WithSessionClock(c), WithIdleTimeout(30*time.Minute), WithAbsoluteTimeout(2*time.Hour),
+	)
+	return sessionService, user
+}
+
+func TestSessionService_LoginAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	fakeClock := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sessionService, user := newTestSessionService(t, fakeClock)
+	device := Device{UserAgent: "curl/8.0", IP: "192.0.2.1"}
+
+	if _, _, err := sessionService.Login(ctx, "test@example.com", "wrong", device); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("Login() with a wrong password expected ErrLoginFailed, got: %v", err)
+	}
+
+	token, session, err := sessionService.Login(ctx, "test@example.com", testPassword, device)
+	if err != nil {
+		t.Fatalf("Login() unexpected error: %v", err)
+	}
+	if session.UserID != user.ID || session.UserAgent != "curl/8.0" || session.IP != "192.0.2.1" {
+		t.Errorf("Login() session = %+v", session)
+	}
+	if session.TokenHash == token {
+		t.Error("Login() stored the session token in the clear")
+	}
+
+	fakeClock.Advance(20 * time.Minute)
+	authenticated, err := sessionService.Authenticate(ctx, token)
+	if err != nil || authenticated.ID != session.ID {
+		t.Fatalf("Authenticate() = %+v, %v, want the session", authenticated, err)
+	}
+	if !authenticated.LastSeenAt.Equal(fakeClock.Now()) {
+		t.Errorf("Authenticate() last seen = %v, want %v", authenticated.LastSeenAt, fakeClock.Now())
+	}
+
+	if _, err := sessionService.Authenticate(ctx, "unknown"); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Authenticate() with an unknown token expected ErrInvalidSession, got: %v", err)
+	}
+}
+
+func TestSessionService_Timeouts(t *testing.T) {
+	ctx := context.Background()
+	fakeClock := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sessionService, user := newTestSessionService(t, fakeClock)
+
+	idle, _, _ := sessionService.Login(ctx, "test@example.com", testPassword, Device{})
+	busy, _, _ := sessionService.Login(ctx, "test@example.com", testPassword, Device{})
+
+	// busy is used every 20 minutes and so outlives the idle timeout, but
+	// not the absolute one
+	for i := 0; i < 5; i++ {
+		fakeClock.Advance(20 * time.Minute)
+		if _, err := sessionService.Authenticate(ctx, busy); err != nil {
+			t.Fatalf("Authenticate() of a busy session unexpected error: %v", err)
+		}
+	}
+	if _, err := sessionService.Authenticate(ctx, idle); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("Authenticate() of an idle session expected ErrSessionExpired, got: %v", err)
+	}
+	if _, err := sessionService.Authenticate(ctx, idle); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Authenticate() of a removed session expected ErrInvalidSession, got: %v", err)
+	}
+
+	fakeClock.Advance(20 * time.Minute)
+	if _, err := sessionService.Authenticate(ctx, busy); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("Authenticate() after the absolute timeout expected ErrSessionExpired, got: %v", err)
+	}
+
+	if sessions, _ := sessionService.ListSessions(ctx, user.ID); len(sessions) != 0 {
+		t.Errorf("ListSessions() = %+v, want no active sessions", sessions)
+	}
+}
+
+func TestSessionService_Revoke(t *testing.T) {
+	ctx := context.Background()
+	sessionService, user := newTestSessionService(t, clock.System)
+
+	first, session, _ := sessionService.Login(ctx, "test@example.com", testPassword, Device{})
+	second, _, _ := sessionService.Login(ctx, "test@example.com", testPassword, Device{})
+
+	if sessions, _ := sessionService.ListSessions(ctx, user.ID); len(sessions) != 2 {
+		t.Fatalf("ListSessions() returned %d sessions, want 2", len(sessions))
+	}
+
+	if err := sessionService.RevokeSession(ctx, "someone_else", session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession() of another user's session expected ErrSessionNotFound, got: %v", err)
+	}
+	if err := sessionService.RevokeSession(ctx, user.ID, session.ID); err != nil {
+		t.Fatalf("RevokeSession() unexpected error: %v", err)
+	}
+	if _, err := sessionService.Authenticate(ctx, first); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Authenticate() of a revoked session expected ErrInvalidSession, got: %v", err)
+	}
+	if _, err := sessionService.Authenticate(ctx, second); err != nil {
+		t.Errorf("RevokeSession() ended another session: %v", err)
+	}
+
+	if err := sessionService.RevokeAllSessions(ctx, user.ID); err != nil {
+		t.Fatalf("RevokeAllSessions() unexpected error: %v", err)
+	}
+	if _, err := sessionService.Authenticate(ctx, second); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Authenticate() after RevokeAllSessions() expected ErrInvalidSession, got: %v", err)
+	}
+}
+
+func TestSessionService_RejectsInactiveUsers(t *testing.T) {
+	ctx := context.Background()
+	sessionService, user := newTestSessionService(t, clock.System)
+
+	token, _, _ := sessionService.Login(ctx, "test@example.com", testPassword, Device{})
+	_ = user.Activate("verified", "admin")
+	if err := user.Suspend("abuse", "admin"); err != nil {
+		t.Fatalf("Suspend() unexpected error: %v", err)
+	}
+
+	if _, err := sessionService.Authenticate(ctx, token); !errors.Is(err, ErrUserInactive) {
+		t.Errorf("Authenticate() for a suspended user expected ErrUserInactive, got: %v", err)
+	}
+	if _, err := sessionService.Authenticate(ctx, token); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Authenticate() of a suspended user's removed session expected ErrInvalidSession, got: %v", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 2); got != "h" {
+		t.Errorf("truncate() = %q, want a cut before the split rune", got)
+	}
+	if got := truncate(strings.Repeat("a", 10), 20); len(got) != 10 {
+		t.Errorf("truncate() shortened a short string to %q", got)
+	}
+}
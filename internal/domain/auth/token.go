@@ -0,0 +1,73 @@
+// Package auth logs users in and proves who is calling: it issues signed
+// access tokens and rotating refresh tokens, or opens revocable server-side
+// sessions, and resolves either back to the user it belongs to.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Authentication errors
+var (
+	ErrLoginFailed          = domainerr.New(domainerr.KindUnauthenticated, "login_failed", "invalid email or password")
+	ErrUserInactive         = domainerr.New(domainerr.KindForbidden, "user_inactive", "user is suspended or deactivated")
+	ErrInvalidToken         = domainerr.New(domainerr.KindUnauthenticated, "invalid_token", "invalid access token")
+	ErrTokenExpired         = domainerr.New(domainerr.KindUnauthenticated, "token_expired", "access token has expired")
+	ErrInvalidRefreshToken  = domainerr.New(domainerr.KindUnauthenticated, "invalid_refresh_token", "invalid refresh token")
+	ErrRefreshTokenNotFound = domainerr.New(domainerr.KindNotFound, "refresh_token_not_found", "refresh token not found")
+)
+
+// Claims are the JWT claims of an access token. Times are seconds since the
+// Unix epoch, as JWT requires.
+type Claims struct {
+	Issuer    string        `json:"iss,omitempty"`
+	Subject   entity.UserID `json:"sub"`
+	IssuedAt  int64         `json:"iat"`
+	ExpiresAt int64         `json:"exp"`
+	ID        string        `json:"jti"`
+}
+
+// Expired reports whether the token has expired at now
+func (c Claims) Expired(now time.Time) bool {
+	return now.Unix() >= c.ExpiresAt
+}
+
+// TokenSigner encodes claims as a signed token and verifies them again.
+// Keyring is the JWT implementation.
+type TokenSigner interface {
+	Sign(claims Claims) (string, error)
+	// Verify returns the claims of a token with a valid signature. It does
+	// not check expiry.
+	Verify(token string) (Claims, error)
+}
+
+// TokenPair is what a login or refresh hands to the client
+type TokenPair struct {
+	IssuedAt              time.Time
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
+
+// randomToken returns n random bytes encoded as unpadded base64url
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hex digest a secret token is stored under
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,187 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// PolicyAuthorizer is an Authorizer that evaluates a Policy
+type PolicyAuthorizer struct {
+	policy *Policy
+	clock  clock.Clock
+}
+
+// PolicyOption configures a PolicyAuthorizer
+type PolicyOption func(*PolicyAuthorizer)
+
+// WithPolicyClock sets the clock that tells the time of a request
+func WithPolicyClock(c clock.Clock) PolicyOption {
+	return func(a *PolicyAuthorizer) {
+		a.clock = c
+	}
+}
+
+// NewPolicyAuthorizer creates a PolicyAuthorizer for policy
+func NewPolicyAuthorizer(policy *Policy, opts ...PolicyOption) *PolicyAuthorizer {
+	a := &PolicyAuthorizer{
+		policy: policy,
+		clock:  clock.System,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Authorize evaluates the policy for a request made now
+func (a *PolicyAuthorizer) Authorize(ctx context.Context, subject Subject, action Action, resource *entity.User) error {
+	decision := a.Explain(ctx, subject, action, resource, Environment{Time: a.clock.Now()})
+	if decision.Allowed {
+		return nil
+	}
+	return Deny(subject, action)
+}
+
+// Explain evaluates every rule of the policy that applies to action. Deny
+// rules take precedence over allow rules, and nothing is allowed unless a
+// rule allows it.
+func (a *PolicyAuthorizer) Explain(ctx context.Context, subject Subject, action Action, resource *entity.User, env Environment) Decision {
+	attributes := a.policy.attributes(subject, resource, env)
+
+	var decision Decision
+	var allow, deny *Rule
+	for i := range a.policy.Rules {
+		rule := &a.policy.Rules[i]
+		if !rule.appliesTo(action) {
+			continue
+		}
+
+		evaluation := rule.evaluate(attributes)
+		decision.Evaluations = append(decision.Evaluations, evaluation)
+		if !evaluation.Matched {
+			continue
+		}
+		if rule.Effect == EffectDeny && deny == nil {
+			deny = rule
+		}
+		if rule.Effect == EffectAllow && allow == nil {
+			allow = rule
+		}
+	}
+
+	decider := deny
+	if decider == nil {
+		decider = allow
+	}
+	if decider == nil {
+		decision.Reason = fmt.Sprintf("no rule allows %s", action)
+		return decision
+	}
+
+	decision.Allowed = decider.Effect == EffectAllow
+	decision.Rule = decider.ID
+	decision.Reason = fmt.Sprintf("allowed by rule %q", decider.ID)
+	if !decision.Allowed {
+		decision.Reason = fmt.Sprintf("denied by rule %q", decider.ID)
+	}
+	if decider.Description != "" {
+		decision.Reason += ": " + decider.Description
+	}
+	return decision
+}
+
+// appliesTo reports whether the rule lists action
+func (r *Rule) appliesTo(action Action) bool {
+	return slices.Contains(r.Actions, AnyAction) || slices.Contains(r.Actions, action)
+}
+
+// evaluate checks every condition of the rule, so that a decision can
+// show all that failed rather than just the first
+func (r *Rule) evaluate(attributes map[string]any) RuleEvaluation {
+	evaluation := RuleEvaluation{Rule: r.ID, Effect: r.Effect, Matched: true}
+	for _, condition := range r.Conditions {
+		result := condition.evaluate(attributes)
+		evaluation.Conditions = append(evaluation.Conditions, result)
+		evaluation.Matched = evaluation.Matched && result.Matched
+	}
+	return evaluation
+}
+
+// evaluate compares the condition's attribute with its operand. Kinds were
+// checked when the policy was compiled.
+func (c Condition) evaluate(attributes map[string]any) ConditionResult {
+	actual := attributes[c.Attribute]
+	expected := c.Value
+	if c.ValueFrom != "" {
+		expected = attributes[c.ValueFrom]
+	}
+
+	var matched bool
+	switch c.Operator {
+	case OpEquals:
+		matched = actual == expected
+	case OpNotEquals:
+		matched = actual != expected
+	case OpIn:
+		matched = slices.Contains(expected.([]any), actual)
+	case OpNotIn:
+		matched = !slices.Contains(expected.([]any), actual)
+	case OpContains:
+		matched = slices.Contains(actual.([]string), expected.(string))
+	case OpNotContains:
+		matched = !slices.Contains(actual.([]string), expected.(string))
+	case OpGreater:
+		matched = actual.(float64) > expected.(float64)
+	case OpGreaterOrEqual:
+		matched = actual.(float64) >= expected.(float64)
+	case OpLess:
+		matched = actual.(float64) < expected.(float64)
+	case OpLessOrEqual:
+		matched = actual.(float64) <= expected.(float64)
+	}
+
+	return ConditionResult{Condition: c.String(), Actual: actual, Expected: expected, Matched: matched}
+}
+
+// attributes returns the value of every attribute for a request, with
+// zero values for the resource's attributes when there is no resource
+func (p *Policy) attributes(subject Subject, resource *entity.User, env Environment) map[string]any {
+	at := env.Time.In(p.location)
+	attributes := map[string]any{
+		"subject.authenticated": !subject.Anonymous(),
+		"subject.id":            string(subject.UserID),
+		"subject.roles":         roleNames(subject.Roles),
+		"subject.rank":          float64(rank(subject.Roles)),
+		"subject.tenant":        string(subject.Tenant),
+		"resource.exists":       resource != nil,
+		"resource.self":         subject.Owns(resource),
+		"resource.id":           "",
+		"resource.status":       "",
+		"resource.roles":        []string{},
+		"resource.rank":         float64(0),
+		"resource.tenant":       "",
+		"env.hour":              float64(at.Hour()),
+		"env.weekday":           weekdays[at.Weekday()],
+	}
+	if resource != nil {
+		attributes["resource.id"] = string(resource.ID)
+		attributes["resource.status"] = string(resource.Status)
+		attributes["resource.roles"] = roleNames(resource.Roles)
+		attributes["resource.rank"] = float64(rank(resource.Roles))
+		attributes["resource.tenant"] = string(resource.Tenant)
+	}
+	return attributes
+}
+
+// roleNames returns roles as strings, never nil
+func roleNames(roles []entity.Role) []string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.String()
+	}
+	return names
+}
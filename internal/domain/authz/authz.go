@@ -0,0 +1,178 @@
+// Package authz decides whether a caller may perform an operation on a
+// user. The user service asks an Authorizer before every operation it
+// performs on a caller's behalf.
+package authz
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Authorization errors
+var (
+	ErrAuthenticationRequired = domainerr.New(domainerr.KindUnauthenticated, "authentication_required", "authentication required")
+	ErrForbidden              = domainerr.New(domainerr.KindForbidden, "forbidden", "operation not permitted")
+	ErrInvalidAction          = domainerr.Validation("invalid_action", "action", "unknown action")
+)
+
+// Action names an operation on users
+type Action string
+
+// User operations
+const (
+	ActionCreate         Action = "user.create"
+	ActionRead           Action = "user.read"
+	ActionList           Action = "user.list"
+	ActionReadHistory    Action = "user.read_history"
+	ActionUpdate         Action = "user.update"
+	ActionChangePassword Action = "user.change_password"
+	ActionActivate       Action = "user.activate"
+	ActionSuspend        Action = "user.suspend"
+	ActionReactivate     Action = "user.reactivate"
+	ActionDeactivate     Action = "user.deactivate"
+	ActionDelete         Action = "user.delete"
+	ActionRestore        Action = "user.restore"
+	ActionPurge          Action = "user.purge"
+	ActionAssignRoles    Action = "user.assign_roles"
+	ActionAssignTenant   Action = "user.assign_tenant"
+)
+
+// ActionCheckAccess dry-runs an authorization decision for any caller
+const ActionCheckAccess Action = "authz.check_access"
+
+// Audit log operations
+const (
+	ActionReadAudit   Action = "audit.read"
+	ActionVerifyAudit Action = "audit.verify"
+)
+
+// AllActions lists every action
+var AllActions = []Action{
+	ActionCreate, ActionRead, ActionList, ActionReadHistory, ActionUpdate, ActionChangePassword,
+	ActionActivate, ActionSuspend, ActionReactivate, ActionDeactivate,
+	ActionDelete, ActionRestore, ActionPurge, ActionAssignRoles, ActionAssignTenant,
+	ActionCheckAccess, ActionReadAudit, ActionVerifyAudit,
+}
+
+// Validate checks that the action is a known value
+func (a Action) Validate() error {
+	if !slices.Contains(AllActions, a) {
+		return ErrInvalidAction.Withf("%q", string(a))
+	}
+	return nil
+}
+
+// String returns the action as string
+func (a Action) String() string {
+	return string(a)
+}
+
+// Subject is the caller an operation is performed for
+type Subject struct {
+	UserID entity.UserID // empty for anonymous callers
+	Roles  []entity.Role
+	Tenant entity.Tenant
+}
+
+// SubjectOf returns the subject for an authenticated user
+func SubjectOf(user *entity.User) Subject {
+	return Subject{UserID: user.ID, Roles: user.Roles, Tenant: user.Tenant}
+}
+
+// Anonymous reports whether the caller is not authenticated
+func (s Subject) Anonymous() bool {
+	return s.UserID == ""
+}
+
+// HasRole reports whether the caller holds role
+func (s Subject) HasRole(role entity.Role) bool {
+	return slices.Contains(s.Roles, role)
+}
+
+// Owns reports whether resource is the caller's own record
+func (s Subject) Owns(resource *entity.User) bool {
+	return !s.Anonymous() && resource != nil && resource.ID == s.UserID
+}
+
+// roleRanks orders roles, so that role grants and policies can tell
+// whether a caller outranks the user they act on
+var roleRanks = map[entity.Role]int{
+	entity.RoleAdmin:   2,
+	entity.RoleSupport: 1,
+}
+
+// topRank is the rank of admins, the highest role
+var topRank = roleRanks[entity.RoleAdmin]
+
+// rank returns the highest rank of roles, 0 for none
+func rank(roles []entity.Role) int {
+	var highest int
+	for _, role := range roles {
+		highest = max(highest, roleRanks[role])
+	}
+	return highest
+}
+
+// Authorizer decides whether a subject may perform an action on a user
+type Authorizer interface {
+	// Authorize returns nil if subject may perform action on resource.
+	// resource is nil for actions that don't concern an existing user, such
+	// as creating or listing users. A denial is ErrAuthenticationRequired for
+	// an anonymous subject and ErrForbidden otherwise.
+	Authorize(ctx context.Context, subject Subject, action Action, resource *entity.User) error
+}
+
+// Environment holds the circumstances of a request that decisions may depend on
+type Environment struct {
+	Time time.Time
+}
+
+// Decision is an explained authorization decision
+type Decision struct {
+	Allowed bool
+	// Rule names the rule that decided, if any
+	Rule string
+	// Reason says in words why the decision was made
+	Reason string
+	// Evaluations lists every rule that applied to the action, in order
+	Evaluations []RuleEvaluation
+}
+
+// RuleEvaluation is how one rule fared in a decision
+type RuleEvaluation struct {
+	Rule       string
+	Effect     Effect
+	Matched    bool
+	Conditions []ConditionResult
+}
+
+// ConditionResult is how one condition of a rule fared
+type ConditionResult struct {
+	// Condition is the condition as text, e.g. "env.hour gte 9"
+	Condition string
+	// Actual is the value of the condition's attribute
+	Actual any
+	// Expected is the value it was compared with
+	Expected any
+	Matched  bool
+}
+
+// Explainer is an Authorizer that can say why it decides the way it does
+type Explainer interface {
+	Authorizer
+	// Explain decides whether subject may perform action on resource in env
+	// and says why, without performing anything
+	Explain(ctx context.Context, subject Subject, action Action, resource *entity.User, env Environment) Decision
+}
+
+// Deny returns the error an Authorizer reports when subject may not perform action
+func Deny(subject Subject, action Action) error {
+	if subject.Anonymous() {
+		return ErrAuthenticationRequired.Withf("%s", action)
+	}
+	return ErrForbidden.Withf("%s", action)
+}
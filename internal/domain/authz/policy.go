@@ -0,0 +1,301 @@
+package authz
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Effect is what a matching rule does to a decision
+type Effect string
+
+// Rule effects
+const (
+	EffectAllow Effect = "allow"
+	EffectDeny  Effect = "deny"
+)
+
+// Operator compares a condition's attribute with its value
+type Operator string
+
+// Condition operators
+const (
+	OpEquals         Operator = "equals"
+	OpNotEquals      Operator = "not_equals"
+	OpIn             Operator = "in"
+	OpNotIn          Operator = "not_in"
+	OpContains       Operator = "contains"
+	OpNotContains    Operator = "not_contains"
+	OpGreater        Operator = "gt"
+	OpGreaterOrEqual Operator = "gte"
+	OpLess           Operator = "lt"
+	OpLessOrEqual    Operator = "lte"
+)
+
+// AnyAction in a rule's actions matches every action
+const AnyAction Action = "*"
+
+// Policy is a set of declarative rules over the attributes of a request.
+// A request is allowed if an allow rule matches and no deny rule does.
+// Policies are created by ParsePolicy or LoadPolicy, which check them.
+//
+// Conditions may use these attributes:
+//
+//	subject.authenticated  bool     the caller is logged in
+//	subject.id             string
+//	subject.roles          strings
+//	subject.rank           number   2 for admins, 1 for support, otherwise 0
+//	subject.tenant         string   empty if the caller belongs to no tenant
+//	resource.exists        bool     false for actions such as create and list
+//	resource.self          bool     the resource is the caller's own record
+//	resource.id            string
+//	resource.status        string
+//	resource.roles         strings
+//	resource.rank          number
+//	resource.tenant        string
+//	env.hour               number   0-23, in the policy's time zone
+//	env.weekday            string   "monday" to "sunday"
+type Policy struct {
+	// TimeZone is the IANA time zone env.hour and env.weekday are read in; UTC if empty
+	TimeZone string `json:"time_zone"`
+	Rules    []Rule `json:"rules"`
+
+	location *time.Location
+}
+
+// Rule applies its effect to the listed actions when all of its conditions hold
+type Rule struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	Effect      Effect      `json:"effect"`
+	Actions     []Action    `json:"actions"`
+	Conditions  []Condition `json:"conditions"`
+}
+
+// Condition compares an attribute with either a literal Value or the value
+// of the attribute named by ValueFrom
+type Condition struct {
+	Attribute string   `json:"attribute"`
+	Operator  Operator `json:"operator"`
+	Value     any      `json:"value,omitempty"`
+	ValueFrom string   `json:"value_from,omitempty"`
+}
+
+// attributeKind is the type of an attribute's value
+type attributeKind string
+
+const (
+	kindString  attributeKind = "string"
+	kindNumber  attributeKind = "number"
+	kindBool    attributeKind = "bool"
+	kindStrings attributeKind = "strings"
+	kindList    attributeKind = "list" // only ever a literal, the operand of in
+)
+
+// attributeKinds lists every attribute a condition may use
+var attributeKinds = map[string]attributeKind{
+	"subject.authenticated": kindBool,
+	"subject.id":            kindString,
+	"subject.roles":         kindStrings,
+	"subject.rank":          kindNumber,
+	"subject.tenant":        kindString,
+	"resource.exists":       kindBool,
+	"resource.self":         kindBool,
+	"resource.id":           kindString,
+	"resource.status":       kindString,
+	"resource.roles":        kindStrings,
+	"resource.rank":         kindNumber,
+	"resource.tenant":       kindString,
+	"env.hour":              kindNumber,
+	"env.weekday":           kindString,
+}
+
+// LoadPolicy reads and checks the JSON policy in the file at path
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy decodes and checks a JSON policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var policy Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if err := policy.compile(); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// compile checks the policy and resolves its time zone
+func (p *Policy) compile() error {
+	location, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return fmt.Errorf("time zone: %w", err)
+	}
+	p.location = location
+
+	if len(p.Rules) == 0 {
+		return errors.New("no rules")
+	}
+	ids := make(map[string]bool, len(p.Rules))
+	for _, rule := range p.Rules {
+		if rule.ID == "" {
+			return errors.New("rule without an id")
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("rule %q: duplicate id", rule.ID)
+		}
+		ids[rule.ID] = true
+
+		if err := rule.check(); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.ID, err)
+		}
+	}
+	return nil
+}
+
+// check validates the rule's effect, actions and conditions
+func (r Rule) check() error {
+	if r.Effect != EffectAllow && r.Effect != EffectDeny {
+		return fmt.Errorf("effect must be %q or %q", EffectAllow, EffectDeny)
+	}
+	if len(r.Actions) == 0 {
+		return errors.New("no actions")
+	}
+	for _, action := range r.Actions {
+		if action == AnyAction {
+			continue
+		}
+		if err := action.Validate(); err != nil {
+			return err
+		}
+	}
+	for i, condition := range r.Conditions {
+		if err := condition.check(); err != nil {
+			return fmt.Errorf("condition %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// check validates that the condition compares values of matching kinds
+func (c Condition) check() error {
+	kind, ok := attributeKinds[c.Attribute]
+	if !ok {
+		return fmt.Errorf("unknown attribute %q", c.Attribute)
+	}
+
+	var operand attributeKind
+	switch {
+	case c.ValueFrom != "" && c.Value != nil:
+		return errors.New("both value and value_from are set")
+	case c.ValueFrom != "":
+		if operand, ok = attributeKinds[c.ValueFrom]; !ok {
+			return fmt.Errorf("unknown attribute %q", c.ValueFrom)
+		}
+	case c.Value != nil:
+		var err error
+		if operand, err = literalKind(c.Attribute, c.Value); err != nil {
+			return err
+		}
+	default:
+		return errors.New("neither value nor value_from is set")
+	}
+
+	var valid bool
+	switch c.Operator {
+	case OpEquals, OpNotEquals:
+		valid = kind != kindStrings && operand == kind
+	case OpIn, OpNotIn:
+		valid = (kind == kindString || kind == kindNumber) && operand == kindList && c.listOf(kind)
+	case OpContains, OpNotContains:
+		valid = kind == kindStrings && operand == kindString
+	case OpGreater, OpGreaterOrEqual, OpLess, OpLessOrEqual:
+		valid = kind == kindNumber && operand == kindNumber
+	default:
+		return fmt.Errorf("unknown operator %q", c.Operator)
+	}
+	if !valid {
+		return fmt.Errorf("%s %s can't be compared with a %s", c.Attribute, c.Operator, operand)
+	}
+	return nil
+}
+
+// listOf reports whether every element of the condition's literal list is of kind
+func (c Condition) listOf(kind attributeKind) bool {
+	for _, element := range c.Value.([]any) {
+		if elementKind, _ := literalKind(c.Attribute, element); elementKind != kind {
+			return false
+		}
+	}
+	return true
+}
+
+// literalKind returns the kind of a literal decoded from JSON. Strings
+// compared with an enumerated attribute must be one of its values, so that a
+// misspelt weekday or role is reported rather than never matching.
+func literalKind(attribute string, value any) (attributeKind, error) {
+	switch v := value.(type) {
+	case string:
+		if err := checkEnumerated(attribute, v); err != nil {
+			return "", err
+		}
+		return kindString, nil
+	case float64:
+		return kindNumber, nil
+	case bool:
+		return kindBool, nil
+	case []any:
+		for _, element := range v {
+			if _, err := literalKind(attribute, element); err != nil {
+				return "", err
+			}
+		}
+		return kindList, nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// checkEnumerated checks that value is a possible value of attribute
+func checkEnumerated(attribute string, value string) error {
+	switch attribute {
+	case "subject.roles", "resource.roles":
+		return entity.Role(value).Validate()
+	case "resource.status":
+		return entity.UserStatus(value).Validate()
+	case "env.weekday":
+		if !slices.Contains(weekdays, value) {
+			return fmt.Errorf("invalid weekday %q", value)
+		}
+	}
+	return nil
+}
+
+// weekdays are the values of env.weekday, indexed by time.Weekday
+var weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// String returns the condition as text, e.g. "env.hour gte 9"
+func (c Condition) String() string {
+	operand := c.ValueFrom
+	if operand == "" {
+		literal, _ := json.Marshal(c.Value)
+		operand = string(literal)
+	}
+	return strings.Join([]string{c.Attribute, string(c.Operator), operand}, " ")
+}
@@ -0,0 +1,167 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+	}{
+		{"not json", `rules: []`, "failed to parse policy"},
+		{"unknown field", `{"rules": [], "default": "allow"}`, "unknown field"},
+		{"no rules", `{"rules": []}`, "no rules"},
+		{"bad time zone", `{"time_zone": "Mars/Olympus", "rules": [{"id": "a", "effect": "allow", "actions": ["*"]}]}`, "time zone"},
+		{"missing id", `{"rules": [{"effect": "allow", "actions": ["*"]}]}`, "without an id"},
+		{"duplicate id", `{"rules": [{"id": "a", "effect": "allow", "actions": ["*"]}, {"id": "a", "effect": "deny", "actions": ["*"]}]}`, "duplicate id"},
+		{"bad effect", `{"rules": [{"id": "a", "effect": "maybe", "actions": ["*"]}]}`, "effect must be"},
+		{"no actions", `{"rules": [{"id": "a", "effect": "allow"}]}`, "no actions"},
+		{"unknown action", `{"rules": [{"id": "a", "effect": "allow", "actions": ["user.fly"]}]}`, "unknown action"},
+		{"unknown attribute", cond(`{"attribute": "subject.age", "operator": "gt", "value": 18}`), "unknown attribute"},
+		{"unknown operator", cond(`{"attribute": "env.hour", "operator": "approx", "value": 9}`), "unknown operator"},
+		{"no operand", cond(`{"attribute": "env.hour", "operator": "gt"}`), "neither value nor value_from"},
+		{"two operands", cond(`{"attribute": "env.hour", "operator": "gt", "value": 9, "value_from": "subject.rank"}`), "both value and value_from"},
+		{"kind mismatch", cond(`{"attribute": "env.hour", "operator": "gt", "value": "nine"}`), "can't be compared"},
+		{"contains on a string", cond(`{"attribute": "subject.tenant", "operator": "contains", "value": "a"}`), "can't be compared"},
+		{"in with mixed list", cond(`{"attribute": "env.hour", "operator": "in", "value": [9, "10"]}`), "can't be compared"},
+		{"misspelt weekday", cond(`{"attribute": "env.weekday", "operator": "equals", "value": "Monday"}`), "invalid weekday"},
+		{"unknown role", cond(`{"attribute": "subject.roles", "operator": "contains", "value": "root"}`), "invalid role"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tt.policy))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParsePolicy() error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// cond returns a policy with a single rule that has condition
+func cond(condition string) string {
+	return `{"rules": [{"id": "a", "effect": "allow", "actions": ["*"], "conditions": [` + condition + `]}]}`
+}
+
+func TestLoadPolicy_Missing(t *testing.T) {
+	if _, err := LoadPolicy("testdata/missing.json"); err == nil {
+		t.Error("LoadPolicy() of a missing file expected an error")
+	}
+}
+
+func TestPolicyAuthorizer_Explain(t *testing.T) {
+	policy, err := LoadPolicy("testdata/policy.json")
+	if err != nil {
+		t.Fatalf("LoadPolicy() unexpected error: %v", err)
+	}
+	authorizer := NewPolicyAuthorizer(policy)
+
+	belgrade, _ := time.LoadLocation("Europe/Belgrade")
+	businessHours := Environment{Time: time.Date(2024, 5, 8, 10, 0, 0, 0, belgrade)} // a Wednesday
+	evening := Environment{Time: time.Date(2024, 5, 8, 20, 0, 0, 0, belgrade)}
+	weekend := Environment{Time: time.Date(2024, 5, 11, 10, 0, 0, 0, belgrade)}
+
+	alice := &entity.User{ID: "user_alice", Tenant: "acme"}
+	otherTenant := &entity.User{ID: "user_bob", Tenant: "globex"}
+	admin := &entity.User{ID: "user_admin", Tenant: "acme", Roles: []entity.Role{entity.RoleAdmin}}
+	noTenant := &entity.User{ID: "user_carol"}
+	support := SubjectOf(&entity.User{ID: "user_support", Tenant: "acme", Roles: []entity.Role{entity.RoleSupport}})
+	supportWithoutTenant := SubjectOf(&entity.User{ID: "user_dave", Roles: []entity.Role{entity.RoleSupport}})
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		action   Action
+		resource *entity.User
+		env      Environment
+		allowed  bool
+		rule     string
+	}{
+		{"anyone registers", Subject{}, ActionCreate, nil, evening, true, "register"},
+		{"anonymous read", Subject{}, ActionRead, alice, evening, false, ""},
+		{"self update", SubjectOf(alice), ActionUpdate, alice, evening, true, "self-service"},
+		{"self delete", SubjectOf(alice), ActionDelete, alice, evening, false, ""},
+		{"support reads another tenant", support, ActionRead, otherTenant, evening, true, "support-read"},
+		{"support edits own tenant in business hours", support, ActionSuspend, alice, businessHours, true, "support-edit-own-tenant"},
+		{"support edits own tenant in the evening", support, ActionSuspend, alice, evening, false, ""},
+		{"support edits own tenant at the weekend", support, ActionSuspend, alice, weekend, false, ""},
+		{"support edits another tenant", support, ActionSuspend, otherTenant, businessHours, false, ""},
+		{"support without a tenant edits a user without one", supportWithoutTenant, ActionSuspend, noTenant, businessHours, false, ""},
+		{"support edits an admin", support, ActionUpdate, admin, businessHours, false, "no-higher-role"},
+		{"admin deletes", SubjectOf(admin), ActionDelete, otherTenant, evening, true, "admins"},
+		{"admin moves a user to another tenant", SubjectOf(admin), ActionAssignTenant, alice, evening, true, "admins"},
+		{"support moves a user to another tenant", support, ActionAssignTenant, alice, businessHours, false, ""},
+		{"admin checks access", SubjectOf(admin), ActionCheckAccess, nil, evening, true, "admins"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := authorizer.Explain(context.Background(), tt.subject, tt.action, tt.resource, tt.env)
+			if decision.Allowed != tt.allowed || decision.Rule != tt.rule {
+				t.Errorf("Explain() = allowed %v by %q, want allowed %v by %q: %s",
+					decision.Allowed, decision.Rule, tt.allowed, tt.rule, decision.Reason)
+			}
+		})
+	}
+}
+
+func TestPolicyAuthorizer_ExplainsConditions(t *testing.T) {
+	policy, _ := LoadPolicy("testdata/policy.json")
+	authorizer := NewPolicyAuthorizer(policy)
+	support := SubjectOf(&entity.User{ID: "user_support", Tenant: "acme", Roles: []entity.Role{entity.RoleSupport}})
+	resource := &entity.User{ID: "user_bob", Tenant: "globex"}
+	env := Environment{Time: time.Date(2024, 5, 8, 18, 0, 0, 0, time.UTC)} // 20:00 in Belgrade
+
+	decision := authorizer.Explain(context.Background(), support, ActionSuspend, resource, env)
+	if decision.Allowed || decision.Reason != "no rule allows user.suspend" {
+		t.Fatalf("Explain() = %+v", decision)
+	}
+
+	// Only the rules for the action are evaluated, with every condition shown
+	var rules []string
+	for _, evaluation := range decision.Evaluations {
+		rules = append(rules, evaluation.Rule)
+	}
+	if strings.Join(rules, ",") != "admins,support-edit-own-tenant,no-higher-role" {
+		t.Fatalf("Explain() evaluated %v", rules)
+	}
+
+	var failed []string
+	for _, result := range decision.Evaluations[1].Conditions {
+		if !result.Matched {
+			failed = append(failed, result.Condition)
+		}
+	}
+	want := []string{"resource.tenant equals subject.tenant", "env.hour lt 17"}
+	if strings.Join(failed, "|") != strings.Join(want, "|") {
+		t.Errorf("failed conditions = %v, want %v", failed, want)
+	}
+	if tenant := decision.Evaluations[1].Conditions[1]; tenant.Actual != "globex" || tenant.Expected != "acme" {
+		t.Errorf("tenant condition = %+v", tenant)
+	}
+}
+
+func TestPolicyAuthorizer_Authorize(t *testing.T) {
+	policy, _ := ParsePolicy([]byte(`{"rules": [{"id": "a", "effect": "allow", "actions": ["user.read"],
+		"conditions": [{"attribute": "subject.authenticated", "operator": "equals", "value": true}]}]}`))
+	authorizer := NewPolicyAuthorizer(policy)
+	user := &entity.User{ID: "user_alice"}
+
+	if err := authorizer.Authorize(context.Background(), SubjectOf(user), ActionRead, user); err != nil {
+		t.Errorf("Authorize() unexpected error: %v", err)
+	}
+	if err := authorizer.Authorize(context.Background(), Subject{}, ActionRead, user); !errors.Is(err, ErrAuthenticationRequired) {
+		t.Errorf("Authorize() anonymously expected ErrAuthenticationRequired, got: %v", err)
+	}
+	if err := authorizer.Authorize(context.Background(), SubjectOf(user), ActionDelete, user); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Authorize() of an action no rule allows expected ErrForbidden, got: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Grants lists the actions each kind of caller may perform
+type Grants struct {
+	// Anyone may perform these, even without authenticating
+	Anyone []Action
+	// Self may be performed by a user on their own record
+	Self []Action
+	// Roles may be performed by holders of the role on any user
+	Roles map[entity.Role][]Action
+}
+
+// DefaultGrants let anyone register, users read and update their own
+// record, support staff look after any user short of closing or deleting
+// their account, and admins do everything. Role grants never allow changing
+// a user with a higher role than the caller's, so support can't suspend an
+// admin.
+var DefaultGrants = Grants{
+	Anyone: []Action{ActionCreate},
+	Self:   []Action{ActionRead, ActionReadHistory, ActionUpdate, ActionChangePassword},
+	Roles: map[entity.Role][]Action{
+		entity.RoleAdmin: AllActions,
+		entity.RoleSupport: {
+			ActionRead, ActionList, ActionReadHistory, ActionUpdate,
+			ActionActivate, ActionSuspend, ActionReactivate,
+		},
+	},
+}
+
+// changeActions modify a user; roles don't grant them on users whose role is
+// as high as the caller's, except to admins
+var changeActions = []Action{
+	ActionUpdate, ActionChangePassword, ActionActivate, ActionSuspend, ActionReactivate,
+	ActionDeactivate, ActionDelete, ActionRestore, ActionAssignRoles, ActionAssignTenant,
+}
+
+// RoleAuthorizer is an Authorizer that grants actions by role, plus the
+// actions any user may perform on their own record
+type RoleAuthorizer struct {
+	grants Grants
+}
+
+// NewRoleAuthorizer creates a RoleAuthorizer with the given grants
+func NewRoleAuthorizer(grants Grants) *RoleAuthorizer {
+	return &RoleAuthorizer{
+		grants: grants,
+	}
+}
+
+// Authorize allows action if anyone may perform it, if resource is the
+// subject's own record and self may perform it, or if one of the subject's
+// roles grants it and it doesn't change a user with a role as high as the
+// subject's
+func (a *RoleAuthorizer) Authorize(ctx context.Context, subject Subject, action Action, resource *entity.User) error {
+	if a.Allowed(subject, action, resource) {
+		return nil
+	}
+	return Deny(subject, action)
+}
+
+// Allowed reports whether the grants allow subject to perform action on resource
+func (a *RoleAuthorizer) Allowed(subject Subject, action Action, resource *entity.User) bool {
+	_, ok := a.grant(subject, action, resource)
+	return ok
+}
+
+// Explain names the grant that allows action, if any. Role grants don't
+// depend on the environment.
+func (a *RoleAuthorizer) Explain(ctx context.Context, subject Subject, action Action, resource *entity.User, env Environment) Decision {
+	grant, ok := a.grant(subject, action, resource)
+	if !ok && outranked(subject, action, resource) {
+		return Decision{Reason: fmt.Sprintf("%s is not granted on a user with a role as high as the caller's", action)}
+	}
+	if !ok {
+		return Decision{Reason: fmt.Sprintf("%s is not granted to the caller", action)}
+	}
+	return Decision{Allowed: true, Rule: grant, Reason: fmt.Sprintf("%s is granted to %s", action, grant)}
+}
+
+// grant returns the name of the grant that allows subject to perform action
+// on resource: "anyone", "self" or "role:<role>"
+func (a *RoleAuthorizer) grant(subject Subject, action Action, resource *entity.User) (string, bool) {
+	if slices.Contains(a.grants.Anyone, action) {
+		return "anyone", true
+	}
+	if subject.Owns(resource) && slices.Contains(a.grants.Self, action) {
+		return "self", true
+	}
+	if outranked(subject, action, resource) {
+		return "", false
+	}
+	for _, role := range subject.Roles {
+		if slices.Contains(a.grants.Roles[role], action) {
+			return "role:" + role.String(), true
+		}
+	}
+	return "", false
+}
+
+// outranked reports whether action would change a user whose role is as high
+// as subject's. Admins may change each other, since no role is above theirs.
+func outranked(subject Subject, action Action, resource *entity.User) bool {
+	if resource == nil || !slices.Contains(changeActions, action) {
+		return false
+	}
+	subjectRank, resourceRank := rank(subject.Roles), rank(resource.Roles)
+	return resourceRank > subjectRank || (resourceRank == subjectRank && subjectRank < topRank)
+}
@@ -0,0 +1,99 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+func TestRoleAuthorizer(t *testing.T) {
+	authorizer := NewRoleAuthorizer(DefaultGrants)
+	alice := &entity.User{ID: "user_alice"}
+	bob := &entity.User{ID: "user_bob"}
+	root := &entity.User{ID: "user_root", Roles: []entity.Role{entity.RoleAdmin}}
+	helpdesk := &entity.User{ID: "user_helpdesk", Roles: []entity.Role{entity.RoleSupport}}
+
+	anonymous := Subject{}
+	self := Subject{UserID: alice.ID}
+	support := Subject{UserID: "user_support", Roles: []entity.Role{entity.RoleSupport}}
+	admin := Subject{UserID: "user_admin", Roles: []entity.Role{entity.RoleAdmin}}
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		action   Action
+		resource *entity.User
+		want     error
+	}{
+		{"anyone registers", anonymous, ActionCreate, nil, nil},
+		{"anonymous read", anonymous, ActionRead, alice, ErrAuthenticationRequired},
+		{"self read", self, ActionRead, alice, nil},
+		{"self update", self, ActionUpdate, alice, nil},
+		{"self change password", self, ActionChangePassword, alice, nil},
+		{"self delete", self, ActionDelete, alice, ErrForbidden},
+		{"self grants roles", self, ActionAssignRoles, alice, ErrForbidden},
+		{"self changes tenant", self, ActionAssignTenant, alice, ErrForbidden},
+		{"read another user", self, ActionRead, bob, ErrForbidden},
+		{"list without role", self, ActionList, nil, ErrForbidden},
+		{"support list", support, ActionList, nil, nil},
+		{"support suspend", support, ActionSuspend, bob, nil},
+		{"support delete", support, ActionDelete, bob, ErrForbidden},
+		{"support change password", support, ActionChangePassword, bob, ErrForbidden},
+		{"support changes tenant", support, ActionAssignTenant, bob, ErrForbidden},
+		{"support suspends admin", support, ActionSuspend, root, ErrForbidden},
+		{"support updates admin", support, ActionUpdate, root, ErrForbidden},
+		{"support reads admin", support, ActionRead, root, nil},
+		{"support updates support", support, ActionUpdate, helpdesk, ErrForbidden},
+		{"support suspends support", support, ActionSuspend, helpdesk, ErrForbidden},
+		{"support reads support", support, ActionRead, helpdesk, nil},
+		{"support updates self", Subject{UserID: helpdesk.ID, Roles: helpdesk.Roles}, ActionUpdate, helpdesk, nil},
+		{"admin delete", admin, ActionDelete, bob, nil},
+		{"admin assign roles", admin, ActionAssignRoles, bob, nil},
+		{"admin assign tenant", admin, ActionAssignTenant, bob, nil},
+		{"admin purge", admin, ActionPurge, nil, nil},
+		{"admin suspends admin", admin, ActionSuspend, root, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorizer.Authorize(context.Background(), tt.subject, tt.action, tt.resource)
+			if tt.want == nil && err != nil {
+				t.Errorf("Authorize() unexpected error: %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Authorize() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleAuthorizer_Explain(t *testing.T) {
+	authorizer := NewRoleAuthorizer(DefaultGrants)
+	alice := &entity.User{ID: "user_alice"}
+	support := Subject{UserID: "user_support", Roles: []entity.Role{entity.RoleSupport}}
+
+	tests := []struct {
+		subject Subject
+		action  Action
+		want    Decision
+	}{
+		{Subject{}, ActionCreate, Decision{Allowed: true, Rule: "anyone", Reason: "user.create is granted to anyone"}},
+		{Subject{UserID: alice.ID}, ActionRead, Decision{Allowed: true, Rule: "self", Reason: "user.read is granted to self"}},
+		{support, ActionSuspend, Decision{Allowed: true, Rule: "role:support", Reason: "user.suspend is granted to role:support"}},
+		{support, ActionDelete, Decision{Reason: "user.delete is not granted to the caller"}},
+	}
+
+	for _, tt := range tests {
+		got := authorizer.Explain(context.Background(), tt.subject, tt.action, alice, Environment{})
+		if got.Allowed != tt.want.Allowed || got.Rule != tt.want.Rule || got.Reason != tt.want.Reason {
+			t.Errorf("Explain(%s) = %+v, want %+v", tt.action, got, tt.want)
+		}
+	}
+	root := &entity.User{ID: "user_root", Roles: []entity.Role{entity.RoleAdmin}}
+	got := authorizer.Explain(context.Background(), support, ActionSuspend, root, Environment{})
+	if want := "user.suspend is not granted on a user with a role as high as the caller's"; got.Allowed || got.Reason != want {
+		t.Errorf("Explain(%s) of an admin = %+v, want reason %q", ActionSuspend, got, want)
+	}
+}
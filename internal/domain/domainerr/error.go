@@ -0,0 +1,114 @@
+// Package domainerr defines the error type shared by the domain packages.
+// Each error carries a kind, which tells a caller how to react to it, and a
+// stable machine-readable code, which tells it exactly what went wrong.
+// Transports map kinds to their own status codes instead of matching
+// individual errors.
+package domainerr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies an error by how a caller should react to it
+type Kind string
+
+// Error kinds
+const (
+	// KindInvalid means the request itself is malformed
+	KindInvalid Kind = "invalid"
+	// KindValidation means well-formed input breaks a domain rule
+	KindValidation Kind = "validation"
+	// KindNotFound means the addressed resource does not exist
+	KindNotFound Kind = "not_found"
+	// KindConflict means the operation clashes with the resource's state
+	KindConflict Kind = "conflict"
+	// KindPrecondition means the resource changed since the caller last read it
+	KindPrecondition Kind = "precondition_failed"
+	// KindUnauthenticated means the caller's identity is missing or not proven
+	KindUnauthenticated Kind = "unauthenticated"
+	// KindForbidden means the caller may not perform the operation
+	KindForbidden Kind = "forbidden"
+	// KindUnavailable means the operation is not supported by this deployment
+	KindUnavailable Kind = "unavailable"
+	// KindTimeout means the operation did not finish in time
+	KindTimeout Kind = "timeout"
+	// KindInternal means something went wrong that the caller can't fix
+	KindInternal Kind = "internal"
+)
+
+// FieldError describes why a single input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is a domain error. Two errors with the same code match each other
+// with errors.Is, so a sentinel still matches the more detailed errors
+// derived from it with Withf.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+// New creates an Error
+func New(kind Kind, code, message string, fields ...FieldError) *Error {
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: message,
+		Fields:  fields,
+	}
+}
+
+// Validation creates a validation Error about a single field; the field
+// error shares the error's code and message
+func Validation(code, field, message string) *Error {
+	return New(KindValidation, code, message, FieldError{Field: field, Code: code, Message: message})
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is makes errors.Is report true for any Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Withf returns a copy of e whose message, and that of each field error,
+// is followed by the formatted detail
+func (e *Error) Withf(format string, args ...any) *Error {
+	detail := fmt.Sprintf(format, args...)
+
+	derived := *e
+	derived.Message = e.Message + ": " + detail
+	derived.Fields = make([]FieldError, len(e.Fields))
+	for i, field := range e.Fields {
+		field.Message = derived.Message
+		derived.Fields[i] = field
+	}
+	return &derived
+}
+
+// As returns the first Error in err's chain
+func As(err error) (*Error, bool) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+	return nil, false
+}
+
+// KindOf returns the kind of the first Error in err's chain, or KindInternal
+// if there is none
+func KindOf(err error) Kind {
+	if domainErr, ok := As(err); ok {
+		return domainErr.Kind
+	}
+	return KindInternal
+}
@@ -0,0 +1,50 @@
+package domainerr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestError_Is(t *testing.T) {
+	sentinel := Validation("weak_password", "password", "password is too weak")
+	other := New(KindConflict, "user_already_exists", "user already exists")
+
+	derived := sentinel.Withf("must contain a digit")
+	wrapped := fmt.Errorf("failed to create user: %w", derived)
+
+	if !errors.Is(wrapped, sentinel) {
+		t.Error("errors.Is() should match the sentinel a derived error came from")
+	}
+	if errors.Is(wrapped, other) {
+		t.Error("errors.Is() should not match an error with another code")
+	}
+	if derived.Error() != "password is too weak: must contain a digit" {
+		t.Errorf("Withf() message = %q", derived.Error())
+	}
+	if derived.Fields[0].Message != derived.Message || sentinel.Fields[0].Message != "password is too weak" {
+		t.Errorf("Withf() fields = %+v, sentinel fields = %+v", derived.Fields, sentinel.Fields)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	notFound := New(KindNotFound, "user_not_found", "user not found")
+
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"domain error", notFound, KindNotFound},
+		{"wrapped domain error", fmt.Errorf("failed to get user: %w", notFound), KindNotFound},
+		{"plain error", errors.New("connection refused"), KindInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != tt.want {
+				t.Errorf("KindOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
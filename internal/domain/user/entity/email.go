@@ -0,0 +1,146 @@
+package entity
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Email represents a user email address
+type Email string
+
+// emailRegex matches an address whose domain is already in ASCII (punycode) form
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// EmailProviderRule describes how a mail provider treats different spellings
+// of the same mailbox
+type EmailProviderRule struct {
+	// Domains the rule applies to, lowercase ASCII
+	Domains []string
+	// CanonicalDomain replaces any of Domains in the canonical form; empty keeps the domain
+	CanonicalDomain string
+	// IgnoreDots drops "." from the local part
+	IgnoreDots bool
+	// StripPlusTag drops everything from the first "+" in the local part
+	StripPlusTag bool
+}
+
+// GmailRule canonicalizes Gmail addresses, which ignore dots and plus-tags
+// and treat googlemail.com as gmail.com
+var GmailRule = EmailProviderRule{
+	Domains:         []string{"gmail.com", "googlemail.com"},
+	CanonicalDomain: "gmail.com",
+	IgnoreDots:      true,
+	StripPlusTag:    true,
+}
+
+// EmailNormalizer turns what a user typed into a display form and a canonical
+// form. The display form is trimmed and has a lowercased domain; the canonical
+// form is what uniqueness and lookups use. The zero value applies no provider rules.
+type EmailNormalizer struct {
+	Rules []EmailProviderRule
+}
+
+// DefaultEmailNormalizer is used when none is configured. It applies no
+// provider rules, since those treat addresses as equal that other providers
+// consider distinct.
+var DefaultEmailNormalizer = EmailNormalizer{}
+
+// Normalize validates raw and returns its display and canonical forms.
+// The canonical form lowercases the whole address, converts international
+// domains to punycode and applies the matching provider rule, if any.
+func (n EmailNormalizer) Normalize(raw string) (display Email, canonical Email, err error) {
+	trimmed := strings.TrimSpace(raw)
+
+	at := strings.LastIndex(trimmed, "@")
+	if at <= 0 {
+		return "", "", ErrInvalidEmail
+	}
+	local, domain := trimmed[:at], strings.ToLower(trimmed[at+1:])
+
+	asciiDomain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", "", ErrInvalidEmail
+	}
+
+	if !emailRegex.MatchString(local + "@" + asciiDomain) {
+		return "", "", ErrInvalidEmail
+	}
+
+	canonicalLocal := strings.ToLower(local)
+	if rule, ok := n.ruleFor(asciiDomain); ok {
+		canonicalLocal, asciiDomain = rule.apply(canonicalLocal, asciiDomain)
+	}
+
+	return Email(local + "@" + domain), Email(canonicalLocal + "@" + asciiDomain), nil
+}
+
+// Canonical returns the canonical form of raw, for lookups
+func (n EmailNormalizer) Canonical(raw string) (Email, error) {
+	_, canonical, err := n.Normalize(raw)
+	return canonical, err
+}
+
+// CanonicalDomain returns the domain of the canonical forms of addresses at
+// domain, e.g. to match users by domain. A leading "@" is ignored.
+func (n EmailNormalizer) CanonicalDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+
+	asciiDomain, err := idna.Lookup.ToASCII(domain)
+	if err != nil || asciiDomain == "" {
+		return "", ErrInvalidEmail
+	}
+
+	if rule, ok := n.ruleFor(asciiDomain); ok && rule.CanonicalDomain != "" {
+		asciiDomain = rule.CanonicalDomain
+	}
+	return asciiDomain, nil
+}
+
+// ruleFor returns the provider rule for an ASCII domain
+func (n EmailNormalizer) ruleFor(domain string) (EmailProviderRule, bool) {
+	for _, rule := range n.Rules {
+		for _, d := range rule.Domains {
+			if d == domain {
+				return rule, true
+			}
+		}
+	}
+	return EmailProviderRule{}, false
+}
+
+// apply canonicalizes a lowercased local part and ASCII domain
+func (r EmailProviderRule) apply(local string, domain string) (string, string) {
+	canonical := local
+	if r.StripPlusTag {
+		canonical, _, _ = strings.Cut(canonical, "+")
+	}
+	if r.IgnoreDots {
+		canonical = strings.ReplaceAll(canonical, ".", "")
+	}
+	// Keep the address usable if the rule would leave nothing, e.g. "+tag@gmail.com"
+	if canonical == "" {
+		canonical = local
+	}
+
+	if r.CanonicalDomain != "" {
+		domain = r.CanonicalDomain
+	}
+	return canonical, domain
+}
+
+// Validate validates email format
+func (e Email) Validate() error {
+	if e == "" || strings.TrimSpace(string(e)) != string(e) {
+		return ErrInvalidEmail
+	}
+
+	_, _, err := DefaultEmailNormalizer.Normalize(string(e))
+	return err
+}
+
+// String returns the email as string
+func (e Email) String() string {
+	return string(e)
+}
@@ -0,0 +1,138 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEmailNormalizer_Normalize(t *testing.T) {
+	tests := []struct {
+		name          string
+		raw           string
+		wantDisplay   Email
+		wantCanonical Email
+	}{
+		{"already canonical", "bob@example.com", "bob@example.com", "bob@example.com"},
+		{"mixed case", "Bob@Example.COM", "Bob@example.com", "bob@example.com"},
+		{"surrounding whitespace", "  bob@example.com\t", "bob@example.com", "bob@example.com"},
+		{"international domain", "info@Bücher.de", "info@bücher.de", "info@xn--bcher-kva.de"},
+		{"punycode domain", "info@xn--bcher-kva.de", "info@xn--bcher-kva.de", "info@xn--bcher-kva.de"},
+		{"gmail untouched by default", "J.Doe+news@gmail.com", "J.Doe+news@gmail.com", "j.doe+news@gmail.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			display, canonical, err := DefaultEmailNormalizer.Normalize(tt.raw)
+			if err != nil {
+				t.Fatalf("Normalize(%q) unexpected error: %v", tt.raw, err)
+			}
+			if display != tt.wantDisplay {
+				t.Errorf("Normalize(%q) display = %q, want %q", tt.raw, display, tt.wantDisplay)
+			}
+			if canonical != tt.wantCanonical {
+				t.Errorf("Normalize(%q) canonical = %q, want %q", tt.raw, canonical, tt.wantCanonical)
+			}
+		})
+	}
+}
+
+func TestEmailNormalizer_CanonicalDomain(t *testing.T) {
+	tests := []struct {
+		normalizer EmailNormalizer
+		domain     string
+		want       string
+	}{
+		{DefaultEmailNormalizer, "Example.COM", "example.com"},
+		{DefaultEmailNormalizer, "@example.com", "example.com"},
+		{DefaultEmailNormalizer, "Bücher.de", "xn--bcher-kva.de"},
+		{DefaultEmailNormalizer, "xn--bcher-kva.de", "xn--bcher-kva.de"},
+		{DefaultEmailNormalizer, "googlemail.com", "googlemail.com"},
+		{EmailNormalizer{Rules: []EmailProviderRule{GmailRule}}, "googlemail.com", "gmail.com"},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.normalizer.CanonicalDomain(tt.domain)
+		if err != nil || got != tt.want {
+			t.Errorf("CanonicalDomain(%q) = %q, %v, want %q", tt.domain, got, err, tt.want)
+		}
+	}
+
+	if _, err := DefaultEmailNormalizer.CanonicalDomain("bad domain"); !errors.Is(err, ErrInvalidEmail) {
+		t.Errorf("CanonicalDomain() of an invalid domain expected ErrInvalidEmail, got: %v", err)
+	}
+}
+
+func TestEmailNormalizer_Invalid(t *testing.T) {
+	invalid := []string{"", "   ", "plain", "@example.com", "bob@", "bob@example", "bo b@example.com", "bob@exa_mple.com"}
+
+	for _, raw := range invalid {
+		if _, _, err := DefaultEmailNormalizer.Normalize(raw); !errors.Is(err, ErrInvalidEmail) {
+			t.Errorf("Normalize(%q) expected ErrInvalidEmail, got: %v", raw, err)
+		}
+	}
+}
+
+func TestEmailNormalizer_GmailRule(t *testing.T) {
+	normalizer := EmailNormalizer{Rules: []EmailProviderRule{GmailRule}}
+
+	tests := []struct {
+		raw           string
+		wantCanonical Email
+	}{
+		{"J.Doe+news@gmail.com", "jdoe@gmail.com"},
+		{"jdoe@GoogleMail.com", "jdoe@gmail.com"},
+		{"j.d.o.e@gmail.com", "jdoe@gmail.com"},
+		{"+tag@gmail.com", "+tag@gmail.com"},
+		{"j.doe+news@example.com", "j.doe+news@example.com"},
+	}
+
+	for _, tt := range tests {
+		canonical, err := normalizer.Canonical(tt.raw)
+		if err != nil {
+			t.Fatalf("Canonical(%q) unexpected error: %v", tt.raw, err)
+		}
+		if canonical != tt.wantCanonical {
+			t.Errorf("Canonical(%q) = %q, want %q", tt.raw, canonical, tt.wantCanonical)
+		}
+	}
+}
+
+func TestNewUser_NormalizesEmail(t *testing.T) {
+	user, err := NewUser(" Bob@Example.com ", "Bob", testPassword)
+	if err != nil {
+		t.Fatalf("NewUser() unexpected error: %v", err)
+	}
+
+	if user.Email != "Bob@example.com" {
+		t.Errorf("NewUser() Email = %q, want display form", user.Email)
+	}
+	if user.CanonicalEmail != "bob@example.com" {
+		t.Errorf("NewUser() CanonicalEmail = %q", user.CanonicalEmail)
+	}
+
+	if err := user.Update("BOB@example.com", "Bob"); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if user.Email != "BOB@example.com" || user.CanonicalEmail != "bob@example.com" {
+		t.Errorf("Update() Email = %q, CanonicalEmail = %q", user.Email, user.CanonicalEmail)
+	}
+}
+
+func TestNewUser_WithEmailNormalizer(t *testing.T) {
+	normalizer := EmailNormalizer{Rules: []EmailProviderRule{GmailRule}}
+
+	user, err := NewUser("J.Doe+x@gmail.com", "Jane", testPassword, WithEmailNormalizer(normalizer))
+	if err != nil {
+		t.Fatalf("NewUser() unexpected error: %v", err)
+	}
+	if user.CanonicalEmail != "jdoe@gmail.com" {
+		t.Errorf("NewUser() CanonicalEmail = %q", user.CanonicalEmail)
+	}
+
+	if err := user.Update("jane.doe@googlemail.com", "Jane"); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if user.CanonicalEmail != "janedoe@gmail.com" {
+		t.Errorf("Update() CanonicalEmail = %q, normalizer not kept", user.CanonicalEmail)
+	}
+}
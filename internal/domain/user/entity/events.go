@@ -0,0 +1,261 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+)
+
+// Event is something that happened to a user. Events are recorded on the
+// aggregate as it changes and collected with PullEvents once the change is stored.
+type Event interface {
+	// EventID is unique per event; redelivered events keep their ID
+	EventID() string
+	// EventName identifies the kind of event, e.g. "user.registered"
+	EventName() string
+	// AggregateID is the user the event happened to
+	AggregateID() UserID
+	// OccurredAt is when the change was made
+	OccurredAt() time.Time
+}
+
+// Event names
+const (
+	EventUserRegistered      = "user.registered"
+	EventUserEmailChanged    = "user.email_changed"
+	EventUserRenamed         = "user.renamed"
+	EventUserPasswordChanged = "user.password_changed"
+	EventUserStatusChanged   = "user.status_changed"
+	EventUserDeleted         = "user.deleted"
+	EventUserRestored        = "user.restored"
+	EventUserRolesChanged    = "user.roles_changed"
+	EventUserTenantChanged   = "user.tenant_changed"
+)
+
+// eventIDPrefix is prepended to every event ID
+const eventIDPrefix = "evt_"
+
+// eventIDs generates event IDs; like user IDs they sort by creation time.
+// They are timestamped with the event, not the generator's clock.
+var eventIDs = NewULIDGenerator(clock.System)
+
+// EventMeta holds the fields every user event carries
+type EventMeta struct {
+	// ID is unique per event, so consumers can recognize redeliveries
+	ID     string    `json:"id"`
+	UserID UserID    `json:"user_id"`
+	At     time.Time `json:"at"`
+}
+
+// EventID returns the event's unique ID
+func (m EventMeta) EventID() string {
+	return m.ID
+}
+
+// AggregateID returns the user the event happened to
+func (m EventMeta) AggregateID() UserID {
+	return m.UserID
+}
+
+// OccurredAt returns when the change was made
+func (m EventMeta) OccurredAt() time.Time {
+	return m.At
+}
+
+// UserRegistered is recorded when a user is created
+type UserRegistered struct {
+	EventMeta
+	Email          Email  `json:"email"`
+	CanonicalEmail Email  `json:"canonical_email"`
+	Name           string `json:"name"`
+	// PasswordHash is the encoded hash, never the password itself. It is
+	// neither stored with the event nor published; see WithoutSecrets.
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// EventName returns EventUserRegistered
+func (UserRegistered) EventName() string { return EventUserRegistered }
+
+// UserEmailChanged is recorded when a user's email changes
+type UserEmailChanged struct {
+	EventMeta
+	OldEmail       Email `json:"old_email"`
+	NewEmail       Email `json:"new_email"`
+	CanonicalEmail Email `json:"canonical_email"`
+}
+
+// EventName returns EventUserEmailChanged
+func (UserEmailChanged) EventName() string { return EventUserEmailChanged }
+
+// UserRenamed is recorded when a user's name changes
+type UserRenamed struct {
+	EventMeta
+	OldName string `json:"old_name"`
+	NewName string `json:"new_name"`
+}
+
+// EventName returns EventUserRenamed
+func (UserRenamed) EventName() string { return EventUserRenamed }
+
+// UserPasswordChanged is recorded when a user's password is replaced.
+// It carries the new encoded hash, never the password itself, which is
+// neither stored with the event nor published; see WithoutSecrets.
+type UserPasswordChanged struct {
+	EventMeta
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// EventName returns EventUserPasswordChanged
+func (UserPasswordChanged) EventName() string { return EventUserPasswordChanged }
+
+// UserStatusChanged is recorded for every status transition
+type UserStatusChanged struct {
+	EventMeta
+	From   UserStatus `json:"from"`
+	To     UserStatus `json:"to"`
+	Reason string     `json:"reason"`
+	Actor  string     `json:"actor"`
+}
+
+// EventName returns EventUserStatusChanged
+func (UserStatusChanged) EventName() string { return EventUserStatusChanged }
+
+// UserDeleted is recorded when a user is soft-deleted, after the
+// corresponding UserStatusChanged
+type UserDeleted struct {
+	EventMeta
+	Reason string `json:"reason"`
+	Actor  string `json:"actor"`
+}
+
+// EventName returns EventUserDeleted
+func (UserDeleted) EventName() string { return EventUserDeleted }
+
+// UserRestored is recorded when a soft delete is undone, after the
+// corresponding UserStatusChanged
+type UserRestored struct {
+	EventMeta
+	Reason string `json:"reason"`
+	Actor  string `json:"actor"`
+}
+
+// EventName returns EventUserRestored
+func (UserRestored) EventName() string { return EventUserRestored }
+
+// UserRolesChanged is recorded when a user's roles are replaced
+type UserRolesChanged struct {
+	EventMeta
+	OldRoles []Role `json:"old_roles"`
+	NewRoles []Role `json:"new_roles"`
+	Actor    string `json:"actor"`
+}
+
+// EventName returns EventUserRolesChanged
+func (UserRolesChanged) EventName() string { return EventUserRolesChanged }
+
+// UserTenantChanged is recorded when a user is moved to another tenant
+type UserTenantChanged struct {
+	EventMeta
+	OldTenant Tenant `json:"old_tenant"`
+	NewTenant Tenant `json:"new_tenant"`
+	Actor     string `json:"actor"`
+}
+
+// EventName returns EventUserTenantChanged
+func (UserTenantChanged) EventName() string { return EventUserTenantChanged }
+
+// WithoutSecrets returns events with the password hashes cleared. Events
+// are stored and published in this form; an event store, which rebuilds
+// users from their events, keeps the current hash apart from them, see
+// PasswordHashIn.
+func WithoutSecrets(events []Event) []Event {
+	public := make([]Event, len(events))
+	for i, event := range events {
+		switch e := event.(type) {
+		case UserRegistered:
+			e.PasswordHash = ""
+			event = e
+		case UserPasswordChanged:
+			e.PasswordHash = ""
+			event = e
+		}
+		public[i] = event
+	}
+	return public
+}
+
+// PasswordHashIn returns the password hash set by the last of events that
+// sets one, and whether any does
+func PasswordHashIn(events []Event) (string, bool) {
+	var (
+		hash  string
+		found bool
+	)
+	for _, event := range events {
+		switch e := event.(type) {
+		case UserRegistered:
+			hash, found = e.PasswordHash, true
+		case UserPasswordChanged:
+			hash, found = e.PasswordHash, true
+		}
+	}
+	return hash, found
+}
+
+// record adds an event to the user's pending events
+func (u *User) record(event Event) {
+	u.events = append(u.events, event)
+}
+
+// meta returns the EventMeta for a new event happening at at
+func (u *User) meta(at time.Time) (EventMeta, error) {
+	id, err := eventIDs.next(at)
+	if err != nil {
+		return EventMeta{}, fmt.Errorf("failed to generate event ID: %w", err)
+	}
+	return EventMeta{ID: eventIDPrefix + id, UserID: u.ID, At: at}, nil
+}
+
+// Events returns the events recorded since the last PullEvents, oldest first
+func (u *User) Events() []Event {
+	return append([]Event(nil), u.events...)
+}
+
+// PullEvents returns the pending events and clears them
+func (u *User) PullEvents() []Event {
+	events := u.events
+	u.events = nil
+	return events
+}
+
+// eventDecoders decode the JSON form of each event, by event name
+var eventDecoders = map[string]func(data []byte) (Event, error){
+	EventUserRegistered:      decodeEvent[UserRegistered],
+	EventUserEmailChanged:    decodeEvent[UserEmailChanged],
+	EventUserRenamed:         decodeEvent[UserRenamed],
+	EventUserPasswordChanged: decodeEvent[UserPasswordChanged],
+	EventUserStatusChanged:   decodeEvent[UserStatusChanged],
+	EventUserDeleted:         decodeEvent[UserDeleted],
+	EventUserRestored:        decodeEvent[UserRestored],
+	EventUserRolesChanged:    decodeEvent[UserRolesChanged],
+	EventUserTenantChanged:   decodeEvent[UserTenantChanged],
+}
+
+// UnmarshalEvent decodes an event stored as JSON, given its EventName
+func UnmarshalEvent(name string, data []byte) (Event, error) {
+	decode, ok := eventDecoders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %q", name)
+	}
+	return decode(data)
+}
+
+func decodeEvent[E Event](data []byte) (Event, error) {
+	var event E
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode %s event: %w", event.EventName(), err)
+	}
+	return event, nil
+}
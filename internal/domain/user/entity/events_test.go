@@ -0,0 +1,142 @@
+package entity
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+)
+
+// eventNames returns the names of events, in order
+func eventNames(events []Event) []string {
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = event.EventName()
+	}
+	return names
+}
+
+func TestUser_RecordsEvents(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	user, _ := NewUser("test@example.com", "Test User", testPassword, WithClock(clock.NewFake(now)))
+
+	events := user.PullEvents()
+	if len(events) != 1 {
+		t.Fatalf("NewUser() recorded %v, want one event", eventNames(events))
+	}
+	registered, ok := events[0].(UserRegistered)
+	if !ok || registered.AggregateID() != user.ID || !registered.OccurredAt().Equal(now) || registered.Email != "test@example.com" {
+		t.Errorf("NewUser() recorded %+v", events[0])
+	}
+	if pending := user.PullEvents(); len(pending) != 0 {
+		t.Errorf("PullEvents() did not clear events, got %v", eventNames(pending))
+	}
+
+	_ = user.Update("updated@example.com", "Updated Name")
+	_ = user.Update("updated@example.com", "Updated Name") // no change, no events
+	_ = user.Activate("verified", "admin")
+	_ = user.ChangePassword(testPassword)
+	_ = user.Delete("requested", "admin")
+	_ = user.Restore("mistake", "admin")
+
+	want := []string{
+		EventUserEmailChanged,
+		EventUserRenamed,
+		EventUserStatusChanged,
+		EventUserPasswordChanged,
+		EventUserStatusChanged,
+		EventUserDeleted,
+		EventUserStatusChanged,
+		EventUserRestored,
+	}
+	got := eventNames(user.Events())
+	if len(got) != len(want) {
+		t.Fatalf("Events() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Events() = %v, want %v", got, want)
+		}
+	}
+
+	events = user.PullEvents()
+	if changed := events[0].(UserEmailChanged); changed.OldEmail != "test@example.com" || changed.NewEmail != "updated@example.com" {
+		t.Errorf("UserEmailChanged = %+v", changed)
+	}
+	if restored := events[6].(UserStatusChanged); restored.From != StatusDeleted || restored.To != StatusActive {
+		t.Errorf("restore UserStatusChanged = %+v", restored)
+	}
+}
+
+func TestUser_FailedChangeRecordsNoEvents(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	user.PullEvents()
+
+	_ = user.Update("invalid", "Test User")
+	_ = user.Suspend("", "admin")
+	_ = user.Restore("", "admin")
+
+	if events := user.PullEvents(); len(events) != 0 {
+		t.Errorf("failed changes recorded %v", eventNames(events))
+	}
+}
+
+func TestWithoutSecrets(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	_ = user.ChangePassword(testPassword)
+	events := user.Events()
+
+	public := WithoutSecrets(events)
+	if len(public) != len(events) {
+		t.Fatalf("WithoutSecrets() returned %d events, want %d", len(public), len(events))
+	}
+	for _, event := range public {
+		payload, _ := json.Marshal(event)
+		if strings.Contains(string(payload), "password_hash") || strings.Contains(string(payload), testPassword.Hash()) {
+			t.Errorf("WithoutSecrets() kept the password hash in %s", payload)
+		}
+	}
+	if registered := events[0].(UserRegistered); registered.PasswordHash != testPassword.Hash() {
+		t.Error("WithoutSecrets() cleared the hash of the original event")
+	}
+}
+
+func TestPasswordHashIn(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	changed, _ := PasswordHasher{Iterations: 1000, SaltLength: 16, KeyLength: 32}.Hash("Another-passw0rd")
+	_ = user.ChangePassword(changed)
+	_ = user.Update("test@example.com", "Renamed")
+
+	if hash, ok := PasswordHashIn(user.Events()); !ok || hash != changed.Hash() {
+		t.Errorf("PasswordHashIn() = %q, %v, want the changed hash", hash, ok)
+	}
+	if _, ok := PasswordHashIn(user.Events()[2:]); ok {
+		t.Error("PasswordHashIn() of a rename found a hash")
+	}
+}
+
+func TestUnmarshalEvent(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	_ = user.Delete("requested", "admin")
+
+	for _, event := range user.PullEvents() {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("Marshal(%s) unexpected error: %v", event.EventName(), err)
+		}
+
+		decoded, err := UnmarshalEvent(event.EventName(), data)
+		if err != nil {
+			t.Fatalf("UnmarshalEvent(%s) unexpected error: %v", event.EventName(), err)
+		}
+		if decoded.EventID() != event.EventID() || !decoded.OccurredAt().Equal(event.OccurredAt()) {
+			t.Errorf("UnmarshalEvent(%s) = %+v, want %+v", event.EventName(), decoded, event)
+		}
+	}
+
+	if _, err := UnmarshalEvent("user.unknown", []byte("{}")); err == nil {
+		t.Errorf("UnmarshalEvent() of unknown event expected error")
+	}
+}
@@ -0,0 +1,199 @@
+package entity
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+)
+
+// ErrInvalidUserID is returned when a string is not a well-formed user ID
+var ErrInvalidUserID = domainerr.New(domainerr.KindInvalid, "invalid_user_id", "invalid user ID")
+
+// userIDPrefix is prepended to every generated ID
+const userIDPrefix = "user_"
+
+// ulidLength is the length of the encoded ULID part of an ID
+const ulidLength = 26
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// legacyUserIDPattern matches IDs produced before ULIDs were introduced
+var legacyUserIDPattern = regexp.MustCompile(`^user_[0-9]{1,19}$`)
+
+// IDGenerator produces new user IDs
+type IDGenerator interface {
+	NewID() (UserID, error)
+}
+
+// DefaultIDGenerator is used by NewUser unless another generator is supplied
+var DefaultIDGenerator IDGenerator = NewULIDGenerator(clock.System)
+
+// ULIDGenerator produces lexicographically time-sortable IDs in the ULID format:
+// 48 bits of millisecond timestamp followed by 80 random bits. IDs generated
+// within the same millisecond increment the random part, so they stay unique
+// and ordered even under concurrent use.
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	clock   clock.Clock
+	entropy io.Reader
+	lastMs  uint64
+	lastRnd [10]byte
+}
+
+// NewULIDGenerator creates a ULIDGenerator that timestamps IDs with c and
+// reads randomness from crypto/rand
+func NewULIDGenerator(c clock.Clock) *ULIDGenerator {
+	return &ULIDGenerator{clock: c, entropy: rand.Reader}
+}
+
+// NewID returns a new unique user ID. It fails only if no random bytes can be read.
+func (g *ULIDGenerator) NewID() (UserID, error) {
+	ulid, err := g.next(g.clock.Now())
+	if err != nil {
+		return "", err
+	}
+	return UserID(userIDPrefix + ulid), nil
+}
+
+// next returns the next encoded ULID, timestamped at
+func (g *ULIDGenerator) next(at time.Time) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(at.UnixMilli())
+	rnd := g.lastRnd
+
+	if ms <= g.lastMs {
+		// Same (or earlier, if the clock stepped back) millisecond:
+		// keep the last timestamp and increment the random part
+		ms = g.lastMs
+		if !incrementBytes(rnd[:]) {
+			// 2^80 IDs in one millisecond; move to the next one
+			ms++
+			if err := g.fillRandom(&rnd); err != nil {
+				return "", err
+			}
+		}
+	} else if err := g.fillRandom(&rnd); err != nil {
+		return "", err
+	}
+	g.lastMs, g.lastRnd = ms, rnd
+
+	return encodeULID(ms, rnd), nil
+}
+
+// fillRandom fills rnd from the generator's entropy source
+func (g *ULIDGenerator) fillRandom(rnd *[10]byte) error {
+	if _, err := io.ReadFull(g.entropy, rnd[:]); err != nil {
+		return fmt.Errorf("failed to read random bytes for ID: %w", err)
+	}
+	return nil
+}
+
+// SequentialIDGenerator produces predictable ULID-format IDs for tests.
+// The n-th ID encodes start plus n milliseconds and n as its random part.
+type SequentialIDGenerator struct {
+	mu    sync.Mutex
+	start time.Time
+	next  uint64
+}
+
+// NewSequentialIDGenerator creates a deterministic generator starting at start
+func NewSequentialIDGenerator(start time.Time) *SequentialIDGenerator {
+	return &SequentialIDGenerator{start: start}
+}
+
+// NewID returns the next ID in the sequence; it never fails
+func (g *SequentialIDGenerator) NewID() (UserID, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.start.UnixMilli()) + g.next
+
+	var rnd [10]byte
+	binary.BigEndian.PutUint64(rnd[2:], g.next)
+	g.next++
+
+	return UserID(userIDPrefix + encodeULID(ms, rnd)), nil
+}
+
+// ParseUserID validates s and returns it as a UserID
+func ParseUserID(s string) (UserID, error) {
+	id := UserID(s)
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Validate checks that the ID is a well-formed generated ID
+func (id UserID) Validate() error {
+	s := string(id)
+
+	if legacyUserIDPattern.MatchString(s) {
+		return nil
+	}
+
+	ulid, ok := strings.CutPrefix(s, userIDPrefix)
+	if !ok || len(ulid) != ulidLength {
+		return ErrInvalidUserID
+	}
+
+	// The first character only carries 3 bits of the 128-bit value
+	if ulid[0] > '7' {
+		return ErrInvalidUserID
+	}
+
+	for i := 0; i < len(ulid); i++ {
+		if strings.IndexByte(crockford, ulid[i]) < 0 {
+			return ErrInvalidUserID
+		}
+	}
+
+	return nil
+}
+
+// encodeULID encodes a 48-bit millisecond timestamp and 80 random bits
+// as 26 Crockford base32 characters
+func encodeULID(ms uint64, rnd [10]byte) string {
+	var raw [16]byte
+	binary.BigEndian.PutUint16(raw[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(raw[2:6], uint32(ms))
+	copy(raw[6:], rnd[:])
+
+	// 26 characters hold 130 bits, so the value is read with two leading zero bits
+	var out [ulidLength]byte
+	for i := range out {
+		var value byte
+		for j := 0; j < 5; j++ {
+			value <<= 1
+			bit := i*5 + j - 2
+			if bit >= 0 && raw[bit/8]&(0x80>>(bit%8)) != 0 {
+				value |= 1
+			}
+		}
+		out[i] = crockford[value]
+	}
+
+	return string(out[:])
+}
+
+// incrementBytes adds one to b as a big-endian number, reporting false on overflow
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
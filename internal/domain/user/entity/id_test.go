@@ -0,0 +1,210 @@
+package entity
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+)
+
+// mustNewID returns generator's next ID, failing the test on error
+func mustNewID(t *testing.T, generator IDGenerator) UserID {
+	t.Helper()
+	id, err := generator.NewID()
+	if err != nil {
+		t.Fatalf("NewID() unexpected error: %v", err)
+	}
+	return id
+}
+
+func TestULIDGenerator_NewID(t *testing.T) {
+	generator := NewULIDGenerator(clock.System)
+
+	const count = 10000
+	ids := make([]UserID, count)
+	for i := range ids {
+		ids[i] = mustNewID(t, generator)
+	}
+
+	seen := make(map[UserID]struct{}, count)
+	for i, id := range ids {
+		if err := id.Validate(); err != nil {
+			t.Fatalf("NewID() produced invalid ID %q: %v", id, err)
+		}
+		if _, dup := seen[id]; dup {
+			t.Fatalf("NewID() produced duplicate ID %q", id)
+		}
+		seen[id] = struct{}{}
+
+		if i > 0 && ids[i-1] >= id {
+			t.Fatalf("NewID() IDs not increasing: %q then %q", ids[i-1], id)
+		}
+	}
+}
+
+func TestULIDGenerator_UsesClock(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	generator := NewULIDGenerator(clock.NewFake(at))
+
+	id := mustNewID(t, generator)
+
+	// The first 10 characters of a ULID encode its millisecond timestamp
+	want := encodeULID(uint64(at.UnixMilli()), [10]byte{})[:10]
+	if got := strings.TrimPrefix(id.String(), userIDPrefix)[:10]; got != want {
+		t.Errorf("NewID() timestamp = %q, want %q", got, want)
+	}
+}
+
+func TestULIDGenerator_EntropyFailure(t *testing.T) {
+	readErr := errors.New("entropy exhausted")
+	generator := NewULIDGenerator(clock.System)
+	generator.entropy = iotest.ErrReader(readErr)
+
+	id, err := generator.NewID()
+	if !errors.Is(err, readErr) {
+		t.Fatalf("NewID() error = %v, want %v", err, readErr)
+	}
+	if id != "" {
+		t.Errorf("NewID() = %q on error, want empty", id)
+	}
+
+	_, err = NewUser("test@example.com", "Test User", testPassword, WithIDGenerator(generator))
+	if !errors.Is(err, readErr) {
+		t.Errorf("NewUser() error = %v, want %v", err, readErr)
+	}
+}
+
+func TestULIDGenerator_Concurrent(t *testing.T) {
+	generator := NewULIDGenerator(clock.System)
+
+	const workers, perWorker = 16, 500
+	var (
+		mu  sync.Mutex
+		all = make(map[UserID]struct{}, workers*perWorker)
+		wg  sync.WaitGroup
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := make([]UserID, perWorker)
+			for i := range local {
+				id, err := generator.NewID()
+				if err != nil {
+					t.Errorf("NewID() unexpected error: %v", err)
+					return
+				}
+				local[i] = id
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, id := range local {
+				all[id] = struct{}{}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(all) != workers*perWorker {
+		t.Errorf("concurrent NewID() produced %d unique IDs, want %d", len(all), workers*perWorker)
+	}
+}
+
+func TestSequentialIDGenerator(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	first := NewSequentialIDGenerator(start)
+	second := NewSequentialIDGenerator(start)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		a, b := mustNewID(t, first), mustNewID(t, second)
+		if a != b {
+			t.Errorf("NewID() not deterministic: %q vs %q", a, b)
+		}
+		if err := a.Validate(); err != nil {
+			t.Errorf("NewID() produced invalid ID %q: %v", a, err)
+		}
+		ids = append(ids, string(a))
+	}
+
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("NewID() IDs not sorted: %v", ids)
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	// Boundary values: no bits set and all 128 bits set
+	var rnd [10]byte
+	if got := encodeULID(0, rnd); got != "00000000000000000000000000" {
+		t.Errorf("encodeULID(0) = %q", got)
+	}
+
+	for i := range rnd {
+		rnd[i] = 0xff
+	}
+	if got := encodeULID(1<<48-1, rnd); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("encodeULID(max) = %q", got)
+	}
+}
+
+func TestParseUserID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{"ulid", "user_01ARZ3NDEKTSV4RRFFQ69G5FAV", false},
+		{"legacy numeric", "user_1700000000000000000", false},
+		{"empty", "", true},
+		{"missing prefix", "01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{"wrong prefix", "usr_01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{"too short", "user_01ARZ3NDEKTSV4RRFFQ69G5FA", true},
+		{"too long", "user_01ARZ3NDEKTSV4RRFFQ69G5FAVX", true},
+		{"lowercase", "user_01arz3ndektsv4rrffq69g5fav", true},
+		{"excluded letter", "user_01ARZ3NDEKTSV4RRFFQ69G5FAU", true},
+		{"overflow", "user_81ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{"path traversal", "user_../../etc/passwd", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := ParseUserID(tt.id)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidUserID) {
+					t.Errorf("ParseUserID(%q) expected ErrInvalidUserID, got: %v", tt.id, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("ParseUserID(%q) unexpected error: %v", tt.id, err)
+			}
+			if id.String() != tt.id {
+				t.Errorf("ParseUserID(%q) = %q", tt.id, id)
+			}
+		})
+	}
+}
+
+func TestNewUser_WithIDGenerator(t *testing.T) {
+	generator := NewSequentialIDGenerator(time.Unix(0, 0))
+	expected := mustNewID(t, NewSequentialIDGenerator(time.Unix(0, 0)))
+
+	user, err := NewUser("test@example.com", "Test User", testPassword, WithIDGenerator(generator))
+	if err != nil {
+		t.Fatalf("NewUser() unexpected error: %v", err)
+	}
+
+	if user.ID != expected {
+		t.Errorf("NewUser() ID = %q, want %q", user.ID, expected)
+	}
+}
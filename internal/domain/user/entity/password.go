@@ -0,0 +1,266 @@
+package entity
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+)
+
+// Password errors
+var (
+	ErrEmptyPassword       = domainerr.Validation("empty_password", "password", "password cannot be empty")
+	ErrWeakPassword        = domainerr.Validation("weak_password", "password", "password does not meet strength requirements")
+	ErrInvalidPasswordHash = errors.New("invalid password hash")
+)
+
+// passwordAlgorithm identifies the hash format produced by PasswordHasher
+const passwordAlgorithm = "pbkdf2-sha256"
+
+// Password is a salted, slow hash of a user's password.
+// The plaintext is never stored; the zero value means "no password set".
+type Password struct {
+	hash string
+}
+
+// PasswordFromHash restores a Password from its encoded hash, e.g. when loading from storage
+func PasswordFromHash(encoded string) (Password, error) {
+	if encoded == "" {
+		return Password{}, nil
+	}
+
+	if _, err := parsePasswordHash(encoded); err != nil {
+		return Password{}, err
+	}
+
+	return Password{hash: encoded}, nil
+}
+
+// Hash returns the encoded hash for storage
+func (p Password) Hash() string {
+	return p.hash
+}
+
+// IsZero reports whether no password is set
+func (p Password) IsZero() bool {
+	return p.hash == ""
+}
+
+// String never reveals the hash, so passwords can't leak through logs
+func (p Password) String() string {
+	return "[REDACTED]"
+}
+
+// Verify reports whether plain matches the stored hash.
+// The comparison runs in constant time with respect to the derived key.
+func (p Password) Verify(plain string) bool {
+	parsed, err := parsePasswordHash(p.hash)
+	if err != nil {
+		return false
+	}
+
+	key, err := pbkdf2.Key(sha256.New, plain, parsed.salt, parsed.iterations, len(parsed.key))
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(key, parsed.key) == 1
+}
+
+// PasswordHasher derives password hashes with PBKDF2-HMAC-SHA256
+type PasswordHasher struct {
+	Iterations int
+	SaltLength int
+	KeyLength  int
+}
+
+// DefaultPasswordHasher follows the OWASP recommendation for PBKDF2-HMAC-SHA256
+var DefaultPasswordHasher = PasswordHasher{
+	Iterations: 600_000,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+// Hash derives a new salted hash of plain
+func (h PasswordHasher) Hash(plain string) (Password, error) {
+	if plain == "" {
+		return Password{}, ErrEmptyPassword
+	}
+
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return Password{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := pbkdf2.Key(sha256.New, plain, salt, h.Iterations, h.KeyLength)
+	if err != nil {
+		return Password{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	encoded := fmt.Sprintf("$%s$i=%d$%s$%s",
+		passwordAlgorithm,
+		h.Iterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+
+	return Password{hash: encoded}, nil
+}
+
+// NeedsRehash reports whether p was hashed with parameters other than h's,
+// so it should be re-hashed the next time the plaintext is available
+func (h PasswordHasher) NeedsRehash(p Password) bool {
+	parsed, err := parsePasswordHash(p.hash)
+	if err != nil {
+		return true
+	}
+
+	return parsed.iterations != h.Iterations ||
+		len(parsed.salt) != h.SaltLength ||
+		len(parsed.key) != h.KeyLength
+}
+
+// parsedPasswordHash holds the components of an encoded hash
+type parsedPasswordHash struct {
+	iterations int
+	salt       []byte
+	key        []byte
+}
+
+// parsePasswordHash decodes a hash in the form $pbkdf2-sha256$i=<iterations>$<salt>$<key>
+func parsePasswordHash(encoded string) (parsedPasswordHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != passwordAlgorithm {
+		return parsedPasswordHash{}, ErrInvalidPasswordHash
+	}
+
+	iterations, err := strconv.Atoi(strings.TrimPrefix(parts[2], "i="))
+	if err != nil || iterations <= 0 || !strings.HasPrefix(parts[2], "i=") {
+		return parsedPasswordHash{}, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(salt) == 0 {
+		return parsedPasswordHash{}, ErrInvalidPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(key) == 0 {
+		return parsedPasswordHash{}, ErrInvalidPasswordHash
+	}
+
+	return parsedPasswordHash{iterations: iterations, salt: salt, key: key}, nil
+}
+
+// PasswordPolicy defines the strength requirements for new passwords
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Denylist holds lowercase passwords that are rejected regardless of composition
+	Denylist map[string]struct{}
+}
+
+// DefaultPasswordPolicy returns the policy applied when none is configured
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     10,
+		MaxLength:     128,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: false,
+		Denylist:      NewPasswordDenylist(commonPasswords...),
+	}
+}
+
+// NewPasswordDenylist builds a denylist from the given passwords
+func NewPasswordDenylist(passwords ...string) map[string]struct{} {
+	denylist := make(map[string]struct{}, len(passwords))
+	for _, password := range passwords {
+		denylist[strings.ToLower(password)] = struct{}{}
+	}
+	return denylist
+}
+
+// Validate checks plain against the policy.
+// Returned errors match ErrWeakPassword or ErrEmptyPassword with errors.Is.
+func (p PasswordPolicy) Validate(plain string) error {
+	if plain == "" {
+		return ErrEmptyPassword
+	}
+
+	length := utf8.RuneCountInString(plain)
+	if p.MinLength > 0 && length < p.MinLength {
+		return ErrWeakPassword.Withf("must be at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return ErrWeakPassword.Withf("must be at most %d characters", p.MaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range plain {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	switch {
+	case p.RequireUpper && !hasUpper:
+		return ErrWeakPassword.Withf("must contain an uppercase letter")
+	case p.RequireLower && !hasLower:
+		return ErrWeakPassword.Withf("must contain a lowercase letter")
+	case p.RequireDigit && !hasDigit:
+		return ErrWeakPassword.Withf("must contain a digit")
+	case p.RequireSymbol && !hasSymbol:
+		return ErrWeakPassword.Withf("must contain a symbol")
+	}
+
+	if _, denied := p.Denylist[strings.ToLower(plain)]; denied {
+		return ErrWeakPassword.Withf("password is too common")
+	}
+
+	return nil
+}
+
+// NewPassword validates plain against policy and hashes it with hasher
+func NewPassword(plain string, policy PasswordPolicy, hasher PasswordHasher) (Password, error) {
+	if err := policy.Validate(plain); err != nil {
+		return Password{}, err
+	}
+
+	return hasher.Hash(plain)
+}
+
+// commonPasswords is a small list of frequently breached passwords that
+// still satisfy typical composition rules
+var commonPasswords = []string{
+	"Password1", "Password12", "Password123", "Password1234", "Password12345",
+	"Passw0rd", "Passw0rd1", "Passw0rd123", "P@ssw0rd", "P@ssw0rd1", "P@ssw0rd123",
+	"Qwerty123", "Qwerty1234", "Qwerty12345", "Qwertyuiop1", "Welcome1", "Welcome123",
+	"Welcome1234", "Letmein123", "Admin123", "Admin1234", "Admin12345", "Administrator1",
+	"Changeme1", "Changeme123", "Iloveyou1", "Iloveyou123", "Sunshine1", "Sunshine123",
+	"Princess1", "Football1", "Football123", "Baseball1", "Monkey123", "Dragon123",
+	"Master123", "Superman1", "Trustno1", "Abc123456", "Abcd1234", "Abcdef123",
+	"Summer2023", "Summer2024", "Summer2025", "Winter2023", "Winter2024", "Winter2025",
+	"Spring2024", "Autumn2024", "Company123", "Test12345", "Test123456", "Secret123",
+}
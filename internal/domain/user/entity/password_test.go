@@ -0,0 +1,123 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{"valid password", "Secret-passw0rd", nil},
+		{"empty password", "", ErrEmptyPassword},
+		{"too short", "Sh0rt", ErrWeakPassword},
+		{"too long", "Aa1" + strings.Repeat("x", 200), ErrWeakPassword},
+		{"missing uppercase", "secret-passw0rd", ErrWeakPassword},
+		{"missing lowercase", "SECRET-PASSW0RD", ErrWeakPassword},
+		{"missing digit", "Secret-password", ErrWeakPassword},
+		{"common password", "Password1234", ErrWeakPassword},
+		{"common password any case", "pASSWORD1234", ErrWeakPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Validate() unexpected error: %v", err)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() expected %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy_RequireSymbol(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	policy.RequireSymbol = true
+
+	if err := policy.Validate("Secretpassw0rd"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("Validate() expected ErrWeakPassword without symbol, got: %v", err)
+	}
+
+	if err := policy.Validate("Secret-passw0rd"); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+}
+
+func TestPasswordHasher_HashAndVerify(t *testing.T) {
+	password, err := testHasher.Hash("Secret-passw0rd")
+	if err != nil {
+		t.Fatalf("Hash() unexpected error: %v", err)
+	}
+
+	if strings.Contains(password.Hash(), "Secret-passw0rd") {
+		t.Errorf("Hash() stored the plaintext")
+	}
+
+	if !password.Verify("Secret-passw0rd") {
+		t.Errorf("Verify() rejected the correct password")
+	}
+
+	if password.Verify("Secret-passw0rd!") {
+		t.Errorf("Verify() accepted a wrong password")
+	}
+
+	other, _ := testHasher.Hash("Secret-passw0rd")
+	if other.Hash() == password.Hash() {
+		t.Errorf("Hash() produced identical hashes, salt is not random")
+	}
+}
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	password, _ := testHasher.Hash("Secret-passw0rd")
+
+	if testHasher.NeedsRehash(password) {
+		t.Errorf("NeedsRehash() true for current parameters")
+	}
+
+	stronger := testHasher
+	stronger.Iterations *= 2
+	if !stronger.NeedsRehash(password) {
+		t.Errorf("NeedsRehash() false after iterations changed")
+	}
+
+	if !testHasher.NeedsRehash(Password{}) {
+		t.Errorf("NeedsRehash() false for empty password")
+	}
+}
+
+func TestPasswordFromHash(t *testing.T) {
+	password, _ := testHasher.Hash("Secret-passw0rd")
+
+	restored, err := PasswordFromHash(password.Hash())
+	if err != nil {
+		t.Fatalf("PasswordFromHash() unexpected error: %v", err)
+	}
+	if !restored.Verify("Secret-passw0rd") {
+		t.Errorf("PasswordFromHash() restored password does not verify")
+	}
+
+	invalid := []string{"plaintext", "$md5$abc$def", "$pbkdf2-sha256$i=x$c2FsdA$a2V5", "$pbkdf2-sha256$i=10$$a2V5"}
+	for _, encoded := range invalid {
+		if _, err := PasswordFromHash(encoded); !errors.Is(err, ErrInvalidPasswordHash) {
+			t.Errorf("PasswordFromHash(%q) expected ErrInvalidPasswordHash, got: %v", encoded, err)
+		}
+	}
+}
+
+func TestPassword_StringRedacted(t *testing.T) {
+	password, _ := testHasher.Hash("Secret-passw0rd")
+
+	if password.String() != "[REDACTED]" {
+		t.Errorf("String() = %q, want redacted", password.String())
+	}
+}
@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEventMismatch is returned when an event cannot be applied to a user
+var ErrEventMismatch = errors.New("event does not apply to user")
+
+// Apply changes the user as event describes without recording it again.
+// Applying a user's events in order to a zero User, or to a snapshot of the
+// user followed by the events after it, rebuilds the user. Apply leaves
+// Version alone: it counts stored changes, which only the store knows.
+func (u *User) Apply(event Event) error {
+	_, isRegistration := event.(UserRegistered)
+	switch {
+	case u.ID == "" && !isRegistration:
+		return fmt.Errorf("%w: %s before %s", ErrEventMismatch, event.EventName(), EventUserRegistered)
+	case u.ID != "" && isRegistration:
+		return fmt.Errorf("%w: user %s is already registered", ErrEventMismatch, u.ID)
+	case u.ID != "" && event.AggregateID() != u.ID:
+		return fmt.Errorf("%w: event for user %s applied to user %s", ErrEventMismatch, event.AggregateID(), u.ID)
+	}
+
+	switch e := event.(type) {
+	case UserRegistered:
+		password, err := PasswordFromHash(e.PasswordHash)
+		if err != nil {
+			return fmt.Errorf("failed to restore password of user %s: %w", e.UserID, err)
+		}
+		u.ID = e.UserID
+		u.Email = e.Email
+		u.CanonicalEmail = e.CanonicalEmail
+		u.Name = e.Name
+		u.Password = password
+		u.Status = StatusPending
+		u.CreatedAt = e.At
+		u.UpdatedAt = e.At
+
+	case UserEmailChanged:
+		u.Email = e.NewEmail
+		u.CanonicalEmail = e.CanonicalEmail
+		u.UpdatedAt = e.At
+
+	case UserRenamed:
+		u.Name = e.NewName
+		u.UpdatedAt = e.At
+
+	case UserPasswordChanged:
+		password, err := PasswordFromHash(e.PasswordHash)
+		if err != nil {
+			return fmt.Errorf("failed to restore password of user %s: %w", e.UserID, err)
+		}
+		u.Password = password
+		u.UpdatedAt = e.At
+
+	case UserStatusChanged:
+		u.setStatus(StatusTransition{From: e.From, To: e.To, Reason: e.Reason, Actor: e.Actor, At: e.At})
+
+	case UserRolesChanged:
+		u.Roles = e.NewRoles
+		u.UpdatedAt = e.At
+
+	case UserTenantChanged:
+		u.Tenant = e.NewTenant
+		u.UpdatedAt = e.At
+
+	case UserDeleted, UserRestored:
+		// The state change is carried by the UserStatusChanged recorded before them
+
+	default:
+		return fmt.Errorf("%w: unknown event %s", ErrEventMismatch, event.EventName())
+	}
+
+	return nil
+}
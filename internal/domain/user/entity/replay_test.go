@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+)
+
+func TestUser_ApplyRebuildsUser(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	user, _ := NewUser("Test@Example.com", "Test User", testPassword, WithClock(fake))
+
+	fake.Advance(time.Hour)
+	_ = user.Update("updated@example.com", "Updated Name")
+	_ = user.Activate("verified", "admin")
+	fake.Advance(time.Hour)
+	newPassword, _ := testHasher.Hash("Other-passw0rd")
+	_ = user.ChangePassword(newPassword)
+	_ = user.SetRoles([]Role{RoleSupport}, "admin")
+	_ = user.SetTenant("acme", "admin")
+	_ = user.Delete("requested", "admin")
+	fake.Advance(time.Hour)
+	_ = user.Restore("mistake", "admin")
+
+	rebuilt := &User{}
+	for _, event := range user.PullEvents() {
+		if err := rebuilt.Apply(event); err != nil {
+			t.Fatalf("Apply(%s) error = %v", event.EventName(), err)
+		}
+	}
+	rebuilt.Version = user.Version
+	rebuilt.clock = user.clock
+	rebuilt.emailNormalizer = user.emailNormalizer
+
+	if !reflect.DeepEqual(rebuilt, user) {
+		t.Errorf("rebuilt user = %+v, want %+v", rebuilt, user)
+	}
+}
+
+func TestUser_ApplyRejectsMismatchedEvents(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	other, _ := NewUser("other@example.com", "Other User", testPassword)
+	registered := user.PullEvents()[0]
+	_ = other.Activate("verified", "admin")
+
+	tests := []struct {
+		name  string
+		user  *User
+		event Event
+	}{
+		{"change before registration", &User{}, other.Events()[1]},
+		{"registered twice", user, registered},
+		{"another user's event", user, other.Events()[1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.user.Apply(tt.event); !errors.Is(err, ErrEventMismatch) {
+				t.Errorf("Apply() error = %v, want ErrEventMismatch", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package entity
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+)
+
+// Role grants a user permissions over other users' records. Every user may
+// act on their own record; roles only ever add to that.
+type Role string
+
+// User roles
+const (
+	// RoleAdmin may perform every operation on every user
+	RoleAdmin Role = "admin"
+	// RoleSupport may look users up and help them with their accounts
+	RoleSupport Role = "support"
+)
+
+// ErrInvalidRole is returned for a role that is not one of the known roles
+var ErrInvalidRole = domainerr.Validation("invalid_role", "roles", "invalid role")
+
+// knownRoles lists every role, in the order roles are kept on a user
+var knownRoles = []Role{RoleAdmin, RoleSupport}
+
+// Validate checks that the role is a known value
+func (r Role) Validate() error {
+	if !slices.Contains(knownRoles, r) {
+		return ErrInvalidRole.Withf("%q", string(r))
+	}
+	return nil
+}
+
+// String returns the role as string
+func (r Role) String() string {
+	return string(r)
+}
+
+// FormatRoles joins roles with commas, e.g. for an audit entry
+func FormatRoles(roles []Role) string {
+	parts := make([]string, len(roles))
+	for i, role := range roles {
+		parts[i] = role.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseRoles splits roles formatted by FormatRoles. It does not validate them.
+func ParseRoles(s string) []Role {
+	if s == "" {
+		return nil
+	}
+
+	var roles []Role
+	for _, part := range strings.Split(s, ",") {
+		roles = append(roles, Role(part))
+	}
+	return roles
+}
+
+// HasRole reports whether the user holds role
+func (u *User) HasRole(role Role) bool {
+	return slices.Contains(u.Roles, role)
+}
+
+// SetRoles replaces the user's roles, recording who changed them. Duplicates
+// are dropped; setting the roles the user already has changes nothing.
+func (u *User) SetRoles(roles []Role, actor string) error {
+	var errs []error
+	for _, role := range roles {
+		errs = append(errs, role.Validate())
+	}
+	if err := JoinValidationErrors(errs...); err != nil {
+		return err
+	}
+
+	var normalized []Role
+	for _, role := range knownRoles {
+		if slices.Contains(roles, role) {
+			normalized = append(normalized, role)
+		}
+	}
+	if slices.Equal(normalized, u.Roles) {
+		return nil
+	}
+
+	now := u.now()
+	meta, err := u.meta(now)
+	if err != nil {
+		return err
+	}
+	u.record(UserRolesChanged{EventMeta: meta, OldRoles: u.Roles, NewRoles: normalized, Actor: actor})
+	u.Roles = normalized
+	u.UpdatedAt = now
+
+	return nil
+}
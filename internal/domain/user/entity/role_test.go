@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestUser_SetRoles(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	user.PullEvents()
+
+	if err := user.SetRoles([]Role{RoleSupport, RoleAdmin, RoleSupport}, "admin_1"); err != nil {
+		t.Fatalf("SetRoles() unexpected error: %v", err)
+	}
+	if want := []Role{RoleAdmin, RoleSupport}; !slices.Equal(user.Roles, want) {
+		t.Errorf("SetRoles() roles = %v, want %v", user.Roles, want)
+	}
+	if !user.HasRole(RoleAdmin) || !user.HasRole(RoleSupport) {
+		t.Errorf("HasRole() false for an assigned role")
+	}
+
+	events := user.PullEvents()
+	if len(events) != 1 {
+		t.Fatalf("SetRoles() recorded %v, want one event", eventNames(events))
+	}
+	if changed := events[0].(UserRolesChanged); len(changed.OldRoles) != 0 || len(changed.NewRoles) != 2 || changed.Actor != "admin_1" {
+		t.Errorf("UserRolesChanged = %+v", changed)
+	}
+
+	_ = user.SetRoles([]Role{RoleAdmin, RoleSupport}, "admin_1")
+	if events := user.PullEvents(); len(events) != 0 {
+		t.Errorf("SetRoles() with unchanged roles recorded %v", eventNames(events))
+	}
+
+	err := user.SetRoles([]Role{"root", RoleAdmin, "self"}, "admin_1")
+	var violations ValidationErrors
+	if !errors.Is(err, ErrInvalidRole) || !errors.As(err, &violations) || len(violations) != 2 {
+		t.Errorf("SetRoles() with unknown roles expected two ErrInvalidRole, got: %v", err)
+	}
+	if len(user.Roles) != 2 {
+		t.Errorf("SetRoles() with unknown roles changed roles to %v", user.Roles)
+	}
+}
+
+func TestFormatRoles(t *testing.T) {
+	roles := []Role{RoleAdmin, RoleSupport}
+	if got := ParseRoles(FormatRoles(roles)); !slices.Equal(got, roles) {
+		t.Errorf("ParseRoles(FormatRoles()) = %v, want %v", got, roles)
+	}
+	if got := ParseRoles(FormatRoles(nil)); got != nil {
+		t.Errorf("ParseRoles(FormatRoles(nil)) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,221 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+)
+
+// UserStatus represents the lifecycle state of a user
+type UserStatus string
+
+// User statuses
+const (
+	StatusPending     UserStatus = "pending"
+	StatusActive      UserStatus = "active"
+	StatusSuspended   UserStatus = "suspended"
+	StatusDeactivated UserStatus = "deactivated"
+	StatusDeleted     UserStatus = "deleted"
+)
+
+// Status errors
+var (
+	ErrInvalidStatus           = domainerr.Validation("invalid_status", "status", "invalid user status")
+	ErrInvalidStatusTransition = domainerr.New(domainerr.KindConflict, "invalid_status_transition", "invalid status transition")
+)
+
+// allowedTransitions lists, for each status, the statuses it may move to
+var allowedTransitions = map[UserStatus][]UserStatus{
+	StatusPending:     {StatusActive, StatusDeactivated, StatusDeleted},
+	StatusActive:      {StatusSuspended, StatusDeactivated, StatusDeleted},
+	StatusSuspended:   {StatusActive, StatusDeactivated, StatusDeleted},
+	StatusDeactivated: {StatusActive, StatusDeleted},
+	StatusDeleted:     {}, // only Restore leaves deleted
+}
+
+// StatusTransitionError describes a transition the state machine does not allow.
+// It unwraps to ErrInvalidStatusTransition with the transition as detail.
+type StatusTransitionError struct {
+	From UserStatus
+	To   UserStatus
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("%s: cannot change status from %s to %s", ErrInvalidStatusTransition, e.From, e.To)
+}
+
+// Unwrap returns ErrInvalidStatusTransition detailed with the transition
+func (e *StatusTransitionError) Unwrap() error {
+	return ErrInvalidStatusTransition.Withf("cannot change status from %s to %s", e.From, e.To)
+}
+
+// StatusTransition records a single status change
+type StatusTransition struct {
+	From   UserStatus `json:"from"`
+	To     UserStatus `json:"to"`
+	Reason string     `json:"reason"`
+	Actor  string     `json:"actor"`
+	At     time.Time  `json:"at"`
+}
+
+// Validate checks that the status is a known value
+func (s UserStatus) Validate() error {
+	if _, known := allowedTransitions[s]; !known {
+		return ErrInvalidStatus.Withf("%q", string(s))
+	}
+	return nil
+}
+
+// CanTransitionTo reports whether the state machine allows moving from s to target
+func (s UserStatus) CanTransitionTo(target UserStatus) bool {
+	for _, allowed := range allowedTransitions[s] {
+		if allowed == target {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the status as string
+func (s UserStatus) String() string {
+	return string(s)
+}
+
+// CanLogIn reports whether the user's status allows logging in and acting as
+// a caller. Suspended, deactivated and deleted users may do neither. Pending
+// users may: they log in to look after their own record while they wait to be
+// activated, and being pending grants nothing beyond that.
+func (u *User) CanLogIn() bool {
+	switch u.Status {
+	case StatusSuspended, StatusDeactivated, StatusDeleted:
+		return false
+	default:
+		return true
+	}
+}
+
+// TransitionTo moves the user to target, recording why and by whom
+func (u *User) TransitionTo(target UserStatus, reason string, actor string) error {
+	if err := target.Validate(); err != nil {
+		return err
+	}
+
+	if !u.Status.CanTransitionTo(target) {
+		return &StatusTransitionError{From: u.Status, To: target}
+	}
+
+	return u.applyTransition(target, reason, actor)
+}
+
+// applyTransition records and applies a transition without consulting the
+// state machine. The user is left unchanged if it fails.
+func (u *User) applyTransition(target UserStatus, reason string, actor string) error {
+	now := u.now()
+	changed, err := u.meta(now)
+	if err != nil {
+		return err
+	}
+	var followUp Event
+	switch {
+	case target == StatusDeleted:
+		meta, err := u.meta(now)
+		if err != nil {
+			return err
+		}
+		followUp = UserDeleted{EventMeta: meta, Reason: reason, Actor: actor}
+	case u.Status == StatusDeleted:
+		meta, err := u.meta(now)
+		if err != nil {
+			return err
+		}
+		followUp = UserRestored{EventMeta: meta, Reason: reason, Actor: actor}
+	}
+
+	u.record(UserStatusChanged{EventMeta: changed, From: u.Status, To: target, Reason: reason, Actor: actor})
+	if followUp != nil {
+		u.record(followUp)
+	}
+
+	u.setStatus(StatusTransition{
+		From:   u.Status,
+		To:     target,
+		Reason: reason,
+		Actor:  actor,
+		At:     now,
+	})
+	return nil
+}
+
+// setStatus adds transition to the history and moves the user to its target,
+// keeping DeletedAt in step with the deleted status
+func (u *User) setStatus(transition StatusTransition) {
+	u.StatusHistory = append(u.StatusHistory, transition)
+	u.Status = transition.To
+	u.UpdatedAt = transition.At
+
+	if transition.To == StatusDeleted {
+		u.DeletedAt = transition.At
+	} else {
+		u.DeletedAt = time.Time{}
+	}
+}
+
+// Activate moves a pending user to active
+func (u *User) Activate(reason string, actor string) error {
+	if u.Status != StatusPending {
+		return &StatusTransitionError{From: u.Status, To: StatusActive}
+	}
+	return u.TransitionTo(StatusActive, reason, actor)
+}
+
+// Suspend temporarily blocks an active user
+func (u *User) Suspend(reason string, actor string) error {
+	return u.TransitionTo(StatusSuspended, reason, actor)
+}
+
+// Reactivate returns a suspended or deactivated user to active
+func (u *User) Reactivate(reason string, actor string) error {
+	if u.Status != StatusSuspended && u.Status != StatusDeactivated {
+		return &StatusTransitionError{From: u.Status, To: StatusActive}
+	}
+	return u.TransitionTo(StatusActive, reason, actor)
+}
+
+// Deactivate closes the user's account without deleting it
+func (u *User) Deactivate(reason string, actor string) error {
+	return u.TransitionTo(StatusDeactivated, reason, actor)
+}
+
+// Delete soft-deletes the user. The user is kept, with DeletedAt set, until
+// it is restored or purged.
+func (u *User) Delete(reason string, actor string) error {
+	return u.TransitionTo(StatusDeleted, reason, actor)
+}
+
+// Restore undoes a soft delete, returning the user to the status it had
+// before deletion. It is the only way out of the deleted status.
+func (u *User) Restore(reason string, actor string) error {
+	if u.Status != StatusDeleted {
+		return ErrInvalidStatusTransition.Withf("only deleted users can be restored, user is %s", u.Status)
+	}
+
+	return u.applyTransition(u.statusBeforeDeletion(), reason, actor)
+}
+
+// IsDeleted reports whether the user is soft-deleted
+func (u *User) IsDeleted() bool {
+	return u.Status == StatusDeleted
+}
+
+// statusBeforeDeletion returns the status the user was deleted from.
+// Without a recorded history the user is restored as deactivated, so an
+// administrator has to reactivate it explicitly.
+func (u *User) statusBeforeDeletion() UserStatus {
+	for i := len(u.StatusHistory) - 1; i >= 0; i-- {
+		if transition := u.StatusHistory[i]; transition.To == StatusDeleted {
+			return transition.From
+		}
+	}
+	return StatusDeactivated
+}
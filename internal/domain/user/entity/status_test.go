@@ -0,0 +1,160 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUserStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from UserStatus
+		to   UserStatus
+		want bool
+	}{
+		{StatusPending, StatusActive, true},
+		{StatusPending, StatusSuspended, false},
+		{StatusActive, StatusSuspended, true},
+		{StatusActive, StatusPending, false},
+		{StatusSuspended, StatusActive, true},
+		{StatusSuspended, StatusDeactivated, true},
+		{StatusDeactivated, StatusActive, true},
+		{StatusDeactivated, StatusSuspended, false},
+		{StatusDeleted, StatusActive, false},
+		{StatusActive, StatusActive, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("CanTransitionTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserStatus_Validate(t *testing.T) {
+	if err := StatusActive.Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+
+	if err := UserStatus("banned").Validate(); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("Validate() expected ErrInvalidStatus, got: %v", err)
+	}
+}
+
+func TestUser_CanLogIn(t *testing.T) {
+	tests := map[UserStatus]bool{
+		StatusPending:     true,
+		StatusActive:      true,
+		StatusSuspended:   false,
+		StatusDeactivated: false,
+		StatusDeleted:     false,
+	}
+
+	for status, want := range tests {
+		user := &User{Status: status}
+		if got := user.CanLogIn(); got != want {
+			t.Errorf("CanLogIn() for a %s user = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestUser_TransitionRecordsHistory(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+
+	if user.Status != StatusPending {
+		t.Fatalf("NewUser() status = %s, want %s", user.Status, StatusPending)
+	}
+
+	if err := user.Activate("email verified", "system"); err != nil {
+		t.Fatalf("Activate() unexpected error: %v", err)
+	}
+	if err := user.Suspend("chargeback", "admin_1"); err != nil {
+		t.Fatalf("Suspend() unexpected error: %v", err)
+	}
+
+	if len(user.StatusHistory) != 2 {
+		t.Fatalf("StatusHistory length = %d, want 2", len(user.StatusHistory))
+	}
+
+	last := user.StatusHistory[1]
+	if last.From != StatusActive || last.To != StatusSuspended {
+		t.Errorf("StatusHistory transition = %s->%s, want active->suspended", last.From, last.To)
+	}
+	if last.Reason != "chargeback" || last.Actor != "admin_1" {
+		t.Errorf("StatusHistory reason/actor = %q/%q", last.Reason, last.Actor)
+	}
+	if last.At.IsZero() {
+		t.Errorf("StatusHistory timestamp not set")
+	}
+}
+
+func TestUser_InvalidTransition(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+
+	err := user.Reactivate("", "admin")
+	if !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Fatalf("Reactivate() expected ErrInvalidStatusTransition, got: %v", err)
+	}
+
+	var transitionErr *StatusTransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("Reactivate() expected *StatusTransitionError, got: %T", err)
+	}
+	if transitionErr.From != StatusPending || transitionErr.To != StatusActive {
+		t.Errorf("StatusTransitionError = %s->%s, want pending->active", transitionErr.From, transitionErr.To)
+	}
+
+	if user.Status != StatusPending || len(user.StatusHistory) != 0 {
+		t.Errorf("failed transition modified the user")
+	}
+
+	_ = user.TransitionTo(StatusDeleted, "", "admin")
+	if err := user.Deactivate("", "admin"); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("Deactivate() of deleted user expected ErrInvalidStatusTransition, got: %v", err)
+	}
+}
+
+func TestUser_DeleteAndRestore(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	_ = user.Activate("verified", "admin")
+	_ = user.Suspend("abuse report", "support")
+
+	if err := user.Delete("requested by user", "admin"); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if !user.IsDeleted() || user.DeletedAt.IsZero() {
+		t.Errorf("Delete() status = %s, DeletedAt = %v", user.Status, user.DeletedAt)
+	}
+
+	if err := user.Restore("deleted by mistake", "admin"); err != nil {
+		t.Fatalf("Restore() unexpected error: %v", err)
+	}
+	if user.Status != StatusSuspended {
+		t.Errorf("Restore() status = %s, want the status before deletion", user.Status)
+	}
+	if !user.DeletedAt.IsZero() {
+		t.Errorf("Restore() left DeletedAt = %v", user.DeletedAt)
+	}
+
+	last := user.StatusHistory[len(user.StatusHistory)-1]
+	if last.From != StatusDeleted || last.To != StatusSuspended || last.Reason != "deleted by mistake" {
+		t.Errorf("Restore() recorded %+v", last)
+	}
+
+	if err := user.Restore("", "admin"); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("Restore() of a live user expected ErrInvalidStatusTransition, got: %v", err)
+	}
+}
+
+func TestUser_RestoreWithoutHistory(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	user.Status = StatusDeleted
+
+	if err := user.Restore("", "admin"); err != nil {
+		t.Fatalf("Restore() unexpected error: %v", err)
+	}
+	if user.Status != StatusDeactivated {
+		t.Errorf("Restore() without history status = %s, want %s", user.Status, StatusDeactivated)
+	}
+}
@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"regexp"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+)
+
+// Tenant names the organization a user belongs to, e.g. "acme". The empty
+// tenant means the user belongs to none.
+type Tenant string
+
+// ErrInvalidTenant is returned for a tenant that is not a valid name
+var ErrInvalidTenant = domainerr.Validation("invalid_tenant", "tenant", "tenant must be up to 63 lowercase letters, digits and hyphens, starting with a letter or digit")
+
+// tenantPattern matches a valid non-empty tenant
+var tenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Validate checks that the tenant is empty or a valid name
+func (t Tenant) Validate() error {
+	if t != "" && !tenantPattern.MatchString(string(t)) {
+		return ErrInvalidTenant.Withf("%q", string(t))
+	}
+	return nil
+}
+
+// String returns the tenant as string
+func (t Tenant) String() string {
+	return string(t)
+}
+
+// SetTenant moves the user to tenant, recording who moved them. Setting the
+// tenant the user already belongs to changes nothing.
+func (u *User) SetTenant(tenant Tenant, actor string) error {
+	if err := tenant.Validate(); err != nil {
+		return err
+	}
+	if tenant == u.Tenant {
+		return nil
+	}
+
+	now := u.now()
+	meta, err := u.meta(now)
+	if err != nil {
+		return err
+	}
+	u.record(UserTenantChanged{EventMeta: meta, OldTenant: u.Tenant, NewTenant: tenant, Actor: actor})
+	u.Tenant = tenant
+	u.UpdatedAt = now
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTenant_Validate(t *testing.T) {
+	valid := []Tenant{"", "acme", "acme-eu", "4chan", Tenant(strings.Repeat("a", 63))}
+	for _, tenant := range valid {
+		if err := tenant.Validate(); err != nil {
+			t.Errorf("Tenant(%q).Validate() unexpected error: %v", tenant, err)
+		}
+	}
+
+	invalid := []Tenant{"Acme", "-acme", "acme corp", "acme.com", Tenant(strings.Repeat("a", 64))}
+	for _, tenant := range invalid {
+		if err := tenant.Validate(); !errors.Is(err, ErrInvalidTenant) {
+			t.Errorf("Tenant(%q).Validate() expected ErrInvalidTenant, got: %v", tenant, err)
+		}
+	}
+}
+
+func TestUser_SetTenant(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	user.PullEvents()
+
+	if err := user.SetTenant("acme", "admin_1"); err != nil {
+		t.Fatalf("SetTenant() unexpected error: %v", err)
+	}
+	if user.Tenant != "acme" {
+		t.Errorf("SetTenant() tenant = %q, want %q", user.Tenant, "acme")
+	}
+
+	events := user.PullEvents()
+	if len(events) != 1 {
+		t.Fatalf("SetTenant() recorded %v, want one event", eventNames(events))
+	}
+	if changed := events[0].(UserTenantChanged); changed.OldTenant != "" || changed.NewTenant != "acme" || changed.Actor != "admin_1" {
+		t.Errorf("UserTenantChanged = %+v", changed)
+	}
+
+	_ = user.SetTenant("acme", "admin_1")
+	if events := user.PullEvents(); len(events) != 0 {
+		t.Errorf("SetTenant() with unchanged tenant recorded %v", eventNames(events))
+	}
+
+	if err := user.SetTenant("Acme Corp", "admin_1"); !errors.Is(err, ErrInvalidTenant) {
+		t.Errorf("SetTenant() with an invalid name expected ErrInvalidTenant, got: %v", err)
+	}
+	if user.Tenant != "acme" {
+		t.Errorf("SetTenant() with an invalid name changed tenant to %q", user.Tenant)
+	}
+}
@@ -1,116 +1,232 @@
 package entity
 
 import (
-	"errors"
 	"fmt"
-	"regexp"
 	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
 )
 
 // User represents a user entity
 type User struct {
-	ID        UserID
-	Email     Email
-	Name      string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             UserID
+	Email          Email // as the user typed it
+	CanonicalEmail Email // normalized form used for uniqueness and lookups
+	Name           string
+	Password       Password
+	Status         UserStatus
+	StatusHistory  []StatusTransition
+	Roles          []Role // known roles without duplicates, in a fixed order
+	Tenant         Tenant // empty unless the user belongs to a tenant
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      time.Time // zero unless the user is soft-deleted
+	// Version counts stored changes; repositories bump it on every update and
+	// reject updates made from a stale copy
+	Version int64
+
+	// clock timestamps changes to the user; nil means clock.System
+	clock clock.Clock
+	// emailNormalizer derives CanonicalEmail on email changes
+	emailNormalizer EmailNormalizer
+	// events are recorded changes not yet collected with PullEvents
+	events []Event
 }
 
 // UserID represents a user identifier
 type UserID string
 
-// Email represents a user email address
-type Email string
-
 // Common errors
 var (
-	ErrInvalidEmail = errors.New("invalid email format")
-	ErrEmptyName    = errors.New("name cannot be empty")
+	ErrInvalidEmail          = domainerr.Validation("invalid_email", "email", "invalid email format")
+	ErrEmptyName             = domainerr.Validation("empty_name", "name", "name cannot be empty")
+	ErrNameTooLong           = domainerr.Validation("name_too_long", "name", "name is too long")
+	ErrInvalidNameCharacters = domainerr.Validation("invalid_name_characters", "name", "name contains control characters or invalid UTF-8")
 )
 
-// NewUser creates a new user with validation
-func NewUser(email string, name string) (*User, error) {
-	// Validate email
-	emailObj := Email(email)
-	if err := emailObj.Validate(); err != nil {
-		return nil, fmt.Errorf("email validation failed: %w", err)
+// UserOption customizes how NewUser builds a user
+type UserOption func(*userOptions)
+
+// userOptions holds the collaborators used by NewUser
+type userOptions struct {
+	idGenerator     IDGenerator
+	clock           clock.Clock
+	emailNormalizer EmailNormalizer
+}
+
+// WithIDGenerator makes NewUser draw the ID from generator instead of DefaultIDGenerator
+func WithIDGenerator(generator IDGenerator) UserOption {
+	return func(o *userOptions) {
+		o.idGenerator = generator
+	}
+}
+
+// WithClock makes NewUser, and later changes to the user, take timestamps from c
+func WithClock(c clock.Clock) UserOption {
+	return func(o *userOptions) {
+		o.clock = c
+	}
+}
+
+// WithEmailNormalizer makes NewUser, and later email changes, derive the
+// canonical email with normalizer instead of DefaultEmailNormalizer
+func WithEmailNormalizer(normalizer EmailNormalizer) UserOption {
+	return func(o *userOptions) {
+		o.emailNormalizer = normalizer
+	}
+}
+
+// NewUser creates a new user. The name is trimmed; if the email, name or
+// password is invalid, the error is a ValidationErrors listing every problem.
+func NewUser(email string, name string, password Password, opts ...UserOption) (*User, error) {
+	options := userOptions{
+		idGenerator:     DefaultIDGenerator,
+		clock:           clock.System,
+		emailNormalizer: DefaultEmailNormalizer,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	display, canonical, emailErr := options.emailNormalizer.Normalize(email)
+	name, nameErr := normalizeName(name)
+	var passwordErr error
+	if password.IsZero() {
+		passwordErr = ErrEmptyPassword
+	}
+	if err := JoinValidationErrors(emailErr, nameErr, passwordErr); err != nil {
+		return nil, err
 	}
 
-	// Validate name
-	if name == "" {
-		return nil, ErrEmptyName
+	id, err := options.idGenerator.NewID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user ID: %w", err)
 	}
 
-	now := time.Now()
+	now := options.clock.Now()
 	user := &User{
-		ID:        UserID(fmt.Sprintf("user_%d", now.UnixNano())), // Simple ID generation
-		Email:     emailObj,
-		Name:      name,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:              id,
+		Email:           display,
+		CanonicalEmail:  canonical,
+		Name:            name,
+		Password:        password,
+		Status:          StatusPending,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Version:         1,
+		clock:           options.clock,
+		emailNormalizer: options.emailNormalizer,
 	}
+	meta, err := user.meta(now)
+	if err != nil {
+		return nil, err
+	}
+	user.record(UserRegistered{
+		EventMeta:      meta,
+		Email:          display,
+		CanonicalEmail: canonical,
+		Name:           name,
+		PasswordHash:   password.Hash(),
+	})
 
 	return user, nil
 }
 
-// Validate validates the user entity
+// Validate validates the user entity, reporting every invalid field as ValidationErrors
 func (u *User) Validate() error {
-	if err := u.Email.Validate(); err != nil {
-		return fmt.Errorf("user email validation failed: %w", err)
+	_, nameErr := normalizeName(u.Name)
+	return JoinValidationErrors(u.Email.Validate(), nameErr, u.Status.Validate())
+}
+
+// Update updates user information. The name is trimmed; if the email or
+// name is invalid, the error is a ValidationErrors listing every problem.
+func (u *User) Update(email string, name string) error {
+	display, canonical, emailErr := u.emailNormalizer.Normalize(email)
+	name, nameErr := normalizeName(name)
+	if err := JoinValidationErrors(emailErr, nameErr); err != nil {
+		return err
 	}
 
-	if u.Name == "" {
-		return ErrEmptyName
+	now := u.now()
+	var events []Event
+	if display != u.Email {
+		meta, err := u.meta(now)
+		if err != nil {
+			return err
+		}
+		events = append(events, UserEmailChanged{EventMeta: meta, OldEmail: u.Email, NewEmail: display, CanonicalEmail: canonical})
+	}
+	if name != u.Name {
+		meta, err := u.meta(now)
+		if err != nil {
+			return err
+		}
+		events = append(events, UserRenamed{EventMeta: meta, OldName: u.Name, NewName: name})
 	}
+	for _, event := range events {
+		u.record(event)
+	}
+
+	u.Email = display
+	u.CanonicalEmail = canonical
+	u.Name = name
+	u.UpdatedAt = now
 
 	return nil
 }
 
-// Update updates user information
-func (u *User) Update(email string, name string) error {
-	// Validate new email
-	emailObj := Email(email)
-	if err := emailObj.Validate(); err != nil {
-		return fmt.Errorf("email validation failed: %w", err)
+// ChangePassword replaces the user's password hash
+func (u *User) ChangePassword(password Password) error {
+	if password.IsZero() {
+		return ErrEmptyPassword
 	}
 
-	// Validate new name
-	if name == "" {
-		return ErrEmptyName
+	now := u.now()
+	meta, err := u.meta(now)
+	if err != nil {
+		return err
 	}
-
-	u.Email = emailObj
-	u.Name = name
-	u.UpdatedAt = time.Now()
+	u.Password = password
+	u.UpdatedAt = now
+	u.record(UserPasswordChanged{EventMeta: meta, PasswordHash: password.Hash()})
 
 	return nil
 }
 
-// IsActive checks if the user is active
-func (u *User) IsActive() bool {
-	// For now, all users are considered active
-	// In the future, we could add an IsActive field or check against a blacklist
-	return true
+// SetClock sets the clock used to timestamp changes, e.g. after loading the
+// user from storage. A nil clock means clock.System.
+func (u *User) SetClock(c clock.Clock) {
+	u.clock = c
 }
 
-// Validate validates email format
-func (e Email) Validate() error {
-	if e == "" {
-		return ErrInvalidEmail
-	}
+// SetEmailNormalizer sets the normalizer used on later email changes,
+// e.g. after loading the user from storage
+func (u *User) SetEmailNormalizer(normalizer EmailNormalizer) {
+	u.emailNormalizer = normalizer
+}
 
-	// Simple email regex pattern
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	if !emailRegex.MatchString(string(e)) {
-		return ErrInvalidEmail
-	}
+// Clone returns a copy of the user that shares no mutable state with it.
+// Pending events are not part of the stored state and are left out.
+func (u *User) Clone() *User {
+	clone := *u
+	clone.StatusHistory = append([]StatusTransition(nil), u.StatusHistory...)
+	clone.Roles = append([]Role(nil), u.Roles...)
+	clone.events = nil
+	return &clone
+}
 
-	return nil
+// now returns the current time according to the user's clock
+func (u *User) now() time.Time {
+	if u.clock == nil {
+		return clock.System.Now()
+	}
+	return u.clock.Now()
 }
 
-// String returns the email as string
-func (e Email) String() string {
-	return string(e)
+// IsActive checks if the user is active
+func (u *User) IsActive() bool {
+	return u.Status == StatusActive
 }
 
 // String returns the user ID as string
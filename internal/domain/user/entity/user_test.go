@@ -1,10 +1,19 @@
 package entity
 
 import (
+	"errors"
 	"testing"
 	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
 )
 
+// testHasher keeps password hashing cheap in tests
+var testHasher = PasswordHasher{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+
+// testPassword is a cheaply hashed password shared by tests
+var testPassword, _ = testHasher.Hash("Secret-passw0rd")
+
 func TestNewUser(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -34,7 +43,7 @@ func TestNewUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, err := NewUser(tt.email, tt.userName)
+			user, err := NewUser(tt.email, tt.userName, testPassword)
 
 			if tt.wantErr && err == nil {
 				t.Errorf("NewUser() expected error but got none")
@@ -52,7 +61,7 @@ func TestNewUser(t *testing.T) {
 }
 
 func TestUser_Validate(t *testing.T) {
-	user, _ := NewUser("test@example.com", "Test User")
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
 
 	if err := user.Validate(); err != nil {
 		t.Errorf("User.Validate() unexpected error: %v", err)
@@ -60,11 +69,11 @@ func TestUser_Validate(t *testing.T) {
 }
 
 func TestUser_Update(t *testing.T) {
-	user, _ := NewUser("test@example.com", "Test User")
-	originalUpdatedAt := user.UpdatedAt
+	fakeClock := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	user, _ := NewUser("test@example.com", "Test User", testPassword, WithClock(fakeClock))
+	createdAt := user.CreatedAt
 
-	// Wait a bit to ensure UpdatedAt will be different
-	time.Sleep(1 * time.Millisecond)
+	fakeClock.Advance(time.Minute)
 
 	err := user.Update("new@example.com", "New Name")
 	if err != nil {
@@ -79,16 +88,102 @@ func TestUser_Update(t *testing.T) {
 		t.Errorf("User.Update() name not updated, got: %s", user.Name)
 	}
 
-	if user.UpdatedAt.Equal(originalUpdatedAt) {
-		t.Errorf("User.Update() UpdatedAt not changed")
+	if want := createdAt.Add(time.Minute); !user.UpdatedAt.Equal(want) {
+		t.Errorf("User.Update() UpdatedAt = %v, want %v", user.UpdatedAt, want)
+	}
+
+	if !user.CreatedAt.Equal(createdAt) {
+		t.Errorf("User.Update() changed CreatedAt")
+	}
+}
+
+func TestNewUser_WithClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	user, err := NewUser("test@example.com", "Test User", testPassword, WithClock(clock.NewFake(now)))
+	if err != nil {
+		t.Fatalf("NewUser() unexpected error: %v", err)
+	}
+
+	if !user.CreatedAt.Equal(now) || !user.UpdatedAt.Equal(now) {
+		t.Errorf("NewUser() timestamps = %v/%v, want %v", user.CreatedAt, user.UpdatedAt, now)
+	}
+}
+
+func TestUser_SetClock(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+
+	now := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	user.SetClock(clock.NewFake(now))
+
+	if err := user.Activate("verified", "admin"); err != nil {
+		t.Fatalf("Activate() unexpected error: %v", err)
+	}
+
+	if !user.UpdatedAt.Equal(now) {
+		t.Errorf("Activate() UpdatedAt = %v, want %v", user.UpdatedAt, now)
+	}
+	if at := user.StatusHistory[0].At; !at.Equal(now) {
+		t.Errorf("Activate() transition At = %v, want %v", at, now)
+	}
+}
+
+func TestNewUser_RequiresPassword(t *testing.T) {
+	_, err := NewUser("test@example.com", "Test User", Password{})
+	if !errors.Is(err, ErrEmptyPassword) {
+		t.Errorf("NewUser() expected ErrEmptyPassword, got: %v", err)
+	}
+}
+
+func TestUser_ChangePassword(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	newPassword, _ := testHasher.Hash("Another-passw0rd")
+
+	if err := user.ChangePassword(newPassword); err != nil {
+		t.Fatalf("User.ChangePassword() unexpected error: %v", err)
+	}
+
+	if !user.Password.Verify("Another-passw0rd") {
+		t.Errorf("User.ChangePassword() password not changed")
+	}
+
+	if err := user.ChangePassword(Password{}); err != ErrEmptyPassword {
+		t.Errorf("User.ChangePassword() expected ErrEmptyPassword, got: %v", err)
+	}
+}
+
+func TestUser_Clone(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+	_ = user.SetRoles([]Role{RoleSupport}, "admin")
+
+	clone := user.Clone()
+	if len(clone.Events()) != 0 {
+		t.Errorf("Clone() kept %d pending events", len(clone.Events()))
+	}
+
+	clone.Roles[0] = RoleAdmin
+	if user.Roles[0] != RoleSupport {
+		t.Errorf("Clone() shares roles with the original: %v", user.Roles)
 	}
 }
 
 func TestUser_IsActive(t *testing.T) {
-	user, _ := NewUser("test@example.com", "Test User")
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+
+	if user.IsActive() {
+		t.Errorf("User.IsActive() should return false for new pending user")
+	}
+
+	_ = user.Activate("email verified", "system")
 
 	if !user.IsActive() {
-		t.Errorf("User.IsActive() should return true for new user")
+		t.Errorf("User.IsActive() should return true for activated user")
+	}
+
+	_ = user.Suspend("abuse report", "admin")
+
+	if user.IsActive() {
+		t.Errorf("User.IsActive() should return false for suspended user")
 	}
 }
 
@@ -0,0 +1,115 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+)
+
+// MaxNameLength is the maximum length of a user's name, in characters
+const MaxNameLength = 100
+
+// ValidationErrors lists every rule an input breaks, so that a caller can
+// fix them all at once instead of discovering them one per attempt.
+// errors.Is matches each of the listed errors; errors.As to a
+// *domainerr.Error yields a single error carrying every field error.
+type ValidationErrors []*domainerr.Error
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, err := range v {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the listed errors
+func (v ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(v))
+	for i, err := range v {
+		errs[i] = err
+	}
+	return errs
+}
+
+// As sets a *domainerr.Error target to DomainError
+func (v ValidationErrors) As(target any) bool {
+	domainErr, ok := target.(**domainerr.Error)
+	if ok {
+		*domainErr = v.DomainError()
+	}
+	return ok
+}
+
+// Fields returns the field errors of every listed error
+func (v ValidationErrors) Fields() []domainerr.FieldError {
+	var fields []domainerr.FieldError
+	for _, err := range v {
+		fields = append(fields, err.Fields...)
+	}
+	return fields
+}
+
+// DomainError combines the listed errors into one. A single error is
+// returned as is, keeping its code.
+func (v ValidationErrors) DomainError() *domainerr.Error {
+	if len(v) == 1 {
+		return v[0]
+	}
+	return domainerr.New(domainerr.KindValidation, "validation_failed", v.Error(), v.Fields()...)
+}
+
+// JoinValidationErrors combines the results of several checks. Nil errors
+// are skipped. If the rest are all validation errors they are returned
+// together as ValidationErrors; otherwise the first other error is returned.
+func JoinValidationErrors(errs ...error) error {
+	var violations ValidationErrors
+	for _, err := range errs {
+		var joined ValidationErrors
+		switch {
+		case err == nil:
+		case errors.As(err, &joined):
+			violations = append(violations, joined...)
+		case domainerr.KindOf(err) == domainerr.KindValidation:
+			domainErr, _ := domainerr.As(err)
+			violations = append(violations, domainErr)
+		default:
+			return err
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return violations
+}
+
+// ValidateProfile checks an email and name the way NewUser and Update do,
+// reporting every violation at once
+func ValidateProfile(email string, name string, normalizer EmailNormalizer) error {
+	_, _, emailErr := normalizer.Normalize(email)
+	_, nameErr := normalizeName(name)
+	return JoinValidationErrors(emailErr, nameErr)
+}
+
+// normalizeName trims surrounding whitespace from name and checks what is
+// left: it must not be empty, longer than MaxNameLength or contain control
+// characters or invalid UTF-8
+func normalizeName(name string) (string, error) {
+	trimmed := strings.TrimSpace(name)
+	if trimmed == "" {
+		return "", ErrEmptyName
+	}
+
+	var errs []error
+	if utf8.RuneCountInString(trimmed) > MaxNameLength {
+		errs = append(errs, ErrNameTooLong.Withf("must be at most %d characters", MaxNameLength))
+	}
+	if !utf8.ValidString(trimmed) || strings.ContainsFunc(trimmed, unicode.IsControl) {
+		errs = append(errs, ErrInvalidNameCharacters)
+	}
+	return trimmed, JoinValidationErrors(errs...)
+}
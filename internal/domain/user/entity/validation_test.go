@@ -0,0 +1,108 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+)
+
+func TestNewUser_ReportsEveryViolation(t *testing.T) {
+	longName := strings.Repeat("a", MaxNameLength) + "\x00"
+
+	_, err := NewUser("invalid-email", longName, Password{})
+
+	var violations ValidationErrors
+	if !errors.As(err, &violations) {
+		t.Fatalf("NewUser() expected ValidationErrors, got: %v", err)
+	}
+	for _, want := range []error{ErrInvalidEmail, ErrNameTooLong, ErrInvalidNameCharacters, ErrEmptyPassword} {
+		if !errors.Is(err, want) {
+			t.Errorf("NewUser() error %q does not match %q", err, want)
+		}
+	}
+	if len(violations) != 4 {
+		t.Errorf("NewUser() returned %d violations, want 4", len(violations))
+	}
+
+	domainErr, ok := domainerr.As(err)
+	if !ok || domainErr.Code != "validation_failed" || domainErr.Kind != domainerr.KindValidation {
+		t.Fatalf("domainerr.As() = %+v, %v, want a combined validation error", domainErr, ok)
+	}
+	var fields []string
+	for _, field := range domainErr.Fields {
+		fields = append(fields, field.Field)
+	}
+	if got := strings.Join(fields, ","); got != "email,name,name,password" {
+		t.Errorf("combined error fields = %s, want email,name,name,password", got)
+	}
+}
+
+func TestNewUser_NameRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		userName string
+		want     string
+		wantErr  error
+	}{
+		{"trimmed", "  Test User \t", "Test User", nil},
+		{"unicode", "Zoë Ñandú 李", "Zoë Ñandú 李", nil},
+		{"at the length limit", strings.Repeat("é", MaxNameLength), strings.Repeat("é", MaxNameLength), nil},
+		{"only whitespace", " \t ", "", ErrEmptyName},
+		{"too long", strings.Repeat("é", MaxNameLength+1), "", ErrNameTooLong},
+		{"control character", "Test\nUser", "", ErrInvalidNameCharacters},
+		{"invalid UTF-8", "Test \xff User", "", ErrInvalidNameCharacters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := NewUser("test@example.com", tt.userName, testPassword)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("NewUser() expected %v, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewUser() unexpected error: %v", err)
+			}
+			if user.Name != tt.want {
+				t.Errorf("NewUser() name = %q, want %q", user.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestUser_UpdateReportsEveryViolation(t *testing.T) {
+	user, _ := NewUser("test@example.com", "Test User", testPassword)
+
+	err := user.Update("invalid-email", "")
+	if !errors.Is(err, ErrInvalidEmail) || !errors.Is(err, ErrEmptyName) {
+		t.Errorf("Update() expected ErrInvalidEmail and ErrEmptyName, got: %v", err)
+	}
+	if user.Email != "test@example.com" || user.Name != "Test User" || len(user.Events()) != 1 {
+		t.Errorf("Update() changed the user despite failing: %+v", user)
+	}
+
+	if err := user.Update("test@example.com", " Renamed "); err != nil || user.Name != "Renamed" {
+		t.Errorf("Update() = %v, name %q, want the trimmed name", err, user.Name)
+	}
+}
+
+func TestJoinValidationErrors(t *testing.T) {
+	if err := JoinValidationErrors(nil, nil); err != nil {
+		t.Errorf("JoinValidationErrors() of nils = %v, want nil", err)
+	}
+
+	internal := errors.New("connection refused")
+	if err := JoinValidationErrors(ErrEmptyName, internal); err != internal {
+		t.Errorf("JoinValidationErrors() = %v, want the non-validation error", err)
+	}
+
+	nested := JoinValidationErrors(ErrInvalidEmail, ErrEmptyName)
+	joined := JoinValidationErrors(nested, ErrEmptyPassword)
+	if violations, ok := joined.(ValidationErrors); !ok || len(violations) != 3 {
+		t.Errorf("JoinValidationErrors() = %#v, want three flattened violations", joined)
+	}
+}
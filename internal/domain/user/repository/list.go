@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Listing defaults
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// Listing errors
+var (
+	ErrInvalidListQuery = domainerr.New(domainerr.KindInvalid, "invalid_list_query", "invalid list query")
+	ErrInvalidCursor    = domainerr.New(domainerr.KindInvalid, "invalid_cursor", "invalid cursor")
+)
+
+// SortField is a user attribute that listings can be ordered by
+type SortField string
+
+// Sortable fields
+const (
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+	SortByName      SortField = "name"
+)
+
+// SortOrder is the direction of a listing
+type SortOrder string
+
+// Sort orders
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+// UserFilter restricts which users a listing returns. Zero fields don't filter.
+type UserFilter struct {
+	// Statuses matches users in any of the given statuses
+	Statuses []entity.UserStatus
+	// EmailDomain matches the domain of the canonical email, so it must be in
+	// canonical form itself (see entity.EmailNormalizer.CanonicalDomain)
+	EmailDomain string
+	// CreatedAfter and CreatedBefore bound CreatedAt (inclusive, exclusive)
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// IncludeDeleted also matches soft-deleted users. Normalize sets it when
+	// Statuses asks for deleted users.
+	IncludeDeleted bool
+}
+
+// ListQuery describes one page of a user listing.
+// Results are ordered by SortBy and then by ID, so pages are stable even when
+// many users share a sort value.
+type ListQuery struct {
+	Filter UserFilter
+	SortBy SortField
+	Order  SortOrder
+	Limit  int
+	// Cursor is the opaque NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// UserPage is one page of a user listing
+type UserPage struct {
+	Users []*entity.User
+	// NextCursor is empty when there are no more results
+	NextCursor string
+}
+
+// Normalize fills in defaults and validates the query
+func (q ListQuery) Normalize() (ListQuery, error) {
+	if q.SortBy == "" {
+		q.SortBy = SortByCreatedAt
+	}
+	if q.Order == "" {
+		q.Order = SortAsc
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultListLimit
+	}
+
+	switch q.SortBy {
+	case SortByCreatedAt, SortByUpdatedAt, SortByName:
+	default:
+		return q, ErrInvalidListQuery.Withf("unsupported sort field %q", q.SortBy)
+	}
+
+	if q.Order != SortAsc && q.Order != SortDesc {
+		return q, ErrInvalidListQuery.Withf("unsupported sort order %q", q.Order)
+	}
+
+	if q.Limit < 0 || q.Limit > MaxListLimit {
+		return q, ErrInvalidListQuery.Withf("limit must be between 1 and %d", MaxListLimit)
+	}
+
+	for _, status := range q.Filter.Statuses {
+		if err := status.Validate(); err != nil {
+			return q, ErrInvalidListQuery.Withf("%v", err)
+		}
+		if status == entity.StatusDeleted {
+			q.Filter.IncludeDeleted = true
+		}
+	}
+
+	if !q.Filter.CreatedAfter.IsZero() && !q.Filter.CreatedBefore.IsZero() &&
+		!q.Filter.CreatedAfter.Before(q.Filter.CreatedBefore) {
+		return q, ErrInvalidListQuery.Withf("created_after must be before created_before")
+	}
+
+	q.Filter.EmailDomain = strings.ToLower(strings.TrimPrefix(q.Filter.EmailDomain, "@"))
+
+	if q.Cursor != "" {
+		if _, err := q.DecodeCursor(); err != nil {
+			return q, err
+		}
+	}
+
+	return q, nil
+}
+
+// Matches reports whether user satisfies the filter
+func (f UserFilter) Matches(user *entity.User) bool {
+	if user.IsDeleted() && !f.IncludeDeleted {
+		return false
+	}
+
+	if len(f.Statuses) > 0 {
+		matched := false
+		for _, status := range f.Statuses {
+			if user.Status == status {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if f.EmailDomain != "" && EmailDomain(user.CanonicalEmail) != strings.ToLower(f.EmailDomain) {
+		return false
+	}
+
+	if !f.CreatedAfter.IsZero() && user.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+
+	if !f.CreatedBefore.IsZero() && !user.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// EmailDomain returns the lowercased domain part of an email address
+func EmailDomain(email entity.Email) string {
+	at := strings.LastIndex(string(email), "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(string(email)[at+1:])
+}
+
+// Cursor is the decoded position after the last user of a page
+type Cursor struct {
+	SortBy SortField     `json:"s"`
+	Order  SortOrder     `json:"o"`
+	Time   time.Time     `json:"t,omitempty"`
+	Name   string        `json:"n,omitempty"`
+	ID     entity.UserID `json:"i"`
+}
+
+// CursorAfter builds the cursor pointing just past user in the query's ordering
+func (q ListQuery) CursorAfter(user *entity.User) string {
+	cursor := Cursor{SortBy: q.SortBy, Order: q.Order, ID: user.ID}
+
+	switch q.SortBy {
+	case SortByName:
+		cursor.Name = user.Name
+	case SortByUpdatedAt:
+		cursor.Time = user.UpdatedAt
+	default:
+		cursor.Time = user.CreatedAt
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor decodes the query's cursor and checks that it belongs to the
+// same ordering; a cursor from a differently sorted listing is rejected
+func (q ListQuery) DecodeCursor() (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if cursor.SortBy != q.SortBy || cursor.Order != q.Order {
+		return Cursor{}, ErrInvalidCursor.Withf("cursor was issued for a different sort order")
+	}
+
+	return cursor, nil
+}
+
+// CompareUsers orders two users by the query's sort field and then by ID,
+// honouring the sort order. It returns a negative number if a comes first.
+func (q ListQuery) CompareUsers(a, b *entity.User) int {
+	result := compareByField(q.SortBy, a, b)
+	if result == 0 {
+		result = strings.Compare(string(a.ID), string(b.ID))
+	}
+	if q.Order == SortDesc {
+		result = -result
+	}
+	return result
+}
+
+// IsAfterCursor reports whether user comes strictly after the cursor position
+func (q ListQuery) IsAfterCursor(user *entity.User, cursor Cursor) bool {
+	marker := &entity.User{ID: cursor.ID, Name: cursor.Name, CreatedAt: cursor.Time, UpdatedAt: cursor.Time}
+	return q.CompareUsers(user, marker) > 0
+}
+
+// Paginate filters, sorts and pages an in-memory set of users.
+// It is meant for adapters that hold all users in memory; q must be normalized.
+func (q ListQuery) Paginate(users []*entity.User) (*UserPage, error) {
+	var (
+		cursor    Cursor
+		hasCursor = q.Cursor != ""
+	)
+	if hasCursor {
+		var err error
+		if cursor, err = q.DecodeCursor(); err != nil {
+			return nil, err
+		}
+	}
+
+	matched := make([]*entity.User, 0, len(users))
+	for _, user := range users {
+		if !q.Filter.Matches(user) {
+			continue
+		}
+		if hasCursor && !q.IsAfterCursor(user, cursor) {
+			continue
+		}
+		matched = append(matched, user)
+	}
+
+	slices.SortFunc(matched, q.CompareUsers)
+
+	page := &UserPage{Users: matched}
+	if len(matched) > q.Limit {
+		page.Users = matched[:q.Limit]
+		page.NextCursor = q.CursorAfter(page.Users[q.Limit-1])
+	}
+
+	return page, nil
+}
+
+func compareByField(field SortField, a, b *entity.User) int {
+	switch field {
+	case SortByName:
+		return strings.Compare(a.Name, b.Name)
+	case SortByUpdatedAt:
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	default:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}
+}
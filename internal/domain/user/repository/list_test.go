@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// listFixture builds users whose creation times collide in pairs to exercise the ID tie-breaker
+func listFixture() []*entity.User {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	users := make([]*entity.User, 0, 10)
+	for i := 0; i < 10; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		if i%3 == 0 {
+			email = fmt.Sprintf("user%d@Other.org", i)
+		}
+		user, _ := entity.NewUser(email, fmt.Sprintf("User %d", 9-i), testPassword)
+		user.ID = entity.UserID(fmt.Sprintf("user_%02d", i))
+		user.CreatedAt = base.Add(time.Duration(i/2) * time.Hour)
+		user.UpdatedAt = user.CreatedAt
+		if i%3 == 0 {
+			_ = user.Activate("", "")
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+func collectAll(t *testing.T, users []*entity.User, query ListQuery) []entity.UserID {
+	t.Helper()
+
+	query, err := query.Normalize()
+	if err != nil {
+		t.Fatalf("Normalize() unexpected error: %v", err)
+	}
+
+	var ids []entity.UserID
+	for pages := 0; ; pages++ {
+		if pages > len(users) {
+			t.Fatalf("Paginate() did not terminate")
+		}
+
+		page, err := query.Paginate(users)
+		if err != nil {
+			t.Fatalf("Paginate() unexpected error: %v", err)
+		}
+		for _, user := range page.Users {
+			ids = append(ids, user.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestListQuery_PaginatesAllUsersOnce(t *testing.T) {
+	users := listFixture()
+
+	ids := collectAll(t, users, ListQuery{Limit: 3})
+	if len(ids) != len(users) {
+		t.Fatalf("Paginate() returned %d users, want %d", len(ids), len(users))
+	}
+
+	for i, id := range ids {
+		if want := entity.UserID(fmt.Sprintf("user_%02d", i)); id != want {
+			t.Errorf("Paginate() position %d = %s, want %s", i, id, want)
+		}
+	}
+}
+
+func TestListQuery_SortDescending(t *testing.T) {
+	ids := collectAll(t, listFixture(), ListQuery{SortBy: SortByCreatedAt, Order: SortDesc, Limit: 4})
+
+	if ids[0] != "user_09" || ids[len(ids)-1] != "user_00" {
+		t.Errorf("Paginate() desc order = %v", ids)
+	}
+}
+
+func TestListQuery_SortByName(t *testing.T) {
+	ids := collectAll(t, listFixture(), ListQuery{SortBy: SortByName, Limit: 4})
+
+	// Names are assigned in reverse order of IDs
+	if ids[0] != "user_09" || ids[len(ids)-1] != "user_00" {
+		t.Errorf("Paginate() name order = %v", ids)
+	}
+}
+
+func TestListQuery_Filters(t *testing.T) {
+	users := listFixture()
+
+	tests := []struct {
+		name   string
+		filter UserFilter
+		want   int
+	}{
+		{"no filter", UserFilter{}, 10},
+		{"status active", UserFilter{Statuses: []entity.UserStatus{entity.StatusActive}}, 4},
+		{"status pending or active", UserFilter{Statuses: []entity.UserStatus{entity.StatusPending, entity.StatusActive}}, 10},
+		{"email domain case-insensitive", UserFilter{EmailDomain: "other.ORG"}, 4},
+		{"created range", UserFilter{
+			CreatedAfter:  time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC),
+			CreatedBefore: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC),
+		}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := collectAll(t, users, ListQuery{Filter: tt.filter, Limit: 3})
+			if len(ids) != tt.want {
+				t.Errorf("Paginate() returned %d users, want %d", len(ids), tt.want)
+			}
+		})
+	}
+}
+
+func TestListQuery_NormalizeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   ListQuery
+		wantErr error
+	}{
+		{"unknown sort", ListQuery{SortBy: "email"}, ErrInvalidListQuery},
+		{"unknown order", ListQuery{Order: "sideways"}, ErrInvalidListQuery},
+		{"limit too large", ListQuery{Limit: MaxListLimit + 1}, ErrInvalidListQuery},
+		{"unknown status", ListQuery{Filter: UserFilter{Statuses: []entity.UserStatus{"banned"}}}, ErrInvalidListQuery},
+		{"inverted range", ListQuery{Filter: UserFilter{
+			CreatedAfter:  time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			CreatedBefore: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}}, ErrInvalidListQuery},
+		{"garbage cursor", ListQuery{Cursor: "not-a-cursor!"}, ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.query.Normalize(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Normalize() expected %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestListQuery_CursorBoundToSortOrder(t *testing.T) {
+	query, _ := ListQuery{Limit: 2}.Normalize()
+	page, _ := query.Paginate(listFixture())
+
+	other := ListQuery{SortBy: SortByName, Cursor: page.NextCursor}
+	if _, err := other.Normalize(); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Normalize() expected ErrInvalidCursor for foreign cursor, got: %v", err)
+	}
+}
+
+func TestListQuery_HidesDeletedUsers(t *testing.T) {
+	users := listFixture()
+	_ = users[1].Delete("", "admin")
+
+	for _, id := range collectAll(t, users, ListQuery{}) {
+		if id == users[1].ID {
+			t.Errorf("Paginate() returned deleted user %s by default", id)
+		}
+	}
+
+	all := collectAll(t, users, ListQuery{Filter: UserFilter{IncludeDeleted: true}})
+	if len(all) != len(users) {
+		t.Errorf("Paginate() with IncludeDeleted returned %d users, want %d", len(all), len(users))
+	}
+
+	deleted := collectAll(t, users, ListQuery{Filter: UserFilter{Statuses: []entity.UserStatus{entity.StatusDeleted}}})
+	if len(deleted) != 1 || deleted[0] != users[1].ID {
+		t.Errorf("Paginate() status=deleted = %v, want [%s]", deleted, users[1].ID)
+	}
+}
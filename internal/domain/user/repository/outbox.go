@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Outbox holds domain events that were stored together with the change that
+// produced them but have not been published yet. Because an event enters the
+// outbox in the same transaction as its user, it is never lost after a
+// successful write and never published for a write that failed. Events are
+// held as entity.WithoutSecrets returns them, so publishing them reveals no
+// password hashes.
+type Outbox interface {
+	// PendingEvents returns up to limit unpublished events, oldest first
+	PendingEvents(ctx context.Context, limit int) ([]entity.Event, error)
+
+	// MarkEventsPublished removes the events with the given IDs from the
+	// outbox. Unknown IDs are ignored.
+	MarkEventsPublished(ctx context.Context, ids ...string) error
+}
@@ -1,31 +1,87 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
 	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
 )
 
-// UserRepository defines the interface for user data access
+// UserRepository defines the interface for user data access.
+// Every method takes a context; implementations must abort and return
+// ctx.Err() once the context is cancelled or its deadline has passed.
 type UserRepository interface {
-	// Save creates a new user or updates existing one
-	Save(user *entity.User) error
+	// Create stores a new user. It is atomic: if a user with the same ID or
+	// canonical email already exists, including one created concurrently,
+	// it fails with ErrUserAlreadyExists and stores nothing.
+	// Like Update, it writes the user's pending events to the outbox together
+	// with the user and then clears them.
+	Create(ctx context.Context, user *entity.User) error
+
+	// FindByID retrieves a user by their ID. Soft-deleted users are not found.
+	FindByID(ctx context.Context, id entity.UserID) (*entity.User, error)
+
+	// FindDeletedByID retrieves a soft-deleted user by their ID
+	FindDeletedByID(ctx context.Context, id entity.UserID) (*entity.User, error)
 
-	// FindByID retrieves a user by their ID
-	FindByID(id entity.UserID) (*entity.User, error)
+	// FindByEmail retrieves a user by their canonical email. Soft-deleted users
+	// are not found, but keep their email reserved until they are purged.
+	FindByEmail(ctx context.Context, email entity.Email) (*entity.User, error)
 
-	// FindByEmail retrieves a user by their email
-	FindByEmail(email entity.Email) (*entity.User, error)
+	// Update replaces an existing user if its stored version still equals
+	// user.Version, then increments user.Version. If another update got there
+	// first it fails with a *VersionConflictError and stores nothing.
+	// The user's pending events are written to the outbox in the same
+	// transaction and cleared from the user once the update succeeds.
+	Update(ctx context.Context, user *entity.User) error
 
-	// Update updates an existing user
-	Update(user *entity.User) error
+	// Delete permanently removes a user by their ID, deleted or not
+	Delete(ctx context.Context, id entity.UserID) error
+
+	// PurgeDeleted permanently removes users soft-deleted before cutoff and
+	// returns how many were removed
+	PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// List returns one page of users matching the query.
+	// The query must be normalized with ListQuery.Normalize.
+	// Soft-deleted users are only included if the filter asks for them.
+	List(ctx context.Context, query ListQuery) (*UserPage, error)
+}
 
-	// Delete removes a user by their ID
-	Delete(id entity.UserID) error
+// PastUserFinder is implemented by user repositories that keep every change
+// to a user and so can rebuild it as it was at any time, such as an
+// event-sourced one
+type PastUserFinder interface {
+	// FindByIDAsOf rebuilds a user, soft-deleted or not, as it was at the
+	// given time. It fails with ErrUserNotFound if the user did not exist yet.
+	FindByIDAsOf(ctx context.Context, id entity.UserID, at time.Time) (*entity.User, error)
 }
 
 // Domain-specific errors
 var (
-	ErrUserNotFound      = errors.New("user not found")
-	ErrUserAlreadyExists = errors.New("user already exists")
+	ErrUserNotFound      = domainerr.New(domainerr.KindNotFound, "user_not_found", "user not found")
+	ErrUserAlreadyExists = domainerr.New(domainerr.KindConflict, "user_already_exists", "user already exists")
 	ErrInvalidUser       = errors.New("invalid user data")
+	ErrVersionConflict   = domainerr.New(domainerr.KindPrecondition, "version_conflict", "user was modified concurrently")
 )
+
+// VersionConflictError reports an update made from a stale copy of a user.
+// It unwraps to ErrVersionConflict.
+type VersionConflictError struct {
+	ID       entity.UserID
+	Expected int64
+	Actual   int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: user %s is at version %d, update was based on version %d",
+		ErrVersionConflict, e.ID, e.Actual, e.Expected)
+}
+
+// Unwrap returns ErrVersionConflict
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
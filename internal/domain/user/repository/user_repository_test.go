@@ -1,10 +1,16 @@
 package repository
 
 import (
-	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"context"
+	"errors"
 	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
 )
 
+// testPassword is a cheaply hashed password shared by tests
+var testPassword, _ = entity.PasswordHasher{Iterations: 1000, SaltLength: 16, KeyLength: 32}.Hash("Secret-passw0rd")
+
 // MockUserRepository implements UserRepository for testing
 type MockUserRepository struct {
 	users map[entity.UserID]*entity.User
@@ -16,15 +22,23 @@ func NewMockUserRepository() *MockUserRepository {
 	}
 }
 
-func (m *MockUserRepository) Save(user *entity.User) error {
+func (m *MockUserRepository) Create(ctx context.Context, user *entity.User) error {
 	if user == nil {
 		return ErrInvalidUser
 	}
+	if _, exists := m.users[user.ID]; exists {
+		return ErrUserAlreadyExists
+	}
+	for _, existing := range m.users {
+		if existing.CanonicalEmail == user.CanonicalEmail {
+			return ErrUserAlreadyExists
+		}
+	}
 	m.users[user.ID] = user
 	return nil
 }
 
-func (m *MockUserRepository) FindByID(id entity.UserID) (*entity.User, error) {
+func (m *MockUserRepository) FindByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
 	user, exists := m.users[id]
 	if !exists {
 		return nil, ErrUserNotFound
@@ -32,16 +46,16 @@ func (m *MockUserRepository) FindByID(id entity.UserID) (*entity.User, error) {
 	return user, nil
 }
 
-func (m *MockUserRepository) FindByEmail(email entity.Email) (*entity.User, error) {
+func (m *MockUserRepository) FindByEmail(ctx context.Context, email entity.Email) (*entity.User, error) {
 	for _, user := range m.users {
-		if user.Email == email {
+		if user.CanonicalEmail == email {
 			return user, nil
 		}
 	}
 	return nil, ErrUserNotFound
 }
 
-func (m *MockUserRepository) Update(user *entity.User) error {
+func (m *MockUserRepository) Update(ctx context.Context, user *entity.User) error {
 	if user == nil {
 		return ErrInvalidUser
 	}
@@ -55,7 +69,7 @@ func (m *MockUserRepository) Update(user *entity.User) error {
 	return nil
 }
 
-func (m *MockUserRepository) Delete(id entity.UserID) error {
+func (m *MockUserRepository) Delete(ctx context.Context, id entity.UserID) error {
 	_, exists := m.users[id]
 	if !exists {
 		return ErrUserNotFound
@@ -65,44 +79,72 @@ func (m *MockUserRepository) Delete(id entity.UserID) error {
 	return nil
 }
 
-func TestUserRepository_Save(t *testing.T) {
+func (m *MockUserRepository) List(ctx context.Context, query ListQuery) (*UserPage, error) {
+	users := make([]*entity.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return query.Paginate(users)
+}
+
+func TestUserRepository_Create(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	user, _ := entity.NewUser("test@example.com", "Test User")
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
 
-	err := repo.Save(user)
+	err := repo.Create(ctx, user)
 	if err != nil {
-		t.Errorf("Save() unexpected error: %v", err)
+		t.Errorf("Create() unexpected error: %v", err)
 	}
 
 	// Verify user was saved
-	savedUser, err := repo.FindByID(user.ID)
+	savedUser, err := repo.FindByID(ctx, user.ID)
 	if err != nil {
 		t.Errorf("FindByID() failed to find saved user: %v", err)
 	}
 
 	if savedUser.Email != user.Email {
-		t.Errorf("Save() user email mismatch, got: %s, want: %s", savedUser.Email, user.Email)
+		t.Errorf("Create() user email mismatch, got: %s, want: %s", savedUser.Email, user.Email)
+	}
+}
+
+func TestUserRepository_CreateDuplicateEmail(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	duplicate, _ := entity.NewUser("Test@Example.com", "Other User", testPassword)
+
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	if err := repo.Create(ctx, duplicate); !errors.Is(err, ErrUserAlreadyExists) {
+		t.Errorf("Create() with a duplicate email expected ErrUserAlreadyExists, got: %v", err)
+	}
+	if _, err := repo.FindByID(ctx, duplicate.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Create() with a duplicate email stored the user, FindByID() error = %v", err)
 	}
 }
 
-func TestUserRepository_SaveNilUser(t *testing.T) {
+func TestUserRepository_CreateNilUser(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
 
-	err := repo.Save(nil)
+	err := repo.Create(ctx, nil)
 	if err != ErrInvalidUser {
-		t.Errorf("Save() expected ErrInvalidUser, got: %v", err)
+		t.Errorf("Create() expected ErrInvalidUser, got: %v", err)
 	}
 }
 
 func TestUserRepository_FindByID(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	user, _ := entity.NewUser("test@example.com", "Test User")
-	err := repo.Save(user)
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	err := repo.Create(ctx, user)
 	if err != nil {
-		t.Errorf("Save() failed to save user: %v", err)
+		t.Errorf("Create() failed to save user: %v", err)
 	}
 
-	foundUser, err := repo.FindByID(user.ID)
+	foundUser, err := repo.FindByID(ctx, user.ID)
 	if err != nil {
 		t.Errorf("FindByID() unexpected error: %v", err)
 	}
@@ -113,9 +155,10 @@ func TestUserRepository_FindByID(t *testing.T) {
 }
 
 func TestUserRepository_FindByIDNotFound(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
 
-	_, err := repo.FindByID("non-existent-id")
+	_, err := repo.FindByID(ctx, "non-existent-id")
 	if err == nil {
 		t.Errorf("FindByID() expected error, got nil")
 	}
@@ -125,14 +168,15 @@ func TestUserRepository_FindByIDNotFound(t *testing.T) {
 }
 
 func TestUserRepository_FindByEmail(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	user, _ := entity.NewUser("test@example.com", "Test User")
-	err := repo.Save(user)
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	err := repo.Create(ctx, user)
 	if err != nil {
-		t.Errorf("Save() failed to save user: %v", err)
+		t.Errorf("Create() failed to save user: %v", err)
 	}
 
-	foundUser, err := repo.FindByEmail(user.Email)
+	foundUser, err := repo.FindByEmail(ctx, user.CanonicalEmail)
 	if err != nil {
 		t.Errorf("FindByEmail() unexpected error: %v", err)
 	}
@@ -143,9 +187,10 @@ func TestUserRepository_FindByEmail(t *testing.T) {
 }
 
 func TestUserRepository_FindByEmailNotFound(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
 
-	_, err := repo.FindByEmail("notfound@example.com")
+	_, err := repo.FindByEmail(ctx, "notfound@example.com")
 	if err == nil {
 		t.Errorf("FindByEmail() expected error, got nil")
 	}
@@ -155,11 +200,12 @@ func TestUserRepository_FindByEmailNotFound(t *testing.T) {
 }
 
 func TestUserRepository_Update(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	user, _ := entity.NewUser("test@example.com", "Test User")
-	err := repo.Save(user)
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	err := repo.Create(ctx, user)
 	if err != nil {
-		t.Errorf("Save() failed to save user: %v", err)
+		t.Errorf("Create() failed to save user: %v", err)
 	}
 
 	// Update user
@@ -168,52 +214,55 @@ func TestUserRepository_Update(t *testing.T) {
 		t.Errorf("Update() failed to update user: %v", err)
 	}
 
-	err = repo.Update(user)
+	err = repo.Update(ctx, user)
 	if err != nil {
 		t.Errorf("Update() unexpected error: %v", err)
 	}
 
 	// Verify update
-	updatedUser, _ := repo.FindByID(user.ID)
+	updatedUser, _ := repo.FindByID(ctx, user.ID)
 	if updatedUser.Email != "updated@example.com" {
 		t.Errorf("Update() email not updated, got: %s", updatedUser.Email)
 	}
 }
 
 func TestUserRepository_UpdateNotFound(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	user, _ := entity.NewUser("test@example.com", "Test User")
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
 
-	err := repo.Update(user)
+	err := repo.Update(ctx, user)
 	if err != ErrUserNotFound {
 		t.Errorf("Update() expected ErrUserNotFound, got: %v", err)
 	}
 }
 
 func TestUserRepository_Delete(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	user, _ := entity.NewUser("test@example.com", "Test User")
-	err := repo.Save(user)
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	err := repo.Create(ctx, user)
 	if err != nil {
-		t.Errorf("Save() failed to save user: %v", err)
+		t.Errorf("Create() failed to save user: %v", err)
 	}
 
-	err = repo.Delete(user.ID)
+	err = repo.Delete(ctx, user.ID)
 	if err != nil {
 		t.Errorf("Delete() unexpected error: %v", err)
 	}
 
 	// Verify deletion
-	_, err = repo.FindByID(user.ID)
+	_, err = repo.FindByID(ctx, user.ID)
 	if err != ErrUserNotFound {
 		t.Errorf("Delete() user still exists after deletion")
 	}
 }
 
 func TestUserRepository_DeleteNotFound(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
 
-	err := repo.Delete("non-existent-id")
+	err := repo.Delete(ctx, "non-existent-id")
 	if err != ErrUserNotFound {
 		t.Errorf("Delete() expected ErrUserNotFound, got: %v", err)
 	}
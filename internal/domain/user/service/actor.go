@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Actors recorded for operations without an identified caller
+const (
+	// SystemActor is recorded for operations marked with ContextWithSystem
+	SystemActor = "system"
+	// AnonymousActor is recorded for operations of unauthenticated callers,
+	// such as self-registration
+	AnonymousActor = "anonymous"
+)
+
+// actorKey is the context key for the acting caller
+type actorKey struct{}
+
+// ContextWithActor returns a context that identifies who is performing operations
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the acting caller. Without one it returns
+// SystemActor if ctx was marked with ContextWithSystem and AnonymousActor
+// otherwise.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	if isSystem(ctx) {
+		return SystemActor
+	}
+	return AnonymousActor
+}
+
+// callerKey is the context key for the authenticated caller
+type callerKey struct{}
+
+// ContextWithCaller returns a context identifying the authenticated user on
+// whose behalf operations are performed. The user is also recorded as the
+// actor of the changes made.
+func ContextWithCaller(ctx context.Context, id entity.UserID) context.Context {
+	ctx = context.WithValue(ctx, callerKey{}, id)
+	return ContextWithActor(ctx, id.String())
+}
+
+// CallerFromContext returns the authenticated caller, if there is one
+func CallerFromContext(ctx context.Context) (entity.UserID, bool) {
+	id, ok := ctx.Value(callerKey{}).(entity.UserID)
+	return id, ok && id != ""
+}
+
+// systemKey is the context key marking operations of the application itself
+type systemKey struct{}
+
+// ContextWithSystem marks operations as performed by the application itself,
+// such as background jobs or internal lookups, rather than for a caller.
+// They are not subject to authorization.
+func ContextWithSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// isSystem reports whether ctx was marked with ContextWithSystem
+func isSystem(ctx context.Context) bool {
+	system, _ := ctx.Value(systemKey{}).(bool)
+	return system
+}
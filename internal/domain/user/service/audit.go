@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// redacted stands in for password hashes in audit entries
+const redacted = "[redacted]"
+
+// Audited user fields
+const (
+	fieldEmail     = "email"
+	fieldName      = "name"
+	fieldStatus    = "status"
+	fieldDeletedAt = "deleted_at"
+	fieldRoles     = "roles"
+	fieldTenant    = "tenant"
+	fieldPassword  = "password"
+)
+
+// AuditLog records who changed what and reads it back, e.g. an *audit.Log
+type AuditLog interface {
+	Record(ctx context.Context, entry audit.Entry) error
+	Query(ctx context.Context, query audit.Query) ([]audit.Entry, error)
+}
+
+// WithAuditLog makes the service record every change it stores. Entries are
+// recorded after the change has been stored, and a failure to record one
+// fails the operation, so that a caller never takes an unaudited change for
+// a complete one. The log also backs the user history, which GetUserAsOf
+// refuses to rebuild once an entry is missing.
+func WithAuditLog(auditLog AuditLog) Option {
+	return func(s *UserService) {
+		s.auditLog = auditLog
+	}
+}
+
+// ErrAuditLogUnavailable is returned by audit log reads on a service whose
+// audit log can't serve them
+var ErrAuditLogUnavailable = domainerr.New(domainerr.KindUnavailable, "audit_log_unavailable", "the audit log is not available")
+
+// auditVerifier is an AuditLog that can check its hash chain, e.g. an *audit.Log
+type auditVerifier interface {
+	Verify(ctx context.Context) (audit.Head, error)
+}
+
+// QueryAuditLog returns the audit entries matching query in sequence order.
+// Reading the audit log requires authz.ActionReadAudit.
+func (s *UserService) QueryAuditLog(ctx context.Context, query audit.Query) ([]audit.Entry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.authorize(ctx, authz.ActionReadAudit, nil); err != nil {
+		return nil, err
+	}
+	if s.auditLog == nil {
+		return nil, ErrAuditLogUnavailable
+	}
+
+	return s.auditLog.Query(ctx, query)
+}
+
+// VerifyAuditLog walks the audit log's hash chain and returns its head, or
+// an *audit.ChainError naming the first bad entry. It reads the whole log, so
+// it is not bound by the operation timeout. Verifying requires
+// authz.ActionVerifyAudit.
+func (s *UserService) VerifyAuditLog(ctx context.Context) (audit.Head, error) {
+	if err := s.authorize(ctx, authz.ActionVerifyAudit, nil); err != nil {
+		return audit.Head{}, err
+	}
+	verifier, ok := s.auditLog.(auditVerifier)
+	if !ok {
+		return audit.Head{}, ErrAuditLogUnavailable
+	}
+
+	return verifier.Verify(ctx)
+}
+
+// audit records a stored change to a user. before is the user as it was
+// loaded, or nil for a new user.
+func (s *UserService) audit(ctx context.Context, action audit.Action, before *entity.User, after *entity.User) error {
+	return s.recordAudit(ctx, audit.Entry{
+		Action:  action,
+		UserID:  after.ID,
+		Changes: userChanges(before, after),
+	})
+}
+
+// recordAudit completes entry with the actor and time and records it
+func (s *UserService) recordAudit(ctx context.Context, entry audit.Entry) error {
+	if s.auditLog == nil {
+		return nil
+	}
+
+	entry.Actor = ActorFromContext(ctx)
+	entry.At = s.clock.Now()
+	if err := s.auditLog.Record(context.WithoutCancel(ctx), entry); err != nil {
+		return fmt.Errorf("failed to record audit entry %s: %w", entry.Action, err)
+	}
+	return nil
+}
+
+// userChanges lists the audited fields that differ between before and after.
+// Password hashes are never written out, only the fact that they changed.
+func userChanges(before *entity.User, after *entity.User) []audit.FieldChange {
+	if before == nil {
+		before = &entity.User{}
+	}
+
+	var changes []audit.FieldChange
+	add := func(field string, from string, to string) {
+		if from != to {
+			changes = append(changes, audit.FieldChange{Field: field, From: from, To: to})
+		}
+	}
+
+	add(fieldEmail, string(before.Email), string(after.Email))
+	add(fieldName, before.Name, after.Name)
+	add(fieldStatus, string(before.Status), string(after.Status))
+	add(fieldDeletedAt, formatAuditTime(before.DeletedAt), formatAuditTime(after.DeletedAt))
+	add(fieldRoles, entity.FormatRoles(before.Roles), entity.FormatRoles(after.Roles))
+	add(fieldTenant, string(before.Tenant), string(after.Tenant))
+	if before.Password.Hash() != after.Password.Hash() {
+		from := redacted
+		if before.Password.IsZero() {
+			from = ""
+		}
+		changes = append(changes, audit.FieldChange{Field: fieldPassword, From: from, To: redacted})
+	}
+
+	return changes
+}
+
+// formatAuditTime formats t for an audit entry, leaving the zero time empty
+func formatAuditTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// purgeChanges describes a purge of n users
+func purgeChanges(n int64) []audit.FieldChange {
+	return []audit.FieldChange{{Field: "purged_users", To: strconv.FormatInt(n, 10)}}
+}
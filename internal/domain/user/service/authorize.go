@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+// ErrAccessCheckUnavailable is returned by CheckAccess when the authorizer can't explain its decisions
+var ErrAccessCheckUnavailable = domainerr.New(domainerr.KindUnavailable, "access_check_unavailable", "access checks are not available with this authorizer")
+
+// WithAuthorizer makes the service ask authorizer before every operation.
+// The caller is taken from the context (see ContextWithCaller); a context
+// without one is an anonymous caller, and operations marked with
+// ContextWithSystem are always allowed. Without an authorizer every
+// operation is allowed.
+func WithAuthorizer(authorizer authz.Authorizer) Option {
+	return func(s *UserService) {
+		s.authorizer = authorizer
+	}
+}
+
+// authorize checks that the caller may perform action on resource, which is
+// nil for actions that don't concern an existing user
+func (s *UserService) authorize(ctx context.Context, action authz.Action, resource *entity.User) error {
+	if s.authorizer == nil || isSystem(ctx) {
+		return nil
+	}
+
+	subject, err := s.subject(ctx)
+	if err != nil {
+		return err
+	}
+	return s.authorizer.Authorize(ctx, subject, action, resource)
+}
+
+// authorizeMissing is called when looking up the user to perform action on
+// failed with err. A missing user is only reported to callers who may perform
+// action whoever the user is; everyone else gets the denial they would get
+// for an existing user, so that lookups don't reveal which users exist.
+func (s *UserService) authorizeMissing(ctx context.Context, action authz.Action, err error) error {
+	if !errors.Is(err, repository.ErrUserNotFound) {
+		return nil
+	}
+	return s.authorize(ctx, action, nil)
+}
+
+// subject loads the caller, so that decisions are made with their current
+// roles. A caller that no longer exists or may no longer log in is treated
+// as anonymous, since tokens issued to them may still be around.
+func (s *UserService) subject(ctx context.Context) (authz.Subject, error) {
+	id, ok := CallerFromContext(ctx)
+	if !ok {
+		return authz.Subject{}, nil
+	}
+
+	caller, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return authz.Subject{}, nil
+	}
+	if err != nil {
+		return authz.Subject{}, fmt.Errorf("failed to load caller: %w", err)
+	}
+	return callerSubject(caller), nil
+}
+
+// callerSubject returns the subject for a caller, anonymous if they may no
+// longer log in
+func callerSubject(caller *entity.User) authz.Subject {
+	if !caller.CanLogIn() {
+		return authz.Subject{}
+	}
+	return authz.SubjectOf(caller)
+}
+
+// AccessCheck is a request to dry-run through the authorizer
+type AccessCheck struct {
+	// SubjectID is the caller to check for; empty for an anonymous caller
+	SubjectID entity.UserID
+	Action    authz.Action
+	// ResourceID is the user acted on; empty for actions such as create and list
+	ResourceID entity.UserID
+	// At is when the request would be made; now if zero
+	At time.Time
+}
+
+// CheckAccess explains whether a caller may perform an action, without
+// performing it. Using it requires authz.ActionCheckAccess, since it reveals
+// what others may do.
+func (s *UserService) CheckAccess(ctx context.Context, check AccessCheck) (authz.Decision, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.authorize(ctx, authz.ActionCheckAccess, nil); err != nil {
+		return authz.Decision{}, err
+	}
+	explainer, ok := s.authorizer.(authz.Explainer)
+	if !ok {
+		return authz.Decision{}, ErrAccessCheckUnavailable
+	}
+	if err := check.Action.Validate(); err != nil {
+		return authz.Decision{}, err
+	}
+
+	var subject authz.Subject
+	if check.SubjectID != "" {
+		caller, err := s.repo.FindByID(ctx, check.SubjectID)
+		if err != nil {
+			return authz.Decision{}, fmt.Errorf("failed to get subject: %w", err)
+		}
+		subject = callerSubject(caller)
+	}
+
+	var resource *entity.User
+	if check.ResourceID != "" {
+		var err error
+		if resource, err = s.findIncludingDeleted(ctx, check.ResourceID); err != nil {
+			return authz.Decision{}, fmt.Errorf("failed to get resource: %w", err)
+		}
+	}
+
+	at := check.At
+	if at.IsZero() {
+		at = s.clock.Now()
+	}
+	return explainer.Explain(ctx, subject, check.Action, resource, authz.Environment{Time: at}), nil
+}
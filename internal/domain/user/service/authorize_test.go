@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+func TestUserService_CheckAccess(t *testing.T) {
+	system := ContextWithSystem(context.Background())
+	policy, err := authz.LoadPolicy("../../authz/testdata/policy.json")
+	if err != nil {
+		t.Fatalf("LoadPolicy() unexpected error: %v", err)
+	}
+	service := NewUserService(NewMockUserRepository(),
+		WithPasswordHasher(testHasher),
+		WithAuthorizer(authz.NewPolicyAuthorizer(policy)),
+	)
+
+	support, _ := service.CreateUser(system, "support@acme.com", "Support", testPasswordPlain)
+	customer, _ := service.CreateUser(system, "customer@acme.com", "Customer", testPasswordPlain)
+	outsider, _ := service.CreateUser(system, "outsider@globex.com", "Outsider", testPasswordPlain)
+	admin, _ := service.CreateUser(system, "admin@acme.com", "Admin", testPasswordPlain)
+	_ = service.AssignRoles(system, support.ID, []entity.Role{entity.RoleSupport})
+	_ = service.AssignRoles(system, admin.ID, []entity.Role{entity.RoleAdmin})
+	_ = service.AssignTenant(system, support.ID, "acme")
+	_ = service.AssignTenant(system, customer.ID, "acme")
+	_ = service.AssignTenant(system, outsider.ID, "globex")
+	asAdmin := ContextWithCaller(context.Background(), admin.ID)
+
+	wednesday := time.Date(2024, 5, 8, 8, 0, 0, 0, time.UTC) // 10:00 in Belgrade
+	decision, err := service.CheckAccess(asAdmin, AccessCheck{
+		SubjectID: support.ID, Action: authz.ActionSuspend, ResourceID: customer.ID, At: wednesday,
+	})
+	if err != nil {
+		t.Fatalf("CheckAccess() unexpected error: %v", err)
+	}
+	if !decision.Allowed || decision.Rule != "support-edit-own-tenant" {
+		t.Errorf("CheckAccess() = %+v, want allowed by support-edit-own-tenant", decision)
+	}
+
+	decision, _ = service.CheckAccess(asAdmin, AccessCheck{
+		SubjectID: support.ID, Action: authz.ActionSuspend, ResourceID: outsider.ID, At: wednesday,
+	})
+	if decision.Allowed {
+		t.Errorf("CheckAccess() on another tenant = %+v, want denied", decision)
+	}
+
+	decision, _ = service.CheckAccess(asAdmin, AccessCheck{
+		SubjectID: support.ID, Action: authz.ActionSuspend, ResourceID: customer.ID, At: wednesday.Add(10 * time.Hour),
+	})
+	if decision.Allowed {
+		t.Errorf("CheckAccess() in the evening = %+v, want denied", decision)
+	}
+
+	// Dry runs change nothing
+	if user, _ := service.GetUserByID(system, customer.ID); user.Status != entity.StatusPending {
+		t.Errorf("CheckAccess() changed the user's status to %s", user.Status)
+	}
+
+	asSupport := ContextWithCaller(context.Background(), support.ID)
+	if _, err := service.CheckAccess(asSupport, AccessCheck{Action: authz.ActionList}); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("CheckAccess() by support expected ErrForbidden, got: %v", err)
+	}
+	if _, err := service.CheckAccess(asAdmin, AccessCheck{Action: "user.fly"}); !errors.Is(err, authz.ErrInvalidAction) {
+		t.Errorf("CheckAccess() of an unknown action expected ErrInvalidAction, got: %v", err)
+	}
+	if _, err := service.CheckAccess(asAdmin, AccessCheck{Action: authz.ActionRead, ResourceID: "user_missing"}); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Errorf("CheckAccess() of a missing resource expected ErrUserNotFound, got: %v", err)
+	}
+
+	unexplained := NewUserService(NewMockUserRepository(), WithPasswordHasher(testHasher))
+	if _, err := unexplained.CheckAccess(system, AccessCheck{Action: authz.ActionRead}); !errors.Is(err, ErrAccessCheckUnavailable) {
+		t.Errorf("CheckAccess() without an authorizer expected ErrAccessCheckUnavailable, got: %v", err)
+	}
+
+	// Callers are turned away before learning that the authorizer can't explain itself
+	opaque := NewUserService(NewMockUserRepository(), WithPasswordHasher(testHasher), WithAuthorizer(denyAll{}))
+	if _, err := opaque.CheckAccess(context.Background(), AccessCheck{Action: authz.ActionRead}); !errors.Is(err, authz.ErrAuthenticationRequired) {
+		t.Errorf("CheckAccess() anonymously expected ErrAuthenticationRequired, got: %v", err)
+	}
+	if _, err := opaque.CheckAccess(system, AccessCheck{Action: authz.ActionRead}); !errors.Is(err, ErrAccessCheckUnavailable) {
+		t.Errorf("CheckAccess() with an authorizer that can't explain expected ErrAccessCheckUnavailable, got: %v", err)
+	}
+}
+
+// denyAll is an Authorizer that denies everything without explaining why
+type denyAll struct{}
+
+func (denyAll) Authorize(ctx context.Context, subject authz.Subject, action authz.Action, resource *entity.User) error {
+	return authz.Deny(subject, action)
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+// History errors
+var (
+	// ErrHistoryUnavailable is returned by history lookups on a service without an audit log
+	ErrHistoryUnavailable = domainerr.New(domainerr.KindUnavailable, "history_unavailable", "user history is not available without an audit log")
+	// ErrHistoryIncomplete is returned by GetUserAsOf when the audit log misses
+	// some of a user's changes, so the user can't be rebuilt. Retrying does not
+	// help; GetUserHistory still lists the changes that were recorded.
+	ErrHistoryIncomplete = domainerr.New(domainerr.KindConflict, "history_incomplete", "user history is incomplete: some changes were not audited, so past versions can't be rebuilt")
+)
+
+// GetUserHistory returns the audit entries of every recorded change to a
+// user, oldest first. Soft-deleted users keep their history.
+func (s *UserService) GetUserHistory(ctx context.Context, id entity.UserID) ([]audit.Entry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.findIncludingDeleted(ctx, id)
+	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionReadHistory, err); denied != nil {
+			return nil, denied
+		}
+		return nil, fmt.Errorf("failed to get user history: %w", err)
+	}
+	if err := s.authorize(ctx, authz.ActionReadHistory, user); err != nil {
+		return nil, err
+	}
+
+	entries, err := s.userHistory(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetUserAsOf returns a user as it was at the given time, soft-deleted or
+// not. A repository that is a repository.PastUserFinder rebuilds it from its
+// own record of every change. Otherwise GetUserAsOf starts from the current
+// user and undoes, newest first, the audited changes made after at; every
+// stored change bumps the user's version and is audited once, so if there
+// are fewer entries than the version some were lost, and
+// ErrHistoryIncomplete is returned rather than a wrongly rebuilt user.
+// The returned user has no password; it is meant for reading, not for storing.
+func (s *UserService) GetUserAsOf(ctx context.Context, id entity.UserID, at time.Time) (*entity.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	current, err := s.findIncludingDeleted(ctx, id)
+	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionReadHistory, err); denied != nil {
+			return nil, denied
+		}
+		return nil, fmt.Errorf("failed to get user as of %s: %w", at.Format(time.RFC3339), err)
+	}
+	if err := s.authorize(ctx, authz.ActionReadHistory, current); err != nil {
+		return nil, err
+	}
+
+	if finder, ok := s.repo.(repository.PastUserFinder); ok {
+		user, err := finder.FindByIDAsOf(ctx, id, at)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user as of %s: %w", at.Format(time.RFC3339), err)
+		}
+		user.Password = entity.Password{}
+		return user, nil
+	}
+
+	user := *current
+	if user.CreatedAt.After(at) {
+		return nil, fmt.Errorf("failed to get user as of %s: %w", at.Format(time.RFC3339), repository.ErrUserNotFound)
+	}
+
+	entries, err := s.userHistory(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user as of %s: %w", at.Format(time.RFC3339), err)
+	}
+	if int64(len(entries)) != current.Version {
+		return nil, ErrHistoryIncomplete.Withf("%d audit entries for version %d", len(entries), current.Version)
+	}
+
+	kept := len(entries)
+	for kept > 0 && entries[kept-1].At.After(at) {
+		kept--
+		if entries[kept].Action == audit.ActionCreate {
+			continue // CreatedAt already says the user existed
+		}
+		if err := s.undo(&user, entries[kept]); err != nil {
+			return nil, fmt.Errorf("failed to undo audit entry %d: %w", entries[kept].Sequence, err)
+		}
+		user.Version--
+	}
+
+	if kept < len(entries) {
+		user.UpdatedAt = user.CreatedAt
+		if kept > 0 && entries[kept-1].Action != audit.ActionCreate {
+			user.UpdatedAt = entries[kept-1].At
+		}
+	}
+
+	var history []entity.StatusTransition
+	for _, transition := range user.StatusHistory {
+		if !transition.At.After(at) {
+			history = append(history, transition)
+		}
+	}
+	user.StatusHistory = history
+	user.Password = entity.Password{}
+
+	return &user, nil
+}
+
+// findIncludingDeleted looks a user up by ID whether or not it is soft-deleted
+func (s *UserService) findIncludingDeleted(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	user, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return s.repo.FindDeletedByID(ctx, id)
+	}
+	return user, err
+}
+
+// userHistory reads every audit entry of a user, oldest first
+func (s *UserService) userHistory(ctx context.Context, id entity.UserID) ([]audit.Entry, error) {
+	if s.auditLog == nil {
+		return nil, ErrHistoryUnavailable
+	}
+
+	var (
+		entries []audit.Entry
+		after   int64
+	)
+	for {
+		page, err := s.auditLog.Query(ctx, audit.Query{UserID: id, AfterSequence: after, Limit: audit.MaxQueryLimit})
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, page...)
+		if len(page) < audit.MaxQueryLimit {
+			return entries, nil
+		}
+		after = page[len(page)-1].Sequence
+	}
+}
+
+// undo sets the fields changed by entry back to their values before it
+func (s *UserService) undo(user *entity.User, entry audit.Entry) error {
+	for _, change := range entry.Changes {
+		switch change.Field {
+		case fieldEmail:
+			canonical, err := s.emailNormalizer.Canonical(change.From)
+			if err != nil {
+				return fmt.Errorf("invalid previous email: %w", err)
+			}
+			user.Email = entity.Email(change.From)
+			user.CanonicalEmail = canonical
+		case fieldName:
+			user.Name = change.From
+		case fieldStatus:
+			user.Status = entity.UserStatus(change.From)
+		case fieldRoles:
+			user.Roles = entity.ParseRoles(change.From)
+		case fieldTenant:
+			user.Tenant = entity.Tenant(change.From)
+		case fieldDeletedAt:
+			user.DeletedAt = time.Time{}
+			if change.From != "" {
+				deletedAt, err := time.Parse(time.RFC3339Nano, change.From)
+				if err != nil {
+					return fmt.Errorf("invalid previous deletion time: %w", err)
+				}
+				user.DeletedAt = deletedAt
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/eventstore"
+)
+
+func TestUserService_GetUserAsOf(t *testing.T) {
+	repos := map[string]func(t *testing.T) repository.UserRepository{
+		"rebuilt from the audit log": func(t *testing.T) repository.UserRepository {
+			return NewMockUserRepository()
+		},
+		"replayed from the event store": func(t *testing.T) repository.UserRepository {
+			repo, err := eventstore.NewUserRepository(context.Background(), eventstore.NewMemoryStore())
+			if err != nil {
+				t.Fatalf("NewUserRepository() unexpected error: %v", err)
+			}
+			return repo
+		},
+	}
+	for name, newRepo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testGetUserAsOf(t, newRepo(t))
+		})
+	}
+}
+
+func testGetUserAsOf(t *testing.T, repo repository.UserRepository) {
+	ctx := ContextWithActor(context.Background(), "support")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFake(start)
+	service := NewUserService(repo,
+		WithPasswordHasher(testHasher),
+		WithClock(fakeClock),
+		WithAuditLog(&recordingAuditLog{}),
+	)
+
+	user, _ := service.CreateUser(ctx, "first@example.com", "Test User", testPasswordPlain)
+	fakeClock.Advance(24 * time.Hour)
+	_ = service.ActivateUser(ctx, user.ID, "verified")
+	fakeClock.Advance(24 * time.Hour)
+	_ = service.UpdateUser(ctx, user.ID, "second@example.com", "Renamed", AnyVersion)
+	_ = service.AssignTenant(ContextWithSystem(ctx), user.ID, "acme")
+	fakeClock.Advance(24 * time.Hour)
+	_ = service.DeleteUser(ctx, user.ID, "")
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		email    entity.Email
+		userName string
+		status   entity.UserStatus
+		tenant   entity.Tenant
+		version  int64
+	}{
+		{"at creation", start, "first@example.com", "Test User", entity.StatusPending, "", 1},
+		{"after activation", start.Add(36 * time.Hour), "first@example.com", "Test User", entity.StatusActive, "", 2},
+		{"after the update", start.Add(60 * time.Hour), "second@example.com", "Renamed", entity.StatusActive, "acme", 4},
+		{"after deletion", start.Add(96 * time.Hour), "second@example.com", "Renamed", entity.StatusDeleted, "acme", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.GetUserAsOf(ctx, user.ID, tt.at)
+			if err != nil {
+				t.Fatalf("GetUserAsOf() unexpected error: %v", err)
+			}
+			if got.Email != tt.email || got.Name != tt.userName || got.Status != tt.status || got.Tenant != tt.tenant || got.Version != tt.version {
+				t.Errorf("GetUserAsOf() = %s %q %s %q v%d, want %s %q %s %q v%d",
+					got.Email, got.Name, got.Status, got.Tenant, got.Version, tt.email, tt.userName, tt.status, tt.tenant, tt.version)
+			}
+			if got.IsDeleted() != !got.DeletedAt.IsZero() {
+				t.Errorf("GetUserAsOf() status %s with DeletedAt %v", got.Status, got.DeletedAt)
+			}
+			for _, transition := range got.StatusHistory {
+				if transition.At.After(tt.at) {
+					t.Errorf("GetUserAsOf() kept a later transition %+v", transition)
+				}
+			}
+			if !got.Password.IsZero() {
+				t.Error("GetUserAsOf() returned a password hash")
+			}
+		})
+	}
+
+	if _, err := service.GetUserAsOf(ctx, user.ID, start.Add(-time.Hour)); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Errorf("GetUserAsOf() before creation expected ErrUserNotFound, got: %v", err)
+	}
+}
+
+// lossyAuditLog fails to record entries while failing is set
+type lossyAuditLog struct {
+	recordingAuditLog
+	failing bool
+}
+
+func (l *lossyAuditLog) Record(ctx context.Context, entry audit.Entry) error {
+	if l.failing {
+		return errors.New("audit log unavailable")
+	}
+	return l.recordingAuditLog.Record(ctx, entry)
+}
+
+func TestUserService_GetUserAsOfDetectsLostEntries(t *testing.T) {
+	ctx := context.Background()
+	auditLog := &lossyAuditLog{}
+	service := NewUserService(NewMockUserRepository(), WithPasswordHasher(testHasher), WithAuditLog(auditLog))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	auditLog.failing = true
+	if err := service.UpdateUser(ctx, user.ID, "test@example.com", "Renamed", AnyVersion); err == nil {
+		t.Errorf("UpdateUser() with a failing audit log expected an error")
+	}
+	auditLog.failing = false
+	_ = service.ActivateUser(ctx, user.ID, "verified")
+
+	_, err := service.GetUserAsOf(ctx, user.ID, time.Now())
+	if !errors.Is(err, ErrHistoryIncomplete) {
+		t.Errorf("GetUserAsOf() with a lost audit entry expected ErrHistoryIncomplete, got: %v", err)
+	}
+	if kind := domainerr.KindOf(err); kind != domainerr.KindConflict {
+		t.Errorf("GetUserAsOf() with a lost audit entry kind = %s, want %s", kind, domainerr.KindConflict)
+	}
+}
+
+func TestUserService_GetUserAsOfAfterNoOpChanges(t *testing.T) {
+	ctx := context.Background()
+	repo, err := eventstore.NewUserRepository(ctx, eventstore.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("NewUserRepository() unexpected error: %v", err)
+	}
+	auditLog := &recordingAuditLog{}
+	service := NewUserService(repo, WithPasswordHasher(testHasher), WithAuditLog(auditLog))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	if err := service.UpdateUser(ctx, user.ID, "test@example.com", "Test User", AnyVersion); err != nil {
+		t.Fatalf("UpdateUser() without changes unexpected error: %v", err)
+	}
+	if err := service.AssignRoles(ctx, user.ID, nil); err != nil {
+		t.Fatalf("AssignRoles() without changes unexpected error: %v", err)
+	}
+	_ = service.UpdateUser(ctx, user.ID, "test@example.com", "Renamed", AnyVersion)
+
+	if len(auditLog.entries) != 2 {
+		t.Errorf("recorded %d audit entries, want 2 for the creation and the rename", len(auditLog.entries))
+	}
+	if history, err := service.GetUserHistory(ctx, user.ID); err != nil || len(history) != 2 {
+		t.Errorf("GetUserHistory() = %d entries, %v, want 2", len(history), err)
+	}
+	got, err := service.GetUserAsOf(ctx, user.ID, time.Now())
+	if err != nil || got.Name != "Renamed" || got.Version != 2 {
+		t.Errorf("GetUserAsOf() = %+v, %v, want the renamed user at version 2", got, err)
+	}
+}
+
+func TestUserService_GetUserHistory(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "support")
+	service := NewUserService(NewMockUserRepository(), WithPasswordHasher(testHasher), WithAuditLog(&recordingAuditLog{}))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	_, _ = service.CreateUser(ctx, "other@example.com", "Other User", testPasswordPlain)
+	_ = service.UpdateUser(ctx, user.ID, "test@example.com", "Renamed", AnyVersion)
+	_ = service.DeleteUser(ctx, user.ID, "")
+
+	history, err := service.GetUserHistory(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserHistory() unexpected error: %v", err)
+	}
+
+	want := []audit.Action{audit.ActionCreate, audit.ActionUpdate, audit.ActionDelete}
+	if len(history) != len(want) {
+		t.Fatalf("GetUserHistory() returned %d entries, want %d", len(history), len(want))
+	}
+	for i, entry := range history {
+		if entry.Action != want[i] || entry.UserID != user.ID || entry.Actor != "support" {
+			t.Errorf("entry %d = %+v, want %s of %s", i, entry, want[i], user.ID)
+		}
+	}
+
+	if _, err := service.GetUserHistory(ctx, "user_01HV6X5Q9Z3K8M2N4P7R0T1W2Y"); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Errorf("GetUserHistory() of unknown user expected ErrUserNotFound, got: %v", err)
+	}
+
+	withoutLog := NewUserService(NewMockUserRepository(), WithPasswordHasher(testHasher))
+	created, _ := withoutLog.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	if _, err := withoutLog.GetUserHistory(ctx, created.ID); !errors.Is(err, ErrHistoryUnavailable) {
+		t.Errorf("GetUserHistory() without audit log expected ErrHistoryUnavailable, got: %v", err)
+	}
+}
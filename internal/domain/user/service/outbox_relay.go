@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+// DefaultRelayInterval is how often an OutboxRelay polls for new events
+const DefaultRelayInterval = time.Second
+
+// DefaultRelayBatchSize is how many events an OutboxRelay publishes at a time
+const DefaultRelayBatchSize = 100
+
+// EventDispatcher delivers the domain events recorded on users to the rest of
+// the system, e.g. an event bus. An error means the events may not all have
+// been delivered, and they will be dispatched again.
+type EventDispatcher interface {
+	Dispatch(ctx context.Context, events []entity.Event) error
+}
+
+// EventDispatcherFunc adapts a function to an EventDispatcher
+type EventDispatcherFunc func(ctx context.Context, events []entity.Event) error
+
+// Dispatch calls f(ctx, events)
+func (f EventDispatcherFunc) Dispatch(ctx context.Context, events []entity.Event) error {
+	return f(ctx, events)
+}
+
+// OutboxRelay publishes the events that repositories write to the outbox.
+// Delivery is at least once: an event is removed from the outbox only after
+// it has been dispatched without error, so a crash, a failed dispatch or a
+// failure to remove it means it is dispatched again. Consumers use
+// Event.EventID to drop duplicates.
+//
+// The guarantee only reaches as far as the dispatcher's, so a dispatcher
+// must not return before the events have been handled; an event bus's
+// Dispatch waits for its async subscribers for that reason.
+type OutboxRelay struct {
+	outbox     repository.Outbox
+	dispatcher EventDispatcher
+	interval   time.Duration
+	batchSize  int
+}
+
+// NewOutboxRelay creates an OutboxRelay that polls every interval.
+// A zero or negative interval means DefaultRelayInterval.
+func NewOutboxRelay(outbox repository.Outbox, dispatcher EventDispatcher, interval time.Duration) *OutboxRelay {
+	if interval <= 0 {
+		interval = DefaultRelayInterval
+	}
+
+	return &OutboxRelay{
+		outbox:     outbox,
+		dispatcher: dispatcher,
+		interval:   interval,
+		batchSize:  DefaultRelayBatchSize,
+	}
+}
+
+// Run drains the outbox once immediately and then every interval, until ctx
+// is done. Failures are logged and retried on the next tick.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Drain(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to relay outbox events: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain dispatches pending events, oldest first, until the outbox is empty
+func (r *OutboxRelay) Drain(ctx context.Context) error {
+	for {
+		events, err := r.outbox.PendingEvents(ctx, r.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		if err := r.dispatcher.Dispatch(ctx, events); err != nil {
+			return fmt.Errorf("failed to dispatch events: %w", err)
+		}
+
+		ids := make([]string, len(events))
+		for i, event := range events {
+			ids[i] = event.EventID()
+		}
+		if err := r.outbox.MarkEventsPublished(ctx, ids...); err != nil {
+			return err
+		}
+
+		if len(events) < r.batchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// flakyOutbox fails to mark events as published while failing is set
+type flakyOutbox struct {
+	*MockUserRepository
+	failing bool
+}
+
+func (o *flakyOutbox) MarkEventsPublished(ctx context.Context, ids ...string) error {
+	if o.failing {
+		return errors.New("outbox unavailable")
+	}
+	return o.MockUserRepository.MarkEventsPublished(ctx, ids...)
+}
+
+func TestOutboxRelay_AtLeastOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+	_, _ = service.CreateUser(ctx, "first@example.com", "First", testPasswordPlain)
+	_, _ = service.CreateUser(ctx, "second@example.com", "Second", testPasswordPlain)
+
+	var dispatched []entity.Event
+	dispatcher := EventDispatcherFunc(func(ctx context.Context, events []entity.Event) error {
+		dispatched = append(dispatched, events...)
+		return nil
+	})
+	outbox := &flakyOutbox{MockUserRepository: repo, failing: true}
+	relay := NewOutboxRelay(outbox, dispatcher, time.Millisecond)
+	relay.batchSize = 1
+
+	if err := relay.Drain(ctx); err == nil {
+		t.Fatalf("Drain() expected error when events can't be marked published")
+	}
+
+	outbox.failing = false
+	if err := relay.Drain(ctx); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+
+	// The first event was redelivered with the same ID
+	if len(dispatched) != 3 || dispatched[0].EventID() != dispatched[1].EventID() {
+		t.Fatalf("dispatched %d events, want the first one twice and then the second", len(dispatched))
+	}
+	if pending, _ := repo.PendingEvents(ctx, 10); len(pending) != 0 {
+		t.Errorf("outbox still holds %d events", len(pending))
+	}
+}
+
+func TestOutboxRelay_DispatchFailureKeepsEvents(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+	_, _ = service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+
+	failing := true
+	var dispatched []entity.Event
+	dispatcher := EventDispatcherFunc(func(ctx context.Context, events []entity.Event) error {
+		if failing {
+			return errors.New("bus closed")
+		}
+		dispatched = append(dispatched, events...)
+		return nil
+	})
+	relay := NewOutboxRelay(repo, dispatcher, time.Millisecond)
+
+	if err := relay.Drain(ctx); err == nil {
+		t.Fatalf("Drain() expected error when dispatching fails")
+	}
+	if pending, _ := repo.PendingEvents(ctx, 10); len(pending) != 1 {
+		t.Fatalf("outbox holds %d events after a failed dispatch, want 1", len(pending))
+	}
+
+	failing = false
+	if err := relay.Drain(ctx); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+	if len(dispatched) != 1 {
+		t.Errorf("dispatched %d events after recovering, want 1", len(dispatched))
+	}
+	if pending, _ := repo.PendingEvents(ctx, 10); len(pending) != 0 {
+		t.Errorf("outbox still holds %d events", len(pending))
+	}
+}
+
+func TestOutboxRelay_Run(t *testing.T) {
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	delivered := make(chan entity.Event, 10)
+	dispatcher := EventDispatcherFunc(func(ctx context.Context, events []entity.Event) error {
+		for _, event := range events {
+			delivered <- event
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewOutboxRelay(repo, dispatcher, time.Millisecond).Run(ctx)
+		close(done)
+	}()
+
+	user, _ := service.CreateUser(context.Background(), "test@example.com", "Test User", testPasswordPlain)
+
+	select {
+	case event := <-delivered:
+		if event.EventName() != entity.EventUserRegistered || event.AggregateID() != user.ID {
+			t.Errorf("Run() delivered %s for %s", event.EventName(), event.AggregateID())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Run() did not deliver the event")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Run() did not stop after the context was cancelled")
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DefaultPurgeInterval is how often a Purger looks for users to purge
+const DefaultPurgeInterval = time.Hour
+
+// Purger periodically and permanently removes users whose deletion grace
+// period has passed
+type Purger struct {
+	service  *UserService
+	interval time.Duration
+}
+
+// NewPurger creates a Purger that runs every interval.
+// A zero or negative interval means DefaultPurgeInterval.
+func NewPurger(service *UserService, interval time.Duration) *Purger {
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+
+	return &Purger{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run purges once immediately and then every interval, until ctx is done.
+// Failures are logged and retried on the next tick.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	purged, err := p.service.PurgeDeletedUsers(ContextWithSystem(ctx))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to purge deleted users: %v", err)
+		}
+		return
+	}
+
+	if purged > 0 {
+		log.Printf("Purged %d deleted users", purged)
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPurger_Run(t *testing.T) {
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher), WithDeletionGracePeriod(-time.Hour))
+
+	user, _ := service.CreateUser(context.Background(), "test@example.com", "Test User", testPasswordPlain)
+	_ = service.DeleteUser(context.Background(), user.ID, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewPurger(service, time.Millisecond).Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := repo.FindDeletedByID(context.Background(), user.ID); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Run() did not purge the deleted user")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Run() did not stop after the context was cancelled")
+	}
+}
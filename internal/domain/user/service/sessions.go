@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Sessions ends the server-side sessions of a user, e.g. an auth.SessionStore
+type Sessions interface {
+	DeleteByUser(ctx context.Context, userID entity.UserID) error
+}
+
+// WithSessions makes suspending, deactivating or deleting a user end all of
+// the user's sessions. The sessions are ended after the change has been
+// stored; a failure to end them is logged, and the sessions that are left
+// are refused when they are next used because of the user's status.
+func WithSessions(sessions Sessions) Option {
+	return func(s *UserService) {
+		s.sessions = sessions
+	}
+}
+
+// endSessions ends the user's sessions if the user's status no longer allows
+// being logged in
+func (s *UserService) endSessions(ctx context.Context, user *entity.User) {
+	if s.sessions == nil || user.CanLogIn() {
+		return
+	}
+	if err := s.sessions.DeleteByUser(context.WithoutCancel(ctx), user.ID); err != nil {
+		log.Printf("Failed to end sessions of user %s: %v", user.ID, err)
+	}
+}
@@ -1,108 +1,658 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
 	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
 	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
 )
 
+// DefaultOperationTimeout bounds how long a single service operation may take
+const DefaultOperationTimeout = 5 * time.Second
+
+// DefaultDeletionGracePeriod is how long a deleted user can still be restored
+const DefaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// AnyVersion makes an update apply to whatever version of the user is current
+const AnyVersion int64 = 0
+
+// ErrInvalidCredentials is returned when an email/password pair does not
+// match, or a current password is wrong. It deliberately does not say which
+// of the two was wrong. The caller failed to prove who they are, so it is
+// unauthenticated rather than forbidden.
+var ErrInvalidCredentials = domainerr.New(domainerr.KindUnauthenticated, "invalid_credentials", "invalid credentials")
+
 // UserService handles business logic for user operations
 type UserService struct {
-	repo repository.UserRepository
+	repo                repository.UserRepository
+	timeout             time.Duration
+	passwordPolicy      entity.PasswordPolicy
+	passwordHasher      entity.PasswordHasher
+	idGenerator         entity.IDGenerator
+	clock               clock.Clock
+	emailNormalizer     entity.EmailNormalizer
+	deletionGracePeriod time.Duration
+	auditLog            AuditLog
+	sessions            Sessions
+	authorizer          authz.Authorizer
+
+	// dummyPassword is verified against when a login names an unknown user,
+	// so that response times don't reveal which emails are registered
+	dummyPasswordOnce sync.Once
+	dummyPassword     entity.Password
+}
+
+// Option configures a UserService
+type Option func(*UserService)
+
+// WithOperationTimeout sets the deadline applied to each service operation.
+// A zero or negative duration disables the per-operation deadline, leaving
+// only the deadline of the caller's context.
+func WithOperationTimeout(timeout time.Duration) Option {
+	return func(s *UserService) {
+		s.timeout = timeout
+	}
+}
+
+// WithPasswordPolicy sets the strength policy applied to new passwords
+func WithPasswordPolicy(policy entity.PasswordPolicy) Option {
+	return func(s *UserService) {
+		s.passwordPolicy = policy
+	}
+}
+
+// WithPasswordHasher sets the hashing parameters for new passwords.
+// Existing hashes made with other parameters are upgraded on the next successful login.
+func WithPasswordHasher(hasher entity.PasswordHasher) Option {
+	return func(s *UserService) {
+		s.passwordHasher = hasher
+	}
+}
+
+// WithIDGenerator sets the generator used for the IDs of new users
+func WithIDGenerator(generator entity.IDGenerator) Option {
+	return func(s *UserService) {
+		s.idGenerator = generator
+	}
+}
+
+// WithClock sets the clock used to timestamp user changes
+func WithClock(c clock.Clock) Option {
+	return func(s *UserService) {
+		s.clock = c
+	}
+}
+
+// WithEmailNormalizer sets how emails are canonicalized for uniqueness and
+// lookups, e.g. to apply entity.GmailRule. Changing it on an existing database
+// does not recompute stored canonical emails.
+func WithEmailNormalizer(normalizer entity.EmailNormalizer) Option {
+	return func(s *UserService) {
+		s.emailNormalizer = normalizer
+	}
+}
+
+// WithDeletionGracePeriod sets how long soft-deleted users can be restored
+// before PurgeDeletedUsers removes them
+func WithDeletionGracePeriod(period time.Duration) Option {
+	return func(s *UserService) {
+		s.deletionGracePeriod = period
+	}
 }
 
 // NewUserService creates a new UserService instance
-func NewUserService(repo repository.UserRepository) *UserService {
-	return &UserService{
-		repo: repo,
+func NewUserService(repo repository.UserRepository, opts ...Option) *UserService {
+	s := &UserService{
+		repo:                repo,
+		timeout:             DefaultOperationTimeout,
+		passwordPolicy:      entity.DefaultPasswordPolicy(),
+		passwordHasher:      entity.DefaultPasswordHasher,
+		idGenerator:         entity.DefaultIDGenerator,
+		clock:               clock.System,
+		emailNormalizer:     entity.DefaultEmailNormalizer,
+		deletionGracePeriod: DefaultDeletionGracePeriod,
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
+}
+
+// withTimeout derives the context used by a single operation.
+// The caller's deadline still applies if it is earlier.
+func (s *UserService) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.timeout)
 }
 
 // CreateUser creates a new user with validation
-func (s *UserService) CreateUser(email string, name string) (*entity.User, error) {
-	// Check if user already exists with this email
-	existingUser, err := s.repo.FindByEmail(entity.Email(email))
-	if err == nil && existingUser != nil {
-		return nil, repository.ErrUserAlreadyExists
+func (s *UserService) CreateUser(ctx context.Context, email string, name string, password string) (*entity.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.authorize(ctx, authz.ActionCreate, nil); err != nil {
+		return nil, err
+	}
+
+	// Check every field before the slow hash so all violations are reported together
+	if err := entity.JoinValidationErrors(
+		entity.ValidateProfile(email, name, s.emailNormalizer),
+		s.passwordPolicy.Validate(password),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// Hash password
+	hashed, err := entity.NewPassword(password, s.passwordPolicy, s.passwordHasher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
 	// Create new user
-	user, err := entity.NewUser(email, name)
+	user, err := entity.NewUser(email, name, hashed,
+		entity.WithIDGenerator(s.idGenerator),
+		entity.WithClock(s.clock),
+		entity.WithEmailNormalizer(s.emailNormalizer),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Save user
-	if err := s.repo.Save(user); err != nil {
+	// Save user; the repository rejects a duplicate email atomically
+	if err := s.repo.Create(ctx, user); err != nil {
 		return nil, fmt.Errorf("failed to save user: %w", err)
 	}
+	if err := s.audit(ctx, audit.ActionCreate, nil, user); err != nil {
+		return nil, err
+	}
 
 	return user, nil
 }
 
 // GetUserByID retrieves a user by their ID
-func (s *UserService) GetUserByID(id entity.UserID) (*entity.User, error) {
-	user, err := s.repo.FindByID(id)
+func (s *UserService) GetUserByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.repo.FindByID(ctx, id)
 	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionRead, err); denied != nil {
+			return nil, denied
+		}
 		return nil, fmt.Errorf("failed to get user by ID: %w", err)
 	}
+	if err := s.authorize(ctx, authz.ActionRead, user); err != nil {
+		return nil, err
+	}
 
 	return user, nil
 }
 
 // GetUserByEmail retrieves a user by their email
-func (s *UserService) GetUserByEmail(email string) (*entity.User, error) {
-	user, err := s.repo.FindByEmail(entity.Email(email))
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.findByEmail(ctx, email)
 	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionRead, err); denied != nil {
+			return nil, denied
+		}
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
+	if err := s.authorize(ctx, authz.ActionRead, user); err != nil {
+		return nil, err
+	}
 
 	return user, nil
 }
 
-// UpdateUser updates an existing user
-func (s *UserService) UpdateUser(id entity.UserID, email string, name string) error {
+// ListUsers returns one page of users matching the query.
+// Pass the returned NextCursor in the next query to fetch the following page.
+func (s *UserService) ListUsers(ctx context.Context, query repository.ListQuery) (*repository.UserPage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.authorize(ctx, authz.ActionList, nil); err != nil {
+		return nil, err
+	}
+
+	query, err := query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	if query.Filter.EmailDomain != "" {
+		domain, err := s.emailNormalizer.CanonicalDomain(query.Filter.EmailDomain)
+		if err != nil {
+			return nil, repository.ErrInvalidListQuery.Withf("invalid email domain %q", query.Filter.EmailDomain)
+		}
+		query.Filter.EmailDomain = domain
+	}
+
+	page, err := s.repo.List(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return page, nil
+}
+
+// UpdateUser updates an existing user.
+// Unless expectedVersion is AnyVersion, the update fails with a
+// *repository.VersionConflictError if the user is no longer at that version.
+func (s *UserService) UpdateUser(
+	ctx context.Context,
+	id entity.UserID,
+	email string,
+	name string,
+	expectedVersion int64,
+) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	// Get existing user
-	user, err := s.repo.FindByID(id)
+	user, err := s.findForChange(ctx, id)
 	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionUpdate, err); denied != nil {
+			return denied
+		}
 		return fmt.Errorf("failed to find user for update: %w", err)
 	}
+	if err := s.authorize(ctx, authz.ActionUpdate, user); err != nil {
+		return err
+	}
+
+	if expectedVersion != AnyVersion && user.Version != expectedVersion {
+		return &repository.VersionConflictError{ID: id, Expected: expectedVersion, Actual: user.Version}
+	}
+	before := *user
 
 	// Update user fields
 	if err := user.Update(email, name); err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
+	if unchanged(user) {
+		return nil
+	}
 
 	// Save updated user
-	if err := s.repo.Update(user); err != nil {
+	if err := s.repo.Update(ctx, user); err != nil {
 		return fmt.Errorf("failed to save updated user: %w", err)
 	}
+	if err := s.audit(ctx, audit.ActionUpdate, &before, user); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ChangePassword replaces a user's password after verifying the current one
+func (s *UserService) ChangePassword(ctx context.Context, id entity.UserID, currentPassword string, newPassword string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.findForChange(ctx, id)
+	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionChangePassword, err); denied != nil {
+			return denied
+		}
+		return fmt.Errorf("failed to find user for password change: %w", err)
+	}
+	if err := s.authorize(ctx, authz.ActionChangePassword, user); err != nil {
+		return err
+	}
+
+	if !user.Password.Verify(currentPassword) {
+		return ErrInvalidCredentials
+	}
+
+	hashed, err := entity.NewPassword(newPassword, s.passwordPolicy, s.passwordHasher)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	before := *user
+	if err := user.ChangePassword(hashed); err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to save changed password: %w", err)
+	}
+	if err := s.audit(ctx, audit.ActionChangePassword, &before, user); err != nil {
+		return err
+	}
 
 	return nil
 }
 
-// DeleteUser removes a user by their ID
-func (s *UserService) DeleteUser(id entity.UserID) error {
-	// First check if user exists
-	_, err := s.repo.FindByID(id)
+// Authenticate verifies an email/password pair and returns the matching user.
+// If the stored hash was made with outdated parameters and the user may log
+// in, it is transparently upgraded; a failure to persist the new hash does
+// not fail the login.
+func (s *UserService) Authenticate(ctx context.Context, email string, password string) (*entity.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.findByEmail(ctx, email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		s.getDummyPassword().Verify(password)
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
+		return nil, fmt.Errorf("failed to find user for authentication: %w", err)
+	}
+
+	if !user.Password.Verify(password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.CanLogIn() && s.passwordHasher.NeedsRehash(user.Password) {
+		if rehashed, err := s.passwordHasher.Hash(password); err == nil {
+			before := *user
+			_ = user.ChangePassword(rehashed) // a fresh hash is never zero
+			if err := s.repo.Update(ctx, user); err != nil {
+				log.Printf("Failed to persist rehashed password for user %s: %v", user.ID, err)
+			} else if err := s.audit(ctx, audit.ActionRehashPassword, &before, user); err != nil {
+				log.Printf("Failed to audit rehashed password for user %s: %v", user.ID, err)
+			}
+		}
+	}
+
+	return user, nil
+}
+
+// getDummyPassword lazily hashes a throwaway password with the current parameters
+func (s *UserService) getDummyPassword() entity.Password {
+	s.dummyPasswordOnce.Do(func() {
+		s.dummyPassword, _ = s.passwordHasher.Hash("dummy-password-for-timing")
+	})
+	return s.dummyPassword
+}
+
+// DeleteUser soft-deletes a user, recording the reason. The user disappears
+// from lookups and listings but can be restored until the deletion grace
+// period has passed.
+func (s *UserService) DeleteUser(ctx context.Context, id entity.UserID, reason string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.findForChange(ctx, id)
+	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionDelete, err); denied != nil {
+			return denied
+		}
 		return fmt.Errorf("failed to find user for deletion: %w", err)
 	}
+	if err := s.authorize(ctx, authz.ActionDelete, user); err != nil {
+		return err
+	}
+
+	before := *user
+	if err := user.Delete(reason, ActorFromContext(ctx)); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
 
-	// Delete user
-	if err := s.repo.Delete(id); err != nil {
+	if err := s.repo.Update(ctx, user); err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
+	s.endSessions(ctx, user)
+	if err := s.audit(ctx, audit.ActionDelete, &before, user); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RestoreUser undoes a soft delete, returning the user to its previous status
+func (s *UserService) RestoreUser(ctx context.Context, id entity.UserID, reason string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.repo.FindDeletedByID(ctx, id)
+	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionRestore, err); denied != nil {
+			return denied
+		}
+		return fmt.Errorf("failed to find deleted user: %w", err)
+	}
+	if err := s.authorize(ctx, authz.ActionRestore, user); err != nil {
+		return err
+	}
+	s.attach(user)
+
+	before := *user
+	if err := user.Restore(reason, ActorFromContext(ctx)); err != nil {
+		return fmt.Errorf("failed to restore user: %w", err)
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to save restored user: %w", err)
+	}
+	if err := s.audit(ctx, audit.ActionRestore, &before, user); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// PurgeDeletedUsers permanently removes users whose deletion grace period
+// has passed and returns how many were removed
+func (s *UserService) PurgeDeletedUsers(ctx context.Context) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.authorize(ctx, authz.ActionPurge, nil); err != nil {
+		return 0, err
+	}
+
+	cutoff := s.clock.Now().Add(-s.deletionGracePeriod)
+	purged, err := s.repo.PurgeDeleted(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted users: %w", err)
+	}
+	if purged > 0 {
+		if err := s.recordAudit(ctx, audit.Entry{Action: audit.ActionPurge, Changes: purgeChanges(purged)}); err != nil {
+			return purged, err
+		}
+	}
+
+	return purged, nil
+}
+
+// ActivateUser moves a pending user to active
+func (s *UserService) ActivateUser(ctx context.Context, id entity.UserID, reason string) error {
+	return s.changeStatus(ctx, id, reason, authz.ActionActivate, audit.ActionActivate, (*entity.User).Activate)
+}
+
+// SuspendUser temporarily blocks a user
+func (s *UserService) SuspendUser(ctx context.Context, id entity.UserID, reason string) error {
+	return s.changeStatus(ctx, id, reason, authz.ActionSuspend, audit.ActionSuspend, (*entity.User).Suspend)
+}
+
+// ReactivateUser returns a suspended or deactivated user to active
+func (s *UserService) ReactivateUser(ctx context.Context, id entity.UserID, reason string) error {
+	return s.changeStatus(ctx, id, reason, authz.ActionReactivate, audit.ActionReactivate, (*entity.User).Reactivate)
+}
+
+// DeactivateUser closes a user's account without deleting it
+func (s *UserService) DeactivateUser(ctx context.Context, id entity.UserID, reason string) error {
+	return s.changeStatus(ctx, id, reason, authz.ActionDeactivate, audit.ActionDeactivate, (*entity.User).Deactivate)
+}
+
+// AssignRoles replaces the roles of a user
+func (s *UserService) AssignRoles(ctx context.Context, id entity.UserID, roles []entity.Role) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.findForChange(ctx, id)
+	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionAssignRoles, err); denied != nil {
+			return denied
+		}
+		return fmt.Errorf("failed to find user for role change: %w", err)
+	}
+	if err := s.authorize(ctx, authz.ActionAssignRoles, user); err != nil {
+		return err
+	}
+
+	before := *user
+	if err := user.SetRoles(roles, ActorFromContext(ctx)); err != nil {
+		return fmt.Errorf("failed to assign roles: %w", err)
+	}
+	if unchanged(user) {
+		return nil
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to save user roles: %w", err)
+	}
+	if err := s.audit(ctx, audit.ActionAssignRoles, &before, user); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AssignTenant moves a user to tenant; the empty tenant takes them out of any
+func (s *UserService) AssignTenant(ctx context.Context, id entity.UserID, tenant entity.Tenant) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.findForChange(ctx, id)
+	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionAssignTenant, err); denied != nil {
+			return denied
+		}
+		return fmt.Errorf("failed to find user for tenant change: %w", err)
+	}
+	if err := s.authorize(ctx, authz.ActionAssignTenant, user); err != nil {
+		return err
+	}
+
+	before := *user
+	if err := user.SetTenant(tenant, ActorFromContext(ctx)); err != nil {
+		return fmt.Errorf("failed to assign tenant: %w", err)
+	}
+	if unchanged(user) {
+		return nil
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to save user tenant: %w", err)
+	}
+	if err := s.audit(ctx, audit.ActionAssignTenant, &before, user); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// changeStatus loads a user, applies a status transition and saves the result,
+// authorizing it as permission and auditing it as action. The actor recorded
+// on the transition is taken from ctx.
+func (s *UserService) changeStatus(
+	ctx context.Context,
+	id entity.UserID,
+	reason string,
+	permission authz.Action,
+	action audit.Action,
+	transition func(user *entity.User, reason string, actor string) error,
+) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.findForChange(ctx, id)
+	if err != nil {
+		if denied := s.authorizeMissing(ctx, permission, err); denied != nil {
+			return denied
+		}
+		return fmt.Errorf("failed to find user for status change: %w", err)
+	}
+	if err := s.authorize(ctx, permission, user); err != nil {
+		return err
+	}
+
+	before := *user
+	if err := transition(user, reason, ActorFromContext(ctx)); err != nil {
+		return fmt.Errorf("failed to change user status: %w", err)
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to save user status: %w", err)
+	}
+	s.endSessions(ctx, user)
+	if err := s.audit(ctx, action, &before, user); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// unchanged reports whether a change left user as it was. Such a change is
+// neither saved nor audited, so that every audited save moves the user to a
+// new version whatever the repository.
+func unchanged(user *entity.User) bool {
+	return len(user.Events()) == 0
+}
+
+// findForChange loads a user that is about to be modified
+func (s *UserService) findForChange(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.attach(user)
+	return user, nil
+}
+
+// attach gives a loaded user the service's clock and email normalizer, so
+// changes made to it are timestamped and normalized consistently
+func (s *UserService) attach(user *entity.User) {
+	user.SetClock(s.clock)
+	user.SetEmailNormalizer(s.emailNormalizer)
+}
+
+// findByEmail looks a user up by the canonical form of email.
+// An address that can't be normalized matches no user.
+func (s *UserService) findByEmail(ctx context.Context, email string) (*entity.User, error) {
+	canonical, err := s.emailNormalizer.Canonical(email)
+	if err != nil {
+		return nil, repository.ErrUserNotFound
+	}
+
+	return s.repo.FindByEmail(ctx, canonical)
+}
+
 // IsUserActive checks if a user is active
-func (s *UserService) IsUserActive(id entity.UserID) (bool, error) {
-	user, err := s.repo.FindByID(id)
+func (s *UserService) IsUserActive(ctx context.Context, id entity.UserID) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user, err := s.repo.FindByID(ctx, id)
 	if err != nil {
+		if denied := s.authorizeMissing(ctx, authz.ActionRead, err); denied != nil {
+			return false, denied
+		}
 		return false, fmt.Errorf("failed to find user: %w", err)
 	}
+	if err := s.authorize(ctx, authz.ActionRead, user); err != nil {
+		return false, err
+	}
 
 	return user.IsActive(), nil
 }
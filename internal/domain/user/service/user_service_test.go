@@ -1,14 +1,31 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
 	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
 	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
-	"testing"
 )
 
-// MockUserRepository for testing
+// testHasher keeps password hashing cheap in tests
+var testHasher = entity.PasswordHasher{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+
+// testPasswordPlain satisfies the default password policy
+const testPasswordPlain = "Secret-passw0rd"
+
+// MockUserRepository for testing. It is safe for concurrent use.
 type MockUserRepository struct {
-	users map[entity.UserID]*entity.User
+	mu     sync.Mutex
+	users  map[entity.UserID]*entity.User
+	outbox []entity.Event
 }
 
 func NewMockUserRepository() *MockUserRepository {
@@ -17,46 +34,138 @@ func NewMockUserRepository() *MockUserRepository {
 	}
 }
 
-func (m *MockUserRepository) Save(user *entity.User) error {
+func (m *MockUserRepository) Create(ctx context.Context, user *entity.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if user == nil {
 		return repository.ErrInvalidUser
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.users[user.ID]; exists {
+		return repository.ErrUserAlreadyExists
+	}
+	for _, existing := range m.users {
+		if existing.CanonicalEmail == user.CanonicalEmail {
+			return repository.ErrUserAlreadyExists
+		}
+	}
+
 	m.users[user.ID] = user
+	m.outbox = append(m.outbox, user.PullEvents()...)
 	return nil
 }
 
-func (m *MockUserRepository) FindByID(id entity.UserID) (*entity.User, error) {
+func (m *MockUserRepository) FindByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	user, exists := m.users[id]
-	if !exists {
+	if !exists || user.IsDeleted() {
+		return nil, repository.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (m *MockUserRepository) FindDeletedByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, exists := m.users[id]
+	if !exists || !user.IsDeleted() {
 		return nil, repository.ErrUserNotFound
 	}
 	return user, nil
 }
 
-func (m *MockUserRepository) FindByEmail(email entity.Email) (*entity.User, error) {
+func (m *MockUserRepository) FindByEmail(ctx context.Context, email entity.Email) (*entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for _, user := range m.users {
-		if user.Email == email {
+		if user.CanonicalEmail == email && !user.IsDeleted() {
 			return user, nil
 		}
 	}
 	return nil, repository.ErrUserNotFound
 }
 
-func (m *MockUserRepository) Update(user *entity.User) error {
+func (m *MockUserRepository) Update(ctx context.Context, user *entity.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if user == nil {
 		return repository.ErrInvalidUser
 	}
 
-	_, exists := m.users[user.ID]
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.users[user.ID]
 	if !exists {
 		return repository.ErrUserNotFound
 	}
+	if stored.Version != user.Version {
+		return &repository.VersionConflictError{ID: user.ID, Expected: user.Version, Actual: stored.Version}
+	}
 
+	user.Version++
 	m.users[user.ID] = user
+	m.outbox = append(m.outbox, user.PullEvents()...)
+	return nil
+}
+
+func (m *MockUserRepository) PendingEvents(ctx context.Context, limit int) ([]entity.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if limit > len(m.outbox) {
+		limit = len(m.outbox)
+	}
+	return append([]entity.Event(nil), m.outbox[:limit]...), nil
+}
+
+func (m *MockUserRepository) MarkEventsPublished(ctx context.Context, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pending []entity.Event
+	for _, event := range m.outbox {
+		published := false
+		for _, id := range ids {
+			published = published || event.EventID() == id
+		}
+		if !published {
+			pending = append(pending, event)
+		}
+	}
+	m.outbox = pending
 	return nil
 }
 
-func (m *MockUserRepository) Delete(id entity.UserID) error {
+func (m *MockUserRepository) Delete(ctx context.Context, id entity.UserID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	_, exists := m.users[id]
 	if !exists {
 		return repository.ErrUserNotFound
@@ -66,11 +175,45 @@ func (m *MockUserRepository) Delete(id entity.UserID) error {
 	return nil
 }
 
+func (m *MockUserRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for id, user := range m.users {
+		if user.IsDeleted() && user.DeletedAt.Before(cutoff) {
+			delete(m.users, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *MockUserRepository) List(ctx context.Context, query repository.ListQuery) (*repository.UserPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := make([]*entity.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return query.Paginate(users)
+}
+
 func TestUserService_CreateUser(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
-	user, err := service.CreateUser("test@example.com", "Test User")
+	user, err := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
 	if err != nil {
 		t.Errorf("CreateUser() unexpected error: %v", err)
 	}
@@ -84,35 +227,176 @@ func TestUserService_CreateUser(t *testing.T) {
 	}
 }
 
+func TestUserService_CreateUserReportsEveryViolation(t *testing.T) {
+	service := NewUserService(NewMockUserRepository(), WithPasswordHasher(testHasher))
+
+	_, err := service.CreateUser(context.Background(), "invalid-email", " ", "short")
+
+	var violations entity.ValidationErrors
+	if !errors.As(err, &violations) || len(violations) != 3 {
+		t.Fatalf("CreateUser() expected three violations, got: %v", err)
+	}
+	for _, want := range []error{entity.ErrInvalidEmail, entity.ErrEmptyName, entity.ErrWeakPassword} {
+		if !errors.Is(err, want) {
+			t.Errorf("CreateUser() error %q does not match %q", err, want)
+		}
+	}
+}
+
+func TestUserService_CreateUserConcurrentSameEmail(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	const attempts = 300
+	var (
+		wg        sync.WaitGroup
+		start     = make(chan struct{})
+		mu        sync.Mutex
+		successes int
+		failures  []error
+	)
+
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			// Vary the spelling so only the canonical form collides
+			email := "racer@example.com"
+			if i%2 == 1 {
+				email = "Racer@EXAMPLE.com"
+			}
+
+			_, err := service.CreateUser(ctx, email, fmt.Sprintf("Racer %d", i), testPasswordPlain)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				successes++
+			case !errors.Is(err, repository.ErrUserAlreadyExists):
+				failures = append(failures, err)
+			}
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("CreateUser() expected exactly one success, got: %d", successes)
+	}
+	for _, err := range failures {
+		t.Errorf("CreateUser() expected ErrUserAlreadyExists, got: %v", err)
+	}
+	if len(repo.users) != 1 {
+		t.Errorf("repository holds %d users, want 1", len(repo.users))
+	}
+}
+
+func TestUserService_CreateUserWithIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	service := NewUserService(repo,
+		WithPasswordHasher(testHasher),
+		WithIDGenerator(entity.NewSequentialIDGenerator(start)),
+	)
+
+	expected := entity.NewSequentialIDGenerator(start)
+	for _, email := range []string{"first@example.com", "second@example.com"} {
+		user, err := service.CreateUser(ctx, email, "Test User", testPasswordPlain)
+		if err != nil {
+			t.Fatalf("CreateUser() unexpected error: %v", err)
+		}
+		want, err := expected.NewID()
+		if err != nil {
+			t.Fatalf("NewID() unexpected error: %v", err)
+		}
+		if user.ID != want {
+			t.Errorf("CreateUser() ID = %q, want %q", user.ID, want)
+		}
+	}
+}
+
+func TestUserService_EmailCaseInsensitive(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	user, err := service.CreateUser(ctx, "Bob@Example.com", "Bob", testPasswordPlain)
+	if err != nil {
+		t.Fatalf("CreateUser() unexpected error: %v", err)
+	}
+	if user.Email != "Bob@example.com" {
+		t.Errorf("CreateUser() Email = %q, want the typed local part kept", user.Email)
+	}
+
+	if _, err := service.CreateUser(ctx, "bob@example.com", "Other Bob", testPasswordPlain); !errors.Is(err, repository.ErrUserAlreadyExists) {
+		t.Errorf("CreateUser() with different case expected ErrUserAlreadyExists, got: %v", err)
+	}
+
+	found, err := service.GetUserByEmail(ctx, " BOB@EXAMPLE.COM ")
+	if err != nil || found.ID != user.ID {
+		t.Errorf("GetUserByEmail() = %v, %v, want user %s", found, err, user.ID)
+	}
+
+	if _, err := service.Authenticate(ctx, "bob@EXAMPLE.com", testPasswordPlain); err != nil {
+		t.Errorf("Authenticate() with different case unexpected error: %v", err)
+	}
+}
+
+func TestUserService_WithEmailNormalizer(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo,
+		WithPasswordHasher(testHasher),
+		WithEmailNormalizer(entity.EmailNormalizer{Rules: []entity.EmailProviderRule{entity.GmailRule}}),
+	)
+
+	if _, err := service.CreateUser(ctx, "jane.doe@gmail.com", "Jane", testPasswordPlain); err != nil {
+		t.Fatalf("CreateUser() unexpected error: %v", err)
+	}
+
+	if _, err := service.CreateUser(ctx, "janedoe+spam@googlemail.com", "Jane", testPasswordPlain); !errors.Is(err, repository.ErrUserAlreadyExists) {
+		t.Errorf("CreateUser() with Gmail alias expected ErrUserAlreadyExists, got: %v", err)
+	}
+}
+
 func TestUserService_CreateUserWithInvalidEmail(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
-	_, err := service.CreateUser("invalid-email", "Test User")
+	_, err := service.CreateUser(ctx, "invalid-email", "Test User", testPasswordPlain)
 	if err == nil {
 		t.Errorf("CreateUser() expected error for invalid email")
 	}
 }
 
 func TestUserService_CreateUserWithEmptyName(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
-	_, err := service.CreateUser("test@example.com", "")
+	_, err := service.CreateUser(ctx, "test@example.com", "", testPasswordPlain)
 	if err == nil {
 		t.Errorf("CreateUser() expected error for empty name")
 	}
 }
 
 func TestUserService_GetUserByID(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
 	// Create user first
-	user, _ := service.CreateUser("test@example.com", "Test User")
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
 
 	// Get user by ID
-	foundUser, err := service.GetUserByID(user.ID)
+	foundUser, err := service.GetUserByID(ctx, user.ID)
 	if err != nil {
 		t.Errorf("GetUserByID() unexpected error: %v", err)
 	}
@@ -123,10 +407,11 @@ func TestUserService_GetUserByID(t *testing.T) {
 }
 
 func TestUserService_GetUserByIDNotFound(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
-	_, err := service.GetUserByID("non-existent-id")
+	_, err := service.GetUserByID(ctx, "non-existent-id")
 	if err == nil {
 		t.Errorf("GetUserByID() expected error, got nil")
 	}
@@ -137,14 +422,15 @@ func TestUserService_GetUserByIDNotFound(t *testing.T) {
 }
 
 func TestUserService_GetUserByEmail(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
 	// Create user first
-	user, _ := service.CreateUser("test@example.com", "Test User")
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
 
 	// Get user by email
-	foundUser, err := service.GetUserByEmail("test@example.com")
+	foundUser, err := service.GetUserByEmail(ctx, "test@example.com")
 	if err != nil {
 		t.Errorf("GetUserByEmail() unexpected error: %v", err)
 	}
@@ -155,10 +441,11 @@ func TestUserService_GetUserByEmail(t *testing.T) {
 }
 
 func TestUserService_GetUserByEmailNotFound(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
-	_, err := service.GetUserByEmail("notfound@example.com")
+	_, err := service.GetUserByEmail(ctx, "notfound@example.com")
 	if err == nil {
 		t.Errorf("GetUserByEmail() expected error, got nil")
 	}
@@ -169,20 +456,21 @@ func TestUserService_GetUserByEmailNotFound(t *testing.T) {
 }
 
 func TestUserService_UpdateUser(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
 	// Create user first
-	user, _ := service.CreateUser("test@example.com", "Test User")
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
 
 	// Update user
-	err := service.UpdateUser(user.ID, "updated@example.com", "Updated Name")
+	err := service.UpdateUser(ctx, user.ID, "updated@example.com", "Updated Name", AnyVersion)
 	if err != nil {
 		t.Errorf("UpdateUser() unexpected error: %v", err)
 	}
 
 	// Verify update
-	updatedUser, _ := service.GetUserByID(user.ID)
+	updatedUser, _ := service.GetUserByID(ctx, user.ID)
 	if updatedUser.Email != "updated@example.com" {
 		t.Errorf("UpdateUser() email not updated, got: %s", updatedUser.Email)
 	}
@@ -192,11 +480,176 @@ func TestUserService_UpdateUser(t *testing.T) {
 	}
 }
 
+func TestUserService_UpdateUserExpectedVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	version := user.Version
+
+	if err := service.UpdateUser(ctx, user.ID, "test@example.com", "First", version); err != nil {
+		t.Fatalf("UpdateUser() unexpected error: %v", err)
+	}
+
+	err := service.UpdateUser(ctx, user.ID, "test@example.com", "Second", version)
+	var conflict *repository.VersionConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("UpdateUser() with stale version expected VersionConflictError, got: %v", err)
+	}
+	if conflict.Expected != version || conflict.Actual != version+1 {
+		t.Errorf("UpdateUser() conflict = %+v", conflict)
+	}
+
+	stored, _ := service.GetUserByID(ctx, user.ID)
+	if stored.Name != "First" {
+		t.Errorf("UpdateUser() stale update applied, got name: %s", stored.Name)
+	}
+}
+
+func TestUserService_WritesEventsToOutbox(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "admin")
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	_ = service.UpdateUser(ctx, user.ID, "test@example.com", "Renamed", AnyVersion)
+	_ = service.ActivateUser(ctx, user.ID, "verified")
+	_ = service.DeleteUser(ctx, user.ID, "")
+
+	// Failed writes store no events
+	_ = service.UpdateUser(ctx, user.ID, "test@example.com", "Again", AnyVersion)
+	_, _ = service.CreateUser(ctx, "test@example.com", "Duplicate", testPasswordPlain)
+
+	events, _ := repo.PendingEvents(ctx, 100)
+	var got []string
+	for _, event := range events {
+		got = append(got, event.EventName())
+	}
+
+	want := []string{
+		entity.EventUserRegistered,
+		entity.EventUserRenamed,
+		entity.EventUserStatusChanged,
+		entity.EventUserStatusChanged,
+		entity.EventUserDeleted,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("outbox holds %v, want %v", got, want)
+	}
+}
+
+// recordingAuditLog keeps recorded audit entries in memory
+type recordingAuditLog struct {
+	entries []audit.Entry
+}
+
+func (l *recordingAuditLog) Record(ctx context.Context, entry audit.Entry) error {
+	entry.Sequence = int64(len(l.entries) + 1)
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *recordingAuditLog) Query(ctx context.Context, query audit.Query) ([]audit.Entry, error) {
+	var entries []audit.Entry
+	for _, entry := range l.entries {
+		if query.Matches(entry) && len(entries) < query.Limit {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func TestUserService_AuditLog(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "admin")
+	auditLog := &recordingAuditLog{}
+	service := NewUserService(NewMockUserRepository(), WithPasswordHasher(testHasher), WithAuditLog(auditLog))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	_ = service.UpdateUser(ctx, user.ID, "test@example.com", "Renamed", AnyVersion)
+	_ = service.ChangePassword(ctx, user.ID, testPasswordPlain, "Other-passw0rd")
+	_ = service.ActivateUser(ctx, user.ID, "verified")
+	_ = service.SuspendUser(ctx, user.ID, "abuse")
+	_ = service.DeleteUser(ctx, user.ID, "")
+	_ = service.RestoreUser(ctx, user.ID, "mistake")
+
+	// Failed changes are not audited
+	_ = service.ActivateUser(ctx, user.ID, "again")
+
+	wantActions := []audit.Action{
+		audit.ActionCreate,
+		audit.ActionUpdate,
+		audit.ActionChangePassword,
+		audit.ActionActivate,
+		audit.ActionSuspend,
+		audit.ActionDelete,
+		audit.ActionRestore,
+	}
+	if len(auditLog.entries) != len(wantActions) {
+		t.Fatalf("recorded %d entries, want %d", len(auditLog.entries), len(wantActions))
+	}
+	for i, entry := range auditLog.entries {
+		if entry.Action != wantActions[i] || entry.Actor != "admin" || entry.UserID != user.ID || entry.At.IsZero() {
+			t.Errorf("entry %d = %+v, want %s by admin", i, entry, wantActions[i])
+		}
+	}
+
+	update := auditLog.entries[1].Changes
+	if len(update) != 1 || update[0] != (audit.FieldChange{Field: "name", From: "Test User", To: "Renamed"}) {
+		t.Errorf("update changes = %+v", update)
+	}
+
+	password := auditLog.entries[2].Changes
+	if len(password) != 1 || password[0] != (audit.FieldChange{Field: "password", From: "[redacted]", To: "[redacted]"}) {
+		t.Errorf("password changes = %+v", password)
+	}
+
+	deleted := auditLog.entries[5].Changes
+	if len(deleted) != 2 || deleted[0].Field != "status" || deleted[0].To != "deleted" || deleted[1].Field != "deleted_at" {
+		t.Errorf("delete changes = %+v", deleted)
+	}
+}
+
+func TestUserService_Clock(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFake(start)
+	service := NewUserService(repo, WithPasswordHasher(testHasher), WithClock(fakeClock))
+
+	user, err := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	if err != nil {
+		t.Fatalf("CreateUser() unexpected error: %v", err)
+	}
+	if !user.CreatedAt.Equal(start) {
+		t.Errorf("CreateUser() CreatedAt = %v, want %v", user.CreatedAt, start)
+	}
+
+	fakeClock.Advance(time.Hour)
+	if err := service.UpdateUser(ctx, user.ID, "test@example.com", "Renamed", AnyVersion); err != nil {
+		t.Fatalf("UpdateUser() unexpected error: %v", err)
+	}
+
+	fakeClock.Advance(time.Hour)
+	if err := service.ActivateUser(ctx, user.ID, "verified"); err != nil {
+		t.Fatalf("ActivateUser() unexpected error: %v", err)
+	}
+
+	stored, _ := service.GetUserByID(ctx, user.ID)
+	if want := start.Add(2 * time.Hour); !stored.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %v", stored.UpdatedAt, want)
+	}
+	if !stored.CreatedAt.Equal(start) {
+		t.Errorf("CreatedAt = %v, want %v", stored.CreatedAt, start)
+	}
+}
+
 func TestUserService_UpdateUserNotFound(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
-	err := service.UpdateUser("non-existent-id", "updated@example.com", "Updated Name")
+	err := service.UpdateUser(ctx, "non-existent-id", "updated@example.com", "Updated Name", AnyVersion)
 	if err == nil {
 		t.Errorf("UpdateUser() expected error, got nil")
 	}
@@ -207,20 +660,21 @@ func TestUserService_UpdateUserNotFound(t *testing.T) {
 }
 
 func TestUserService_DeleteUser(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
 	// Create user first
-	user, _ := service.CreateUser("test@example.com", "Test User")
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
 
 	// Delete user
-	err := service.DeleteUser(user.ID)
+	err := service.DeleteUser(ctx, user.ID, "")
 	if err != nil {
 		t.Errorf("DeleteUser() unexpected error: %v", err)
 	}
 
 	// Verify deletion
-	_, err = service.GetUserByID(user.ID)
+	_, err = service.GetUserByID(ctx, user.ID)
 	if err == nil {
 		t.Errorf("DeleteUser() expected error after deletion, got nil")
 	}
@@ -229,11 +683,74 @@ func TestUserService_DeleteUser(t *testing.T) {
 	}
 }
 
+func TestUserService_RestoreUser(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "admin")
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	_ = service.ActivateUser(ctx, user.ID, "verified")
+
+	if err := service.RestoreUser(ctx, user.ID, "not deleted"); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Errorf("RestoreUser() of live user expected ErrUserNotFound, got: %v", err)
+	}
+
+	if err := service.DeleteUser(ctx, user.ID, "duplicate account"); err != nil {
+		t.Fatalf("DeleteUser() unexpected error: %v", err)
+	}
+
+	if err := service.RestoreUser(ctx, user.ID, "deleted by mistake"); err != nil {
+		t.Fatalf("RestoreUser() unexpected error: %v", err)
+	}
+
+	restored, err := service.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() after restore unexpected error: %v", err)
+	}
+	if restored.Status != entity.StatusActive {
+		t.Errorf("RestoreUser() status = %s, want %s", restored.Status, entity.StatusActive)
+	}
+
+	deletion := restored.StatusHistory[len(restored.StatusHistory)-2]
+	if deletion.To != entity.StatusDeleted || deletion.Actor != "admin" || deletion.Reason != "duplicate account" {
+		t.Errorf("DeleteUser() recorded %+v", deletion)
+	}
+}
+
+func TestUserService_PurgeDeletedUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	fakeClock := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	service := NewUserService(repo,
+		WithPasswordHasher(testHasher),
+		WithClock(fakeClock),
+		WithDeletionGracePeriod(7*24*time.Hour),
+	)
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	_ = service.DeleteUser(ctx, user.ID, "")
+
+	fakeClock.Advance(6 * 24 * time.Hour)
+	if purged, err := service.PurgeDeletedUsers(ctx); err != nil || purged != 0 {
+		t.Errorf("PurgeDeletedUsers() within grace period = %d, %v, want 0", purged, err)
+	}
+
+	fakeClock.Advance(2 * 24 * time.Hour)
+	if purged, err := service.PurgeDeletedUsers(ctx); err != nil || purged != 1 {
+		t.Errorf("PurgeDeletedUsers() after grace period = %d, %v, want 1", purged, err)
+	}
+
+	if err := service.RestoreUser(ctx, user.ID, ""); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Errorf("RestoreUser() after purge expected ErrUserNotFound, got: %v", err)
+	}
+}
+
 func TestUserService_DeleteUserNotFound(t *testing.T) {
+	ctx := context.Background()
 	repo := NewMockUserRepository()
-	service := NewUserService(repo)
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
 
-	err := service.DeleteUser("non-existent-id")
+	err := service.DeleteUser(ctx, "non-existent-id", "")
 	if err == nil {
 		t.Errorf("DeleteUser() expected error, got nil")
 	}
@@ -242,3 +759,497 @@ func TestUserService_DeleteUserNotFound(t *testing.T) {
 		t.Errorf("DeleteUser() unexpected error message: %v", err)
 	}
 }
+
+// deadlineRecordingRepository records the deadline of the context it receives
+type deadlineRecordingRepository struct {
+	*MockUserRepository
+	deadline    time.Time
+	hasDeadline bool
+}
+
+func (r *deadlineRecordingRepository) FindByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	r.deadline, r.hasDeadline = ctx.Deadline()
+	return r.MockUserRepository.FindByID(ctx, id)
+}
+
+func TestUserService_CancelledContext(t *testing.T) {
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateUser() expected context.Canceled, got: %v", err)
+	}
+
+	if len(repo.users) != 0 {
+		t.Errorf("CreateUser() saved a user despite cancelled context")
+	}
+}
+
+func TestUserService_OperationTimeout(t *testing.T) {
+	repo := &deadlineRecordingRepository{MockUserRepository: NewMockUserRepository()}
+	service := NewUserService(repo, WithPasswordHasher(testHasher), WithOperationTimeout(time.Second))
+
+	before := time.Now()
+	_, _ = service.GetUserByID(context.Background(), "any-id")
+
+	if !repo.hasDeadline {
+		t.Fatalf("GetUserByID() repository context has no deadline")
+	}
+
+	if repo.deadline.Before(before) || repo.deadline.After(before.Add(2*time.Second)) {
+		t.Errorf("GetUserByID() unexpected deadline: %v", repo.deadline)
+	}
+}
+
+func TestUserService_CallerDeadlineWins(t *testing.T) {
+	repo := &deadlineRecordingRepository{MockUserRepository: NewMockUserRepository()}
+	service := NewUserService(repo, WithPasswordHasher(testHasher), WithOperationTimeout(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	want, _ := ctx.Deadline()
+
+	_, _ = service.GetUserByID(ctx, "any-id")
+
+	if !repo.deadline.Equal(want) {
+		t.Errorf("GetUserByID() deadline = %v, want caller deadline %v", repo.deadline, want)
+	}
+}
+
+func TestUserService_CreateUserWithWeakPassword(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	_, err := service.CreateUser(ctx, "test@example.com", "Test User", "weak")
+	if !errors.Is(err, entity.ErrWeakPassword) {
+		t.Errorf("CreateUser() expected ErrWeakPassword, got: %v", err)
+	}
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+
+	err := service.ChangePassword(ctx, user.ID, "wrong-password", "Another-passw0rd")
+	if err != ErrInvalidCredentials {
+		t.Errorf("ChangePassword() expected ErrInvalidCredentials, got: %v", err)
+	}
+
+	err = service.ChangePassword(ctx, user.ID, testPasswordPlain, "Another-passw0rd")
+	if err != nil {
+		t.Fatalf("ChangePassword() unexpected error: %v", err)
+	}
+
+	if _, err := service.Authenticate(ctx, "test@example.com", "Another-passw0rd"); err != nil {
+		t.Errorf("Authenticate() with new password unexpected error: %v", err)
+	}
+
+	if _, err := service.Authenticate(ctx, "test@example.com", testPasswordPlain); err != ErrInvalidCredentials {
+		t.Errorf("Authenticate() with old password expected ErrInvalidCredentials, got: %v", err)
+	}
+}
+
+func TestUserService_AuthenticateUnknownUser(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	_, err := service.Authenticate(ctx, "nobody@example.com", testPasswordPlain)
+	if err != ErrInvalidCredentials {
+		t.Errorf("Authenticate() expected ErrInvalidCredentials, got: %v", err)
+	}
+}
+
+func TestUserService_AuthenticateRehashesOutdatedPassword(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+
+	oldService := NewUserService(repo, WithPasswordHasher(testHasher))
+	user, _ := oldService.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+
+	upgraded := testHasher
+	upgraded.Iterations = testHasher.Iterations * 2
+	newService := NewUserService(repo, WithPasswordHasher(upgraded))
+
+	if _, err := newService.Authenticate(ctx, "test@example.com", testPasswordPlain); err != nil {
+		t.Fatalf("Authenticate() unexpected error: %v", err)
+	}
+
+	stored, _ := repo.FindByID(ctx, user.ID)
+	if upgraded.NeedsRehash(stored.Password) {
+		t.Errorf("Authenticate() did not upgrade the stored hash")
+	}
+
+	if !stored.Password.Verify(testPasswordPlain) {
+		t.Errorf("Authenticate() upgraded hash does not verify")
+	}
+}
+
+func TestUserService_AuthenticateKeepsBlockedUsersHash(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+
+	oldService := NewUserService(repo, WithPasswordHasher(testHasher))
+	user, _ := oldService.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	_ = oldService.ActivateUser(ctx, user.ID, "")
+	_ = oldService.SuspendUser(ctx, user.ID, "abuse")
+	suspended, _ := repo.FindByID(ctx, user.ID)
+
+	upgraded := testHasher
+	upgraded.Iterations = testHasher.Iterations * 2
+	newService := NewUserService(repo, WithPasswordHasher(upgraded))
+
+	if _, err := newService.Authenticate(ctx, "test@example.com", testPasswordPlain); err != nil {
+		t.Fatalf("Authenticate() unexpected error: %v", err)
+	}
+
+	stored, _ := repo.FindByID(ctx, user.ID)
+	if stored.Version != suspended.Version || !upgraded.NeedsRehash(stored.Password) {
+		t.Errorf("Authenticate() of a suspended user saved a rehashed password")
+	}
+}
+
+func TestUserService_StatusOperations(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "admin_1")
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+
+	if active, _ := service.IsUserActive(ctx, user.ID); active {
+		t.Errorf("IsUserActive() true for pending user")
+	}
+
+	if err := service.ActivateUser(ctx, user.ID, "verified"); err != nil {
+		t.Fatalf("ActivateUser() unexpected error: %v", err)
+	}
+	if active, _ := service.IsUserActive(ctx, user.ID); !active {
+		t.Errorf("IsUserActive() false after activation")
+	}
+
+	if err := service.SuspendUser(ctx, user.ID, "abuse"); err != nil {
+		t.Fatalf("SuspendUser() unexpected error: %v", err)
+	}
+	if active, _ := service.IsUserActive(ctx, user.ID); active {
+		t.Errorf("IsUserActive() true for suspended user")
+	}
+
+	if err := service.ReactivateUser(ctx, user.ID, "appeal accepted"); err != nil {
+		t.Fatalf("ReactivateUser() unexpected error: %v", err)
+	}
+
+	if err := service.DeactivateUser(ctx, user.ID, "user request"); err != nil {
+		t.Fatalf("DeactivateUser() unexpected error: %v", err)
+	}
+
+	stored, _ := service.GetUserByID(ctx, user.ID)
+	if len(stored.StatusHistory) != 4 {
+		t.Fatalf("StatusHistory length = %d, want 4", len(stored.StatusHistory))
+	}
+	for _, transition := range stored.StatusHistory {
+		if transition.Actor != "admin_1" {
+			t.Errorf("StatusHistory actor = %q, want admin_1", transition.Actor)
+		}
+	}
+}
+
+func TestUserService_InvalidStatusTransition(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+
+	err := service.SuspendUser(ctx, user.ID, "abuse")
+	if !errors.Is(err, entity.ErrInvalidStatusTransition) {
+		t.Errorf("SuspendUser() of pending user expected ErrInvalidStatusTransition, got: %v", err)
+	}
+}
+
+// recordingSessions records whose sessions were ended
+type recordingSessions struct {
+	ended []entity.UserID
+	err   error
+}
+
+func (r *recordingSessions) DeleteByUser(ctx context.Context, userID entity.UserID) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.ended = append(r.ended, userID)
+	return nil
+}
+
+func TestUserService_EndsSessions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	sessions := &recordingSessions{}
+	service := NewUserService(repo, WithPasswordHasher(testHasher), WithSessions(sessions))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	_ = service.ActivateUser(ctx, user.ID, "verified")
+	if len(sessions.ended) != 0 {
+		t.Fatalf("ActivateUser() ended sessions of %v", sessions.ended)
+	}
+
+	if err := service.SuspendUser(ctx, user.ID, "abuse"); err != nil {
+		t.Fatalf("SuspendUser() unexpected error: %v", err)
+	}
+	_ = service.ReactivateUser(ctx, user.ID, "appeal accepted")
+	if err := service.DeleteUser(ctx, user.ID, ""); err != nil {
+		t.Fatalf("DeleteUser() unexpected error: %v", err)
+	}
+	if len(sessions.ended) != 2 || sessions.ended[0] != user.ID || sessions.ended[1] != user.ID {
+		t.Errorf("ended sessions of %v, want the user's after suspension and deletion", sessions.ended)
+	}
+
+	other, _ := service.CreateUser(ctx, "other@example.com", "Other User", testPasswordPlain)
+	_ = service.ActivateUser(ctx, other.ID, "verified")
+	sessions.err = errors.New("session store down")
+	if err := service.SuspendUser(ctx, other.ID, "abuse"); err != nil {
+		t.Errorf("SuspendUser() unexpected error when sessions can't be ended: %v", err)
+	}
+	if stored, _ := repo.FindByID(ctx, other.ID); stored.Status != entity.StatusSuspended {
+		t.Errorf("stored status = %s, want the suspension kept", stored.Status)
+	}
+}
+
+// failingUpdateRepository refuses to store changes to existing users while failing is set
+type failingUpdateRepository struct {
+	*MockUserRepository
+	failing bool
+}
+
+func (r *failingUpdateRepository) Update(ctx context.Context, user *entity.User) error {
+	if r.failing {
+		return errors.New("database down")
+	}
+	return r.MockUserRepository.Update(ctx, user)
+}
+
+func TestUserService_KeepsSessionsWhenSaveFails(t *testing.T) {
+	ctx := context.Background()
+	repo := &failingUpdateRepository{MockUserRepository: NewMockUserRepository()}
+	sessions := &recordingSessions{}
+	service := NewUserService(repo, WithPasswordHasher(testHasher), WithSessions(sessions))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	_ = service.ActivateUser(ctx, user.ID, "verified")
+	repo.failing = true
+	if err := service.SuspendUser(ctx, user.ID, "abuse"); err == nil {
+		t.Fatal("SuspendUser() expected an error when the user can't be saved")
+	}
+	if err := service.DeleteUser(ctx, user.ID, ""); err == nil {
+		t.Fatal("DeleteUser() expected an error when the user can't be saved")
+	}
+	if len(sessions.ended) != 0 {
+		t.Errorf("ended sessions of %v although nothing was saved", sessions.ended)
+	}
+}
+
+func TestUserService_Authorization(t *testing.T) {
+	system := ContextWithSystem(context.Background())
+	repo := NewMockUserRepository()
+	service := NewUserService(repo,
+		WithPasswordHasher(testHasher),
+		WithAuthorizer(authz.NewRoleAuthorizer(authz.DefaultGrants)),
+	)
+
+	// Anyone may register
+	alice, err := service.CreateUser(context.Background(), "alice@example.com", "Alice", testPasswordPlain)
+	if err != nil {
+		t.Fatalf("CreateUser() anonymously unexpected error: %v", err)
+	}
+	bob, _ := service.CreateUser(context.Background(), "bob@example.com", "Bob", testPasswordPlain)
+	admin, _ := service.CreateUser(context.Background(), "admin@example.com", "Admin", testPasswordPlain)
+	if err := service.AssignRoles(system, admin.ID, []entity.Role{entity.RoleAdmin}); err != nil {
+		t.Fatalf("AssignRoles() by the system unexpected error: %v", err)
+	}
+
+	asAlice := ContextWithCaller(context.Background(), alice.ID)
+	asAdmin := ContextWithCaller(context.Background(), admin.ID)
+
+	if _, err := service.GetUserByID(context.Background(), alice.ID); !errors.Is(err, authz.ErrAuthenticationRequired) {
+		t.Errorf("GetUserByID() anonymously expected ErrAuthenticationRequired, got: %v", err)
+	}
+	if _, err := service.GetUserByID(asAlice, alice.ID); err != nil {
+		t.Errorf("GetUserByID() of own record unexpected error: %v", err)
+	}
+	if err := service.UpdateUser(asAlice, alice.ID, "alice@example.com", "Alice A.", AnyVersion); err != nil {
+		t.Errorf("UpdateUser() of own record unexpected error: %v", err)
+	}
+	if _, err := service.GetUserByID(asAlice, bob.ID); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("GetUserByID() of another user expected ErrForbidden, got: %v", err)
+	}
+	if err := service.DeleteUser(asAlice, bob.ID, ""); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("DeleteUser() of another user expected ErrForbidden, got: %v", err)
+	}
+	if err := service.AssignRoles(asAlice, alice.ID, []entity.Role{entity.RoleAdmin}); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("AssignRoles() on own record expected ErrForbidden, got: %v", err)
+	}
+
+	// Unknown users look the same as users the caller may not see
+	const unknown entity.UserID = "user_01HV6X5Q9Z3K8M2N4P7R0T1W2Y"
+	if _, err := service.GetUserByID(context.Background(), unknown); !errors.Is(err, authz.ErrAuthenticationRequired) {
+		t.Errorf("GetUserByID() of an unknown user anonymously expected ErrAuthenticationRequired, got: %v", err)
+	}
+	if _, err := service.GetUserByID(asAlice, unknown); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("GetUserByID() of an unknown user expected ErrForbidden, got: %v", err)
+	}
+	if _, err := service.GetUserByEmail(asAlice, "nobody@example.com"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("GetUserByEmail() of an unknown user expected ErrForbidden, got: %v", err)
+	}
+	if err := service.SuspendUser(asAlice, unknown, "abuse"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("SuspendUser() of an unknown user expected ErrForbidden, got: %v", err)
+	}
+	if _, err := service.GetUserByID(asAdmin, unknown); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Errorf("GetUserByID() of an unknown user by an admin expected ErrUserNotFound, got: %v", err)
+	}
+
+	if err := service.AssignRoles(asAdmin, alice.ID, []entity.Role{entity.RoleSupport}); err != nil {
+		t.Fatalf("AssignRoles() by an admin unexpected error: %v", err)
+	}
+	if _, err := service.GetUserByID(asAlice, bob.ID); err != nil {
+		t.Errorf("GetUserByID() by support unexpected error: %v", err)
+	}
+	if err := service.DeleteUser(asAdmin, bob.ID, ""); err != nil {
+		t.Errorf("DeleteUser() by an admin unexpected error: %v", err)
+	}
+
+	// A suspended caller loses their roles and their own record
+	_ = service.ActivateUser(asAdmin, alice.ID, "verified")
+	_ = service.SuspendUser(asAdmin, alice.ID, "abuse")
+	if _, err := service.GetUserByID(asAlice, alice.ID); !errors.Is(err, authz.ErrAuthenticationRequired) {
+		t.Errorf("GetUserByID() by a suspended caller expected ErrAuthenticationRequired, got: %v", err)
+	}
+
+	// Callers are turned away before learning that there is no audit log
+	if _, err := service.QueryAuditLog(context.Background(), audit.Query{}); !errors.Is(err, authz.ErrAuthenticationRequired) {
+		t.Errorf("QueryAuditLog() anonymously expected ErrAuthenticationRequired, got: %v", err)
+	}
+	if _, err := service.VerifyAuditLog(asAlice); !errors.Is(err, authz.ErrAuthenticationRequired) {
+		t.Errorf("VerifyAuditLog() by a suspended caller expected ErrAuthenticationRequired, got: %v", err)
+	}
+	if _, err := service.QueryAuditLog(asAdmin, audit.Query{}); !errors.Is(err, ErrAuditLogUnavailable) {
+		t.Errorf("QueryAuditLog() by an admin expected ErrAuditLogUnavailable, got: %v", err)
+	}
+
+	if _, err := service.PurgeDeletedUsers(context.Background()); !errors.Is(err, authz.ErrAuthenticationRequired) {
+		t.Errorf("PurgeDeletedUsers() anonymously expected ErrAuthenticationRequired, got: %v", err)
+	}
+	if _, err := service.PurgeDeletedUsers(system); err != nil {
+		t.Errorf("PurgeDeletedUsers() by the system unexpected error: %v", err)
+	}
+}
+
+func TestUserService_AssignRolesAudited(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "admin")
+	auditLog := &recordingAuditLog{}
+	service := NewUserService(NewMockUserRepository(), WithPasswordHasher(testHasher), WithAuditLog(auditLog))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	if err := service.AssignRoles(ctx, user.ID, []entity.Role{entity.RoleSupport}); err != nil {
+		t.Fatalf("AssignRoles() unexpected error: %v", err)
+	}
+	if err := service.AssignRoles(ctx, user.ID, []entity.Role{"root"}); !errors.Is(err, entity.ErrInvalidRole) {
+		t.Errorf("AssignRoles() with an unknown role expected ErrInvalidRole, got: %v", err)
+	}
+
+	last := auditLog.entries[len(auditLog.entries)-1]
+	if last.Action != audit.ActionAssignRoles || len(last.Changes) != 1 || last.Changes[0].To != "support" {
+		t.Errorf("AssignRoles() audited %+v", last)
+	}
+}
+
+func TestUserService_AssignTenantAudited(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "admin")
+	auditLog := &recordingAuditLog{}
+	service := NewUserService(NewMockUserRepository(), WithPasswordHasher(testHasher), WithAuditLog(auditLog))
+
+	user, _ := service.CreateUser(ctx, "test@example.com", "Test User", testPasswordPlain)
+	if err := service.AssignTenant(ctx, user.ID, "acme"); err != nil {
+		t.Fatalf("AssignTenant() unexpected error: %v", err)
+	}
+	if err := service.AssignTenant(ctx, user.ID, "Acme Corp"); !errors.Is(err, entity.ErrInvalidTenant) {
+		t.Errorf("AssignTenant() with an invalid name expected ErrInvalidTenant, got: %v", err)
+	}
+	if err := service.AssignTenant(ctx, user.ID, "acme"); err != nil {
+		t.Fatalf("AssignTenant() to the same tenant unexpected error: %v", err)
+	}
+
+	if len(auditLog.entries) != 2 {
+		t.Fatalf("AssignTenant() audited %d entries, want 2", len(auditLog.entries))
+	}
+	last := auditLog.entries[1]
+	if last.Action != audit.ActionAssignTenant || len(last.Changes) != 1 || last.Changes[0].To != "acme" {
+		t.Errorf("AssignTenant() audited %+v", last)
+	}
+
+	found, _ := service.GetUserByID(ctx, user.ID)
+	if found.Tenant != "acme" {
+		t.Errorf("GetUser() tenant = %q, want %q", found.Tenant, "acme")
+	}
+}
+
+func TestActorFromContext(t *testing.T) {
+	if actor := ActorFromContext(context.Background()); actor != AnonymousActor {
+		t.Errorf("ActorFromContext() = %q, want %q", actor, AnonymousActor)
+	}
+	if actor := ActorFromContext(ContextWithSystem(context.Background())); actor != SystemActor {
+		t.Errorf("ActorFromContext() of the system = %q, want %q", actor, SystemActor)
+	}
+
+	ctx := ContextWithActor(context.Background(), "user_42")
+	if actor := ActorFromContext(ctx); actor != "user_42" {
+		t.Errorf("ActorFromContext() = %q, want user_42", actor)
+	}
+}
+
+func TestUserService_ListUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockUserRepository()
+	service := NewUserService(repo, WithPasswordHasher(testHasher))
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@other.org", "d@bücher.de", "e@xn--bcher-kva.de"} {
+		if _, err := service.CreateUser(ctx, email, "User", testPasswordPlain); err != nil {
+			t.Fatalf("CreateUser() unexpected error: %v", err)
+		}
+	}
+
+	page, err := service.ListUsers(ctx, repository.ListQuery{Filter: repository.UserFilter{EmailDomain: "example.com"}})
+	if err != nil {
+		t.Fatalf("ListUsers() unexpected error: %v", err)
+	}
+	if len(page.Users) != 2 {
+		t.Errorf("ListUsers() returned %d users, want 2", len(page.Users))
+	}
+
+	// International domains match whichever form they were given in
+	for _, domain := range []string{"Bücher.de", "xn--bcher-kva.de", "@bücher.de"} {
+		page, err := service.ListUsers(ctx, repository.ListQuery{Filter: repository.UserFilter{EmailDomain: domain}})
+		if err != nil {
+			t.Fatalf("ListUsers(%q) unexpected error: %v", domain, err)
+		}
+		if len(page.Users) != 2 {
+			t.Errorf("ListUsers(%q) returned %d users, want 2", domain, len(page.Users))
+		}
+	}
+
+	_, err = service.ListUsers(ctx, repository.ListQuery{Filter: repository.UserFilter{EmailDomain: "bad domain"}})
+	if !errors.Is(err, repository.ErrInvalidListQuery) {
+		t.Errorf("ListUsers() with an invalid email domain expected ErrInvalidListQuery, got: %v", err)
+	}
+
+	_, err = service.ListUsers(ctx, repository.ListQuery{SortBy: "password"})
+	if !errors.Is(err, repository.ErrInvalidListQuery) {
+		t.Errorf("ListUsers() expected ErrInvalidListQuery, got: %v", err)
+	}
+}
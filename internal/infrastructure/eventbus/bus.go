@@ -0,0 +1,417 @@
+// Package eventbus delivers user domain events to in-process subscribers.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// Default sizes of the async worker pool
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 256
+)
+
+// ErrClosed is returned when publishing to a bus that has been closed
+var ErrClosed = errors.New("event bus closed")
+
+// Mode says how a subscriber receives events
+type Mode int
+
+const (
+	// Sync handles events in the publisher's goroutine before Publish returns,
+	// retries included
+	Sync Mode = iota
+	// Async queues events for the bus's worker pool and returns immediately
+	Async
+)
+
+// Bus delivers published events to the subscribers of their type.
+// A Bus implements service.EventDispatcher.
+type Bus struct {
+	retry       RetryPolicy
+	deadLetters DeadLetterStore
+	clock       clock.Clock
+	workers     int
+
+	mu            sync.RWMutex
+	subscriptions []*subscription
+	closed        bool
+
+	queue   chan delivery
+	closing chan struct{}  // closed by Close to wake publishers waiting for room
+	sending sync.WaitGroup // publishers that may still send to queue
+	stopped sync.WaitGroup
+}
+
+// subscription is a named handler for the events it accepts
+type subscription struct {
+	name   string
+	mode   Mode
+	retry  RetryPolicy
+	handle func(ctx context.Context, event entity.Event) (handled bool, err error)
+}
+
+// delivery is an event queued for an async subscriber
+type delivery struct {
+	ctx          context.Context
+	event        entity.Event
+	subscription *subscription
+	// result receives the outcome of the delivery, if not nil
+	result chan<- error
+}
+
+// Option configures a Bus
+type Option func(*Bus)
+
+// WithWorkers sets how many goroutines handle async deliveries
+func WithWorkers(workers int) Option {
+	return func(b *Bus) {
+		b.workers = workers
+	}
+}
+
+// WithQueueSize sets how many async deliveries may wait for a worker.
+// When the queue is full Publish blocks until there is room, its context is
+// done or the bus is closed.
+func WithQueueSize(size int) Option {
+	return func(b *Bus) {
+		b.queue = make(chan delivery, size)
+	}
+}
+
+// WithRetryPolicy sets the retry policy of subscribers that don't set their own
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(b *Bus) {
+		b.retry = policy
+	}
+}
+
+// WithDeadLetterStore sets where events go once a subscriber has exhausted
+// its attempts. Without one they are only logged.
+func WithDeadLetterStore(store DeadLetterStore) Option {
+	return func(b *Bus) {
+		b.deadLetters = store
+	}
+}
+
+// WithClock sets the clock that times retry backoff and timestamps dead letters
+func WithClock(c clock.Clock) Option {
+	return func(b *Bus) {
+		b.clock = c
+	}
+}
+
+// New creates a Bus and starts its worker pool. Close stops it.
+func New(opts ...Option) *Bus {
+	b := &Bus{
+		retry:   DefaultRetryPolicy,
+		clock:   clock.System,
+		workers: DefaultWorkers,
+		closing: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	if b.queue == nil {
+		b.queue = make(chan delivery, DefaultQueueSize)
+	}
+	if b.workers < 1 {
+		b.workers = 1
+	}
+
+	b.stopped.Add(b.workers)
+	for i := 0; i < b.workers; i++ {
+		go b.work()
+	}
+
+	return b
+}
+
+// SubscribeOption configures a single subscription
+type SubscribeOption func(*subscription)
+
+// WithMode sets how the subscriber receives events; the default is Sync
+func WithMode(mode Mode) SubscribeOption {
+	return func(s *subscription) {
+		s.mode = mode
+	}
+}
+
+// WithRetry overrides the bus's retry policy for this subscriber
+func WithRetry(policy RetryPolicy) SubscribeOption {
+	return func(s *subscription) {
+		s.retry = policy
+	}
+}
+
+// Subscribe registers handler for every published event of type E, e.g.
+// entity.UserRegistered. Subscribing to entity.Event receives all events.
+// The name identifies the subscriber in logs and dead letters.
+func Subscribe[E entity.Event](b *Bus, name string, handler func(ctx context.Context, event E) error, opts ...SubscribeOption) {
+	s := &subscription{
+		name:  name,
+		mode:  Sync,
+		retry: b.retry,
+		handle: func(ctx context.Context, event entity.Event) (bool, error) {
+			typed, ok := event.(E)
+			if !ok {
+				return false, nil
+			}
+			return true, handler(ctx, typed)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscriptions = append(b.subscriptions, s)
+}
+
+// Dispatch publishes events and, unlike Publish, waits until async
+// subscribers have handled them too; it makes the bus a
+// service.EventDispatcher. It returns the failures of every subscriber
+// joined, or ctx's error if ctx is done first, so that through an
+// OutboxRelay events stay in the outbox until every subscriber has handled
+// them. Async handlers carry on after ctx is done.
+func (b *Bus) Dispatch(ctx context.Context, events []entity.Event) error {
+	return b.publish(ctx, events, true)
+}
+
+// Publish delivers events, in order, to every subscriber of their type.
+// Sync subscribers have handled them by the time Publish returns; async
+// deliveries are queued and handled with a context that keeps ctx's values
+// but not its cancellation. A failing handler is retried and then
+// dead-lettered; Publish returns the failures of sync handlers joined, after
+// delivering every event to every other subscriber. It returns ErrClosed if
+// the bus is closed, or ctx's error if an async delivery can't be queued
+// before ctx is done; the events before it may already have been delivered.
+func (b *Bus) Publish(ctx context.Context, events ...entity.Event) error {
+	return b.publish(ctx, events, false)
+}
+
+// publish delivers events like Publish and, if wait is set, also waits for
+// the async deliveries and collects their failures
+func (b *Bus) publish(ctx context.Context, events []entity.Event, wait bool) error {
+	subscriptions, err := b.subscribers()
+	if err != nil {
+		return err
+	}
+
+	var (
+		failures []error
+		results  chan error
+		queued   int
+	)
+	if wait {
+		results = make(chan error, len(events)*len(subscriptions))
+	}
+	for _, event := range events {
+		for _, s := range subscriptions {
+			if s.mode == Sync {
+				failures = append(failures, b.deliver(ctx, event, s))
+				continue
+			}
+			if err := b.enqueue(ctx, event, s, results); err != nil {
+				return errors.Join(append(failures, err)...)
+			}
+			queued++
+		}
+	}
+
+	for ; wait && queued > 0; queued-- {
+		select {
+		case err := <-results:
+			failures = append(failures, err)
+		case <-ctx.Done():
+			return errors.Join(append(failures, fmt.Errorf("failed to wait for async subscribers: %w", ctx.Err()))...)
+		}
+	}
+
+	return errors.Join(failures...)
+}
+
+// Redeliver hands a dead-lettered event to the subscriber that failed it,
+// with a fresh set of attempts, and returns the handler's failure, if any
+func (b *Bus) Redeliver(ctx context.Context, letter DeadLetter) error {
+	subscriptions, err := b.subscribers()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range subscriptions {
+		if s.name == letter.Subscriber {
+			return b.deliver(ctx, letter.Event, s)
+		}
+	}
+	return fmt.Errorf("no subscriber named %q", letter.Subscriber)
+}
+
+// subscribers returns the current subscriptions, or ErrClosed. Handlers run
+// without the lock held, so they may subscribe or publish themselves.
+func (b *Bus) subscribers() ([]*subscription, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	return b.subscriptions, nil
+}
+
+// enqueue queues event for the async subscriber s, waiting for room in the
+// queue until ctx is done or the bus is closed. The outcome is sent to result
+// if it is not nil. The lock is not held while waiting, so that Close can
+// proceed; sending keeps the queue open until enqueue is done with it.
+func (b *Bus) enqueue(ctx context.Context, event entity.Event, s *subscription, result chan<- error) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	b.sending.Add(1)
+	b.mu.RUnlock()
+	defer b.sending.Done()
+
+	select {
+	case b.queue <- delivery{ctx: context.WithoutCancel(ctx), event: event, subscription: s, result: result}:
+		return nil
+	case <-b.closing:
+		return ErrClosed
+	case <-ctx.Done():
+		return fmt.Errorf("failed to queue event: %w", ctx.Err())
+	}
+}
+
+// Close stops accepting events and waits until queued async deliveries have
+// been handled or ctx is done. Publishers waiting for room in the queue get
+// ErrClosed.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	first := !b.closed
+	b.closed = true
+	b.mu.Unlock()
+
+	if first {
+		close(b.closing)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		if first {
+			b.sending.Wait()
+			close(b.queue)
+		}
+		b.stopped.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work handles async deliveries until the queue is closed
+func (b *Bus) work() {
+	defer b.stopped.Done()
+
+	for d := range b.queue {
+		err := b.deliver(d.ctx, d.event, d.subscription)
+		if d.result != nil {
+			d.result <- err // buffered for every queued delivery
+		}
+	}
+}
+
+// deliver hands event to s, retrying with backoff, and dead-letters it once
+// the attempts run out. It returns the last failure.
+func (b *Bus) deliver(ctx context.Context, event entity.Event, s *subscription) error {
+	attempts := s.retry.attempts()
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if waitErr := b.sleep(ctx, s.retry.backoff(attempt-1)); waitErr != nil {
+				err = errors.Join(err, waitErr)
+				b.deadLetter(ctx, event, s, attempt-1, err)
+				return b.failure(event, s, err)
+			}
+		}
+
+		var handled bool
+		handled, err = call(ctx, event, s)
+		if !handled || err == nil {
+			return nil
+		}
+	}
+
+	b.deadLetter(ctx, event, s, attempts, err)
+	return b.failure(event, s, err)
+}
+
+// failure describes a delivery of event that s failed
+func (b *Bus) failure(event entity.Event, s *subscription, err error) error {
+	return fmt.Errorf("subscriber %s failed to handle %s %s: %w", s.name, event.EventName(), event.EventID(), err)
+}
+
+// sleep waits for d on the bus's clock, returning early with ctx's error if
+// ctx is done first
+func (b *Bus) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.clock.After(d):
+		return nil
+	}
+}
+
+// call runs the subscriber's handler, turning a panic into an error
+func call(ctx context.Context, event entity.Event, s *subscription) (handled bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			handled, err = true, fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return s.handle(ctx, event)
+}
+
+// deadLetter records an event s failed to handle
+func (b *Bus) deadLetter(ctx context.Context, event entity.Event, s *subscription, attempts int, err error) {
+	log.Printf("Subscriber %s failed to handle %s for user %s after %d attempts: %v",
+		s.name, event.EventName(), event.AggregateID(), attempts, err)
+
+	if b.deadLetters == nil {
+		return
+	}
+
+	letter := DeadLetter{
+		Event:      event,
+		Subscriber: s.name,
+		Attempts:   attempts,
+		Err:        err,
+		At:         b.clock.Now(),
+	}
+	if err := b.deadLetters.Add(context.WithoutCancel(ctx), letter); err != nil {
+		log.Printf("Failed to store dead letter for subscriber %s: %v", s.name, err)
+	}
+}
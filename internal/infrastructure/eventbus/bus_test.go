@@ -0,0 +1,342 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// fastRetry retries quickly so tests don't wait on backoff
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2}
+
+func registered(id entity.UserID) entity.UserRegistered {
+	return entity.UserRegistered{EventMeta: entity.EventMeta{UserID: id}, Email: "test@example.com", Name: "Test"}
+}
+
+func renamed(id entity.UserID) entity.UserRenamed {
+	return entity.UserRenamed{EventMeta: entity.EventMeta{UserID: id}, OldName: "Old", NewName: "New"}
+}
+
+func closeBus(t *testing.T, bus *Bus) {
+	t.Helper()
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+}
+
+func TestBus_SyncTypedSubscribers(t *testing.T) {
+	bus := New(WithRetryPolicy(NoRetry))
+	defer closeBus(t, bus)
+
+	var registrations []entity.UserID
+	var all []string
+	Subscribe(bus, "welcome", func(ctx context.Context, event entity.UserRegistered) error {
+		registrations = append(registrations, event.UserID)
+		return nil
+	})
+	Subscribe(bus, "log", func(ctx context.Context, event entity.Event) error {
+		all = append(all, event.EventName())
+		return nil
+	})
+
+	if err := bus.Publish(context.Background(), registered("user_1"), renamed("user_1")); err != nil {
+		t.Fatalf("Publish() unexpected error: %v", err)
+	}
+
+	if len(registrations) != 1 || registrations[0] != "user_1" {
+		t.Errorf("typed subscriber got %v", registrations)
+	}
+	if len(all) != 2 || all[0] != entity.EventUserRegistered || all[1] != entity.EventUserRenamed {
+		t.Errorf("catch-all subscriber got %v", all)
+	}
+}
+
+func TestBus_RetryThenSucceed(t *testing.T) {
+	deadLetters := NewMemoryDeadLetterStore()
+	bus := New(WithRetryPolicy(fastRetry), WithDeadLetterStore(deadLetters))
+	defer closeBus(t, bus)
+
+	calls := 0
+	Subscribe(bus, "flaky", func(ctx context.Context, event entity.UserRegistered) error {
+		calls++
+		if calls < 3 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	_ = bus.Publish(context.Background(), registered("user_1"))
+
+	if calls != 3 {
+		t.Errorf("handler called %d times, want 3", calls)
+	}
+	if letters := deadLetters.List(); len(letters) != 0 {
+		t.Errorf("dead letters = %+v, want none", letters)
+	}
+}
+
+func TestBus_DeadLetterAndRedeliver(t *testing.T) {
+	deadLetters := NewMemoryDeadLetterStore()
+	bus := New(WithRetryPolicy(NoRetry), WithDeadLetterStore(deadLetters))
+	defer closeBus(t, bus)
+
+	failing := true
+	calls := 0
+	Subscribe(bus, "broken", func(ctx context.Context, event entity.UserRegistered) error {
+		calls++
+		if failing {
+			panic("boom")
+		}
+		return nil
+	}, WithRetry(fastRetry))
+	Subscribe(bus, "healthy", func(ctx context.Context, event entity.UserRegistered) error {
+		return nil
+	})
+
+	err := bus.Publish(context.Background(), registered("user_1"))
+	if err == nil || !strings.Contains(err.Error(), "subscriber broken") {
+		t.Errorf("Publish() error = %v, want the broken subscriber's failure", err)
+	}
+
+	letters := deadLetters.Drain()
+	if len(letters) != 1 {
+		t.Fatalf("dead letters = %+v, want one", letters)
+	}
+	if letters[0].Subscriber != "broken" || letters[0].Attempts != 3 || letters[0].Err == nil {
+		t.Errorf("dead letter = %+v", letters[0])
+	}
+	if calls != 3 {
+		t.Errorf("handler called %d times, want 3", calls)
+	}
+
+	failing = false
+	if err := bus.Redeliver(context.Background(), letters[0]); err != nil {
+		t.Fatalf("Redeliver() unexpected error: %v", err)
+	}
+	if calls != 4 || len(deadLetters.List()) != 0 {
+		t.Errorf("Redeliver() calls = %d, dead letters = %d", calls, len(deadLetters.List()))
+	}
+
+	if err := bus.Redeliver(context.Background(), DeadLetter{Subscriber: "unknown"}); err == nil {
+		t.Errorf("Redeliver() to unknown subscriber expected error")
+	}
+}
+
+func TestBus_BackoffUsesClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bus := New(WithClock(fake), WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour}))
+	defer closeBus(t, bus)
+
+	var calls atomic.Int32
+	Subscribe(bus, "flaky", func(ctx context.Context, event entity.UserRegistered) error {
+		if calls.Add(1) == 1 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	published := make(chan error, 1)
+	go func() { published <- bus.Publish(context.Background(), registered("user_1")) }()
+
+	deadline := time.After(time.Second)
+	for fake.Waiters() == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("Publish() did not wait for the backoff")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times before the backoff passed, want 1", calls.Load())
+	}
+
+	fake.Advance(time.Hour)
+	if err := <-published; err != nil {
+		t.Errorf("Publish() unexpected error: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("handler called %d times, want 2", calls.Load())
+	}
+}
+
+func TestBus_AsyncWorkerPool(t *testing.T) {
+	const workers = 3
+	bus := New(WithWorkers(workers), WithQueueSize(1), WithRetryPolicy(NoRetry))
+
+	var (
+		running, peak atomic.Int32
+		handled       sync.WaitGroup
+	)
+	Subscribe(bus, "slow", func(ctx context.Context, event entity.Event) error {
+		defer handled.Done()
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}, WithMode(Async))
+
+	const events = 20
+	handled.Add(events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < events; i++ {
+		_ = bus.Publish(ctx, registered("user_1"))
+	}
+	// Async handlers must outlive the publisher's context
+	cancel()
+
+	closeBus(t, bus)
+	handled.Wait()
+
+	if peak.Load() > workers {
+		t.Errorf("%d handlers ran at once, want at most %d", peak.Load(), workers)
+	}
+}
+
+func TestBus_Closed(t *testing.T) {
+	bus := New()
+	closeBus(t, bus)
+
+	if err := bus.Publish(context.Background(), registered("user_1")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish() after Close expected ErrClosed, got: %v", err)
+	}
+	// Closing twice is harmless
+	closeBus(t, bus)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3}
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second}
+
+	for i, w := range want {
+		if got := policy.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestBus_DispatchReportsFailures(t *testing.T) {
+	bus := New()
+	Subscribe(bus, "async", func(ctx context.Context, event entity.Event) error { return nil }, WithMode(Async))
+	closeBus(t, bus)
+
+	if err := bus.Dispatch(context.Background(), []entity.Event{registered("user_1")}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Dispatch() after Close expected ErrClosed, got: %v", err)
+	}
+}
+
+func TestBus_DispatchWaitsForAsyncSubscribers(t *testing.T) {
+	bus := New(WithRetryPolicy(NoRetry))
+	defer closeBus(t, bus)
+
+	var handled atomic.Int32
+	Subscribe(bus, "counter", func(ctx context.Context, event entity.Event) error {
+		time.Sleep(10 * time.Millisecond)
+		handled.Add(1)
+		return nil
+	}, WithMode(Async))
+	Subscribe(bus, "broken", func(ctx context.Context, event entity.UserRenamed) error {
+		return errors.New("broken")
+	}, WithMode(Async))
+
+	err := bus.Dispatch(context.Background(), []entity.Event{registered("user_1"), renamed("user_1")})
+	if err == nil || !strings.Contains(err.Error(), "subscriber broken") {
+		t.Errorf("Dispatch() expected the async failure, got: %v", err)
+	}
+	if got := handled.Load(); got != 2 {
+		t.Errorf("Dispatch() returned after %d of 2 async deliveries", got)
+	}
+}
+
+func TestBus_DispatchStopsWaitingWhenContextDone(t *testing.T) {
+	bus := New(WithRetryPolicy(NoRetry))
+	defer closeBus(t, bus)
+
+	release := make(chan struct{})
+	Subscribe(bus, "slow", func(ctx context.Context, event entity.Event) error {
+		<-release
+		return nil
+	}, WithMode(Async))
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bus.Dispatch(ctx, []entity.Event{registered("user_1")}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Dispatch() expected context.DeadlineExceeded, got: %v", err)
+	}
+}
+
+func TestBus_CloseWithFullQueue(t *testing.T) {
+	bus := New(WithWorkers(1), WithQueueSize(1), WithRetryPolicy(NoRetry))
+
+	release := make(chan struct{})
+	var handled atomic.Int32
+	Subscribe(bus, "blocked", func(ctx context.Context, event entity.Event) error {
+		<-release
+		handled.Add(1)
+		return nil
+	}, WithMode(Async))
+
+	// One event keeps the worker busy, the next fills the queue and the
+	// third waits for room with a context that is never done
+	published := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() { published <- bus.Publish(context.Background(), registered("user_1")) }()
+	}
+	deadline := time.After(time.Second)
+	for len(bus.queue) < 1 {
+		select {
+		case <-deadline:
+			t.Fatalf("the queue did not fill up")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() { closed <- bus.Close(context.Background()) }()
+
+	var errs []error
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-published:
+			errs = append(errs, err)
+		case <-time.After(time.Second):
+			close(release)
+			t.Fatalf("Publish() still blocked after Close")
+		}
+	}
+	close(release)
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close() unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Close() deadlocked with a full queue")
+	}
+
+	var rejected int
+	for _, err := range errs {
+		if errors.Is(err, ErrClosed) {
+			rejected++
+		}
+	}
+	if rejected+int(handled.Load()) != 3 || rejected == 0 {
+		t.Errorf("%d events handled and %d rejected, want the waiting one rejected and the rest handled", handled.Load(), rejected)
+	}
+}
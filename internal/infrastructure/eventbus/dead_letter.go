@@ -0,0 +1,65 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// DeadLetter is an event a subscriber failed to handle after all its attempts
+type DeadLetter struct {
+	Event      entity.Event
+	Subscriber string
+	Attempts   int
+	Err        error
+	At         time.Time
+}
+
+// DeadLetterStore keeps events that could not be delivered, so they can be
+// inspected and replayed
+type DeadLetterStore interface {
+	Add(ctx context.Context, letter DeadLetter) error
+}
+
+// MemoryDeadLetterStore is a DeadLetterStore that keeps letters in memory.
+// They are lost when the process exits, so it suits tests and development;
+// postgres.DeadLetterStore keeps them across restarts.
+type MemoryDeadLetterStore struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+// NewMemoryDeadLetterStore creates an empty MemoryDeadLetterStore
+func NewMemoryDeadLetterStore() *MemoryDeadLetterStore {
+	return &MemoryDeadLetterStore{}
+}
+
+// Add stores letter
+func (s *MemoryDeadLetterStore) Add(ctx context.Context, letter DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.letters = append(s.letters, letter)
+	return nil
+}
+
+// List returns the stored letters, oldest first
+func (s *MemoryDeadLetterStore) List() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]DeadLetter(nil), s.letters...)
+}
+
+// Drain removes and returns the stored letters, oldest first, e.g. to
+// publish them again once the failing subscriber has been fixed
+func (s *MemoryDeadLetterStore) Drain() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters := s.letters
+	s.letters = nil
+	return letters
+}
@@ -0,0 +1,57 @@
+package eventbus
+
+import "time"
+
+// RetryPolicy controls how often a failing handler is retried and how long
+// the bus waits between attempts
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 1 mean a single attempt.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between retries; zero means no cap
+	MaxBackoff time.Duration
+	// Multiplier grows the wait after every retry; values below 1 mean 2
+	Multiplier float64
+}
+
+// DefaultRetryPolicy retries a handler up to twice more, waiting 100ms and then 200ms
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+}
+
+// NoRetry makes a single attempt
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// attempts returns the total number of attempts the policy allows
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the wait before retry number n, starting at 1
+func (p RetryPolicy) backoff(n int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	wait := float64(p.InitialBackoff)
+	for i := 1; i < n; i++ {
+		wait *= multiplier
+		if p.MaxBackoff > 0 && wait >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+
+	if p.MaxBackoff > 0 && time.Duration(wait) > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return time.Duration(wait)
+}
@@ -0,0 +1,227 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// MemoryStore is a thread-safe in-memory Store. Events are immutable values,
+// so records are shared; snapshots are stored and returned as copies.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	log       []Record
+	streams   map[entity.UserID][]Record
+	snapshots map[entity.UserID]Snapshot
+	passwords map[entity.UserID]string
+	outbox    []entity.Event
+	position  int64
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		streams:   make(map[entity.UserID][]Record),
+		snapshots: make(map[entity.UserID]Snapshot),
+		passwords: make(map[entity.UserID]string),
+	}
+}
+
+// Append adds events to the user's stream if it is at version expected
+func (s *MemoryStore) Append(ctx context.Context, id entity.UserID, expected int64, events []entity.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if actual := streamVersion(s.streams[id]); actual != expected {
+		return &ConflictError{UserID: id, Expected: expected, Actual: actual}
+	}
+
+	public := entity.WithoutSecrets(events)
+	for _, event := range public {
+		s.position++
+		record := Record{Position: s.position, Version: expected + 1, Event: event}
+		s.log = append(s.log, record)
+		s.streams[id] = append(s.streams[id], record)
+	}
+	if hash, ok := entity.PasswordHashIn(events); ok {
+		s.passwords[id] = hash
+	}
+	s.outbox = append(s.outbox, public...)
+	return nil
+}
+
+// LoadPasswordHash returns the user's current password hash
+func (s *MemoryStore) LoadPasswordHash(ctx context.Context, id entity.UserID) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.streams[id]; !exists {
+		return "", ErrStreamNotFound
+	}
+	return s.passwords[id], nil
+}
+
+// Load returns the user's records with a version above after
+func (s *MemoryStore) Load(ctx context.Context, id entity.UserID, after int64) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream, exists := s.streams[id]
+	if !exists {
+		return nil, ErrStreamNotFound
+	}
+
+	var records []Record
+	for _, record := range stream {
+		if record.Version > after {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// ReadAll returns up to limit records with a position above after
+func (s *MemoryStore) ReadAll(ctx context.Context, after int64, limit int) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []Record
+	for _, record := range s.log {
+		if len(records) == limit {
+			break
+		}
+		if record.Position > after {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// DeleteStream removes the user's stream, snapshot and password hash
+func (s *MemoryStore) DeleteStream(ctx context.Context, id entity.UserID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.streams[id]; !exists {
+		return ErrStreamNotFound
+	}
+
+	kept := s.log[:0]
+	for _, record := range s.log {
+		if record.Event.AggregateID() != id {
+			kept = append(kept, record)
+		}
+	}
+	clear(s.log[len(kept):])
+	s.log = kept
+
+	delete(s.streams, id)
+	delete(s.snapshots, id)
+	delete(s.passwords, id)
+	return nil
+}
+
+// SaveSnapshot stores a copy of snapshot
+func (s *MemoryStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.streams[snapshot.UserID]; !exists {
+		return ErrStreamNotFound
+	}
+
+	snapshot.State = append([]byte(nil), snapshot.State...)
+	s.snapshots[snapshot.UserID] = snapshot
+	return nil
+}
+
+// LoadSnapshot returns a copy of the user's latest snapshot, or nil
+func (s *MemoryStore) LoadSnapshot(ctx context.Context, id entity.UserID) (*Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot, exists := s.snapshots[id]
+	if !exists {
+		return nil, nil
+	}
+
+	snapshot.State = append([]byte(nil), snapshot.State...)
+	return &snapshot, nil
+}
+
+// PendingEvents returns up to limit unpublished events, oldest first
+func (s *MemoryStore) PendingEvents(ctx context.Context, limit int) ([]entity.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if limit > len(s.outbox) {
+		limit = len(s.outbox)
+	}
+	return append([]entity.Event(nil), s.outbox[:limit]...), nil
+}
+
+// MarkEventsPublished removes the events with the given IDs from the outbox
+func (s *MemoryStore) MarkEventsPublished(ctx context.Context, ids ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	published := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		published[id] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.outbox[:0]
+	for _, event := range s.outbox {
+		if !published[event.EventID()] {
+			pending = append(pending, event)
+		}
+	}
+	clear(s.outbox[len(pending):])
+	s.outbox = pending
+	return nil
+}
+
+// streamVersion returns the version of the last record in stream, 0 if empty
+func streamVersion(stream []Record) int64 {
+	if len(stream) == 0 {
+		return 0
+	}
+	return stream[len(stream)-1].Version
+}
@@ -0,0 +1,62 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultReplayBatchSize is how many records a Replayer reads at a time
+const DefaultReplayBatchSize = 500
+
+// Projection is a read model derived from the records of every stream
+type Projection interface {
+	// Reset clears the projection before it is rebuilt from scratch
+	Reset()
+	// Apply updates the projection with record. Records arrive in position order.
+	Apply(record Record) error
+}
+
+// Replayer feeds the records of a Store to projections
+type Replayer struct {
+	store     Store
+	batchSize int
+}
+
+// NewReplayer creates a Replayer reading from store
+func NewReplayer(store Store) *Replayer {
+	return &Replayer{
+		store:     store,
+		batchSize: DefaultReplayBatchSize,
+	}
+}
+
+// Rebuild resets projection and replays every stored record into it. It
+// returns the position of the last record applied, from which CatchUp can
+// continue later.
+func (r *Replayer) Rebuild(ctx context.Context, projection Projection) (int64, error) {
+	projection.Reset()
+	return r.CatchUp(ctx, projection, 0)
+}
+
+// CatchUp applies the records with a position above after to projection and
+// returns the position of the last one applied. If applying a record fails
+// it stops there and returns the position of the record before it.
+func (r *Replayer) CatchUp(ctx context.Context, projection Projection, after int64) (int64, error) {
+	for {
+		records, err := r.store.ReadAll(ctx, after, r.batchSize)
+		if err != nil {
+			return after, fmt.Errorf("failed to read events after position %d: %w", after, err)
+		}
+
+		for _, record := range records {
+			if err := projection.Apply(record); err != nil {
+				return after, fmt.Errorf("failed to apply event at position %d: %w", record.Position, err)
+			}
+			after = record.Position
+		}
+
+		if len(records) < r.batchSize {
+			return after, nil
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// countingProjection counts the events it is given, by name
+type countingProjection struct {
+	counts map[string]int
+	fail   string
+}
+
+func (p *countingProjection) Reset() {
+	p.counts = make(map[string]int)
+}
+
+func (p *countingProjection) Apply(record Record) error {
+	if record.Event.EventName() == p.fail {
+		return errors.New("projection failed")
+	}
+	p.counts[record.Event.EventName()]++
+	return nil
+}
+
+func TestReplayer(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for i := 0; i < 3; i++ {
+		user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+		_ = user.Activate("verified", "admin")
+		_ = store.Append(ctx, user.ID, 0, user.PullEvents())
+	}
+
+	replayer := NewReplayer(store)
+	replayer.batchSize = 2
+	projection := &countingProjection{counts: map[string]int{"stale": 1}}
+
+	position, err := replayer.Rebuild(ctx, projection)
+	if err != nil {
+		t.Fatalf("Rebuild() unexpected error: %v", err)
+	}
+	if position != 6 || projection.counts["stale"] != 0 ||
+		projection.counts[entity.EventUserRegistered] != 3 || projection.counts[entity.EventUserStatusChanged] != 3 {
+		t.Errorf("Rebuild() = %d, counts %v", position, projection.counts)
+	}
+
+	user, _ := entity.NewUser("new@example.com", "New User", testPassword)
+	_ = store.Append(ctx, user.ID, 0, user.PullEvents())
+
+	if position, err = replayer.CatchUp(ctx, projection, position); err != nil || position != 7 {
+		t.Errorf("CatchUp() = %d, %v, want 7", position, err)
+	}
+	if projection.counts[entity.EventUserRegistered] != 4 {
+		t.Errorf("CatchUp() counts %v", projection.counts)
+	}
+
+	projection.fail = entity.EventUserStatusChanged
+	if position, err = replayer.Rebuild(ctx, projection); err == nil || position != 1 {
+		t.Errorf("Rebuild() with failing projection = %d, %v, want position 1 and an error", position, err)
+	}
+}
+
+func TestMemoryStore_AppendConflict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	_ = store.Append(ctx, user.ID, 0, user.PullEvents())
+
+	_ = user.Activate("verified", "admin")
+	err := store.Append(ctx, user.ID, 0, user.Events())
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) || conflict.Actual != 1 {
+		t.Fatalf("Append() expected ConflictError at version 1, got: %v", err)
+	}
+
+	if err := store.Append(ctx, user.ID, 1, user.PullEvents()); err != nil {
+		t.Fatalf("Append() unexpected error: %v", err)
+	}
+	records, _ := store.Load(ctx, user.ID, 1)
+	if len(records) != 1 || records[0].Version != 2 || records[0].Position != 2 {
+		t.Errorf("Load() = %+v", records)
+	}
+}
@@ -0,0 +1,63 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// snapshotState is the JSON form of a user kept in a Snapshot. Like the
+// stored events it leaves out the password hash, which the Store keeps apart.
+type snapshotState struct {
+	ID             entity.UserID             `json:"id"`
+	Email          entity.Email              `json:"email"`
+	CanonicalEmail entity.Email              `json:"canonical_email"`
+	Name           string                    `json:"name"`
+	Status         entity.UserStatus         `json:"status"`
+	StatusHistory  []entity.StatusTransition `json:"status_history"`
+	Roles          []entity.Role             `json:"roles,omitempty"`
+	Tenant         entity.Tenant             `json:"tenant,omitempty"`
+	CreatedAt      time.Time                 `json:"created_at"`
+	UpdatedAt      time.Time                 `json:"updated_at"`
+	DeletedAt      time.Time                 `json:"deleted_at"`
+}
+
+// encodeSnapshot encodes the state of user; its version is kept by the Snapshot
+func encodeSnapshot(user *entity.User) ([]byte, error) {
+	return json.Marshal(snapshotState{
+		ID:             user.ID,
+		Email:          user.Email,
+		CanonicalEmail: user.CanonicalEmail,
+		Name:           user.Name,
+		Status:         user.Status,
+		StatusHistory:  user.StatusHistory,
+		Roles:          user.Roles,
+		Tenant:         user.Tenant,
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
+		DeletedAt:      user.DeletedAt,
+	})
+}
+
+// decodeSnapshot restores a user encoded by encodeSnapshot, without a password
+func decodeSnapshot(data []byte) (*entity.User, error) {
+	var state snapshotState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return &entity.User{
+		ID:             state.ID,
+		Email:          state.Email,
+		CanonicalEmail: state.CanonicalEmail,
+		Name:           state.Name,
+		Status:         state.Status,
+		StatusHistory:  state.StatusHistory,
+		Roles:          state.Roles,
+		Tenant:         state.Tenant,
+		CreatedAt:      state.CreatedAt,
+		UpdatedAt:      state.UpdatedAt,
+		DeletedAt:      state.DeletedAt,
+	}, nil
+}
@@ -0,0 +1,96 @@
+// Package eventstore keeps users as streams of their domain events and
+// rebuilds them, and read models over them, by replaying those events.
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+// Store errors
+var (
+	ErrStreamNotFound = errors.New("event stream not found")
+	ErrConflict       = errors.New("event stream was appended to concurrently")
+)
+
+// ConflictError reports an append to a stream that is no longer at the
+// expected version. It matches ErrConflict with errors.Is.
+type ConflictError struct {
+	UserID   entity.UserID
+	Expected int64
+	Actual   int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: stream %s is at version %d, append expected version %d",
+		ErrConflict, e.UserID, e.Actual, e.Expected)
+}
+
+// Is makes errors.Is(err, ErrConflict) report true
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Record is an event as stored in its user's stream
+type Record struct {
+	// Position orders records across all streams; it starts at 1
+	Position int64
+	// Version is the stream version the event was appended at. Events
+	// appended together share a version, so it matches User.Version.
+	Version int64
+	Event   entity.Event
+}
+
+// Snapshot is the encoded state of a user as of a stream version
+type Snapshot struct {
+	UserID  entity.UserID
+	Version int64
+	State   []byte
+}
+
+// Store keeps one append-only stream of events per user.
+// Like repositories, its methods fail with ctx.Err() once ctx is done.
+// It is also the repository.Outbox of the events appended to it: every event
+// enters the outbox in the same write as its stream, so an event that was
+// appended is published even if the process stops right after.
+//
+// Events are stored as entity.WithoutSecrets returns them. The user's current
+// password hash is kept apart from the stream instead, written along with
+// the events that set it and removed with the stream.
+type Store interface {
+	repository.Outbox
+
+	// Append adds events to the user's stream at version expected+1 if the
+	// stream is at version expected, where 0 means it must not exist yet.
+	// Otherwise it fails with a *ConflictError and stores nothing.
+	// Appending no events only checks the version.
+	Append(ctx context.Context, id entity.UserID, expected int64, events []entity.Event) error
+
+	// LoadPasswordHash returns the password hash set by the last event of the
+	// user's stream that set one, or "" if none did. It fails with
+	// ErrStreamNotFound if there is no stream.
+	LoadPasswordHash(ctx context.Context, id entity.UserID) (string, error)
+
+	// Load returns the records of the user's stream with a version above
+	// after, oldest first. It fails with ErrStreamNotFound if there is no stream.
+	Load(ctx context.Context, id entity.UserID, after int64) ([]Record, error)
+
+	// ReadAll returns up to limit records of every stream with a position
+	// above after, in position order. A record must never become visible
+	// after one with a higher position, so that readers continuing from the
+	// last position they saw miss nothing.
+	ReadAll(ctx context.Context, after int64, limit int) ([]Record, error)
+
+	// DeleteStream permanently removes the user's stream and snapshot
+	DeleteStream(ctx context.Context, id entity.UserID) error
+
+	// SaveSnapshot stores snapshot, replacing the user's previous one
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) error
+
+	// LoadSnapshot returns the user's latest snapshot, or nil if there is none
+	LoadSnapshot(ctx context.Context, id entity.UserID) (*Snapshot, error)
+}
@@ -0,0 +1,56 @@
+package eventstore
+
+import (
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// userIndex is the Projection of every stored user's current state, by ID
+// and by canonical email
+type userIndex struct {
+	users   map[entity.UserID]*entity.User
+	byEmail map[entity.Email]entity.UserID
+}
+
+// newUserIndex creates an empty userIndex
+func newUserIndex() *userIndex {
+	index := &userIndex{}
+	index.Reset()
+	return index
+}
+
+// Reset empties the index
+func (x *userIndex) Reset() {
+	x.users = make(map[entity.UserID]*entity.User)
+	x.byEmail = make(map[entity.Email]entity.UserID)
+}
+
+// Apply applies record's event to the user it belongs to
+func (x *userIndex) Apply(record Record) error {
+	id := record.Event.AggregateID()
+
+	user, exists := x.users[id]
+	if !exists {
+		user = &entity.User{}
+	}
+
+	previousEmail := user.CanonicalEmail
+	if err := user.Apply(record.Event); err != nil {
+		return err
+	}
+	user.Version = record.Version
+
+	if previousEmail != user.CanonicalEmail {
+		delete(x.byEmail, previousEmail)
+	}
+	x.users[id] = user
+	x.byEmail[user.CanonicalEmail] = id
+	return nil
+}
+
+// remove drops a user from the index
+func (x *userIndex) remove(id entity.UserID) {
+	if user, exists := x.users[id]; exists {
+		delete(x.byEmail, user.CanonicalEmail)
+		delete(x.users, id)
+	}
+}
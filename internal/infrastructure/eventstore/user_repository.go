@@ -0,0 +1,462 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+// DefaultSnapshotInterval is how many versions apart user snapshots are taken
+const DefaultSnapshotInterval = 20
+
+// UserRepository is an implementation of repository.UserRepository that
+// stores each user as the stream of its domain events and rebuilds users by
+// replaying them, starting from the latest snapshot. Changes must therefore
+// be made through entity.User's methods, which record events; fields set
+// directly are not stored.
+//
+// Lookups by email and listings are served from an index of current users,
+// a Projection of the store that the repository builds when it is created.
+// The store stays the source of truth: before every use the index catches up
+// with the records appended since, by this or any other repository over the
+// same store, and users are always loaded from the store itself. Streams that
+// another repository deletes drop out of listings on the next Rebuild;
+// lookups and the email check see at once that they are gone.
+//
+// Email uniqueness is checked against the index, so two repositories
+// creating users with the same email at the same moment can both succeed.
+//
+// It is also the repository.Outbox for the events it stores, which it leaves
+// to the store so that they survive a restart.
+type UserRepository struct {
+	store            Store
+	replayer         *Replayer
+	snapshotInterval int64
+
+	mu    sync.Mutex
+	index *userIndex
+	// position is that of the last record applied to index
+	position int64
+}
+
+// Option configures a UserRepository
+type Option func(*UserRepository)
+
+// WithSnapshotInterval sets how many versions apart snapshots are taken;
+// 0 disables snapshots
+func WithSnapshotInterval(versions int64) Option {
+	return func(r *UserRepository) {
+		r.snapshotInterval = versions
+	}
+}
+
+// NewUserRepository creates a UserRepository over store and builds its index
+// by replaying every stored event
+func NewUserRepository(ctx context.Context, store Store, opts ...Option) (*UserRepository, error) {
+	r := &UserRepository{
+		store:            store,
+		replayer:         NewReplayer(store),
+		snapshotInterval: DefaultSnapshotInterval,
+		index:            newUserIndex(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err := r.Rebuild(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Rebuild replaces the index of current users with one replayed from scratch
+func (r *UserRepository) Rebuild(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	index := newUserIndex()
+	position, err := r.replayer.Rebuild(ctx, index)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild user index: %w", err)
+	}
+
+	r.index, r.position = index, position
+	return nil
+}
+
+// Create starts the stream of a new user with its pending events.
+// It fails with repository.ErrUserAlreadyExists if the ID or the email is taken.
+func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
+	if user == nil {
+		return repository.ErrInvalidUser
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.catchUp(ctx); err != nil {
+		return err
+	}
+
+	if _, exists := r.index.users[user.ID]; exists {
+		return repository.ErrUserAlreadyExists
+	}
+
+	if err := r.checkEmailAvailable(ctx, user); err != nil {
+		return err
+	}
+
+	events := user.Events()
+	if len(events) == 0 {
+		return fmt.Errorf("%w: user %s has no events to store", repository.ErrInvalidUser, user.ID)
+	}
+
+	err := r.store.Append(ctx, user.ID, 0, events)
+	if errors.Is(err, ErrConflict) {
+		return repository.ErrUserAlreadyExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append events of user %s: %w", user.ID, err)
+	}
+
+	r.stored(ctx, user, 1)
+	return nil
+}
+
+// FindByID rebuilds a user from its latest snapshot and the events after it
+func (r *UserRepository) FindByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	user, err := r.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDeleted() {
+		return nil, repository.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// FindDeletedByID rebuilds a soft-deleted user
+func (r *UserRepository) FindDeletedByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	user, err := r.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsDeleted() {
+		return nil, repository.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// FindByEmail rebuilds the user who owns the canonical email
+func (r *UserRepository) FindByEmail(ctx context.Context, email entity.Email) (*entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	err := r.catchUp(ctx)
+	id, exists := r.index.byEmail[email]
+	r.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, repository.ErrUserNotFound
+	}
+	return r.FindByID(ctx, id)
+}
+
+// FindByIDAsOf rebuilds a user as it was at the given time, soft-deleted or
+// not, by replaying only the events that had happened by then. It fails with
+// repository.ErrUserNotFound if the user did not exist yet. It makes the
+// repository a repository.PastUserFinder.
+func (r *UserRepository) FindByIDAsOf(ctx context.Context, id entity.UserID, at time.Time) (*entity.User, error) {
+	records, err := r.store.Load(ctx, id, 0)
+	if errors.Is(err, ErrStreamNotFound) {
+		return nil, repository.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events of user %s: %w", id, err)
+	}
+
+	var past []Record
+	for _, record := range records {
+		if record.Event.OccurredAt().After(at) {
+			break
+		}
+		past = append(past, record)
+	}
+	if len(past) == 0 {
+		return nil, repository.ErrUserNotFound
+	}
+
+	return replay(&entity.User{}, past)
+}
+
+// Update appends the user's pending events to its stream if the stream is
+// still at user.Version, then increments user.Version. It fails with
+// repository.ErrUserAlreadyExists if the new email belongs to another user.
+// A user without pending events has nothing to store and keeps its version.
+func (r *UserRepository) Update(ctx context.Context, user *entity.User) error {
+	if user == nil {
+		return repository.ErrInvalidUser
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.catchUp(ctx); err != nil {
+		return err
+	}
+
+	current, exists := r.index.users[user.ID]
+	if !exists {
+		return repository.ErrUserNotFound
+	}
+
+	if current.Version != user.Version {
+		return &repository.VersionConflictError{ID: user.ID, Expected: user.Version, Actual: current.Version}
+	}
+
+	if err := r.checkEmailAvailable(ctx, user); err != nil {
+		return err
+	}
+
+	events := user.Events()
+	if len(events) == 0 {
+		return nil
+	}
+
+	err := r.store.Append(ctx, user.ID, user.Version, events)
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return &repository.VersionConflictError{ID: user.ID, Expected: user.Version, Actual: conflict.Actual}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append events of user %s: %w", user.ID, err)
+	}
+
+	user.Version++
+	r.stored(ctx, user, user.Version)
+	return nil
+}
+
+// Delete permanently removes a user's stream, deleted or not
+func (r *UserRepository) Delete(ctx context.Context, id entity.UserID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.catchUp(ctx); err != nil {
+		return err
+	}
+
+	if _, exists := r.index.users[id]; !exists {
+		return repository.ErrUserNotFound
+	}
+
+	if err := r.store.DeleteStream(ctx, id); err != nil && !errors.Is(err, ErrStreamNotFound) {
+		return fmt.Errorf("failed to delete events of user %s: %w", id, err)
+	}
+
+	r.index.remove(id)
+	return nil
+}
+
+// PurgeDeleted permanently removes the streams of users soft-deleted before cutoff
+func (r *UserRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.catchUp(ctx); err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for id, user := range r.index.users {
+		if !user.IsDeleted() || !user.DeletedAt.Before(cutoff) {
+			continue
+		}
+
+		if err := r.store.DeleteStream(ctx, id); err != nil && !errors.Is(err, ErrStreamNotFound) {
+			return purged, fmt.Errorf("failed to delete events of user %s: %w", id, err)
+		}
+
+		r.index.remove(id)
+		purged++
+	}
+	return purged, nil
+}
+
+// List returns one page of users matching the query from the index
+func (r *UserRepository) List(ctx context.Context, query repository.ListQuery) (*repository.UserPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.catchUp(ctx); err != nil {
+		return nil, err
+	}
+
+	users := make([]*entity.User, 0, len(r.index.users))
+	for _, user := range r.index.users {
+		users = append(users, user)
+	}
+	page, err := query.Paginate(users)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, user := range page.Users {
+		page.Users[i] = user.Clone()
+	}
+	return page, nil
+}
+
+// PendingEvents returns up to limit unpublished events from the store, oldest first
+func (r *UserRepository) PendingEvents(ctx context.Context, limit int) ([]entity.Event, error) {
+	return r.store.PendingEvents(ctx, limit)
+}
+
+// MarkEventsPublished removes the events with the given IDs from the store's outbox
+func (r *UserRepository) MarkEventsPublished(ctx context.Context, ids ...string) error {
+	return r.store.MarkEventsPublished(ctx, ids...)
+}
+
+// catchUp applies the records appended to the store since the index was
+// last brought up to date. Callers must hold the lock.
+func (r *UserRepository) catchUp(ctx context.Context) error {
+	position, err := r.replayer.CatchUp(ctx, r.index, r.position)
+	r.position = position
+	if err != nil {
+		return fmt.Errorf("failed to update user index: %w", err)
+	}
+	return nil
+}
+
+// stored clears the events of user just appended at version from it, brings
+// the index up to date and takes a snapshot when one is due. The append has
+// committed by then, so a failure to index it is logged rather than
+// returned; the next catch-up applies the records again. Callers must hold
+// the lock.
+func (r *UserRepository) stored(ctx context.Context, user *entity.User, version int64) {
+	user.PullEvents()
+	if err := r.catchUp(ctx); err != nil {
+		log.Printf("Failed to index user %s at version %d: %v", user.ID, version, err)
+		return
+	}
+
+	if indexed, ok := r.index.users[user.ID]; ok && indexed.Version == version &&
+		r.snapshotInterval > 0 && version%r.snapshotInterval == 0 {
+		r.snapshot(ctx, indexed, version)
+	}
+}
+
+// snapshot saves the state of user at version. Snapshots only speed up
+// loading, so a failure is logged rather than returned.
+func (r *UserRepository) snapshot(ctx context.Context, user *entity.User, version int64) {
+	state, err := encodeSnapshot(user)
+	if err == nil {
+		err = r.store.SaveSnapshot(ctx, Snapshot{UserID: user.ID, Version: version, State: state})
+	}
+	if err != nil {
+		log.Printf("Failed to snapshot user %s at version %d: %v", user.ID, version, err)
+	}
+}
+
+// load rebuilds a user from its latest snapshot and the events after it
+func (r *UserRepository) load(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	user := &entity.User{}
+
+	snapshot, err := r.store.LoadSnapshot(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot of user %s: %w", id, err)
+	}
+	if snapshot != nil {
+		if user, err = decodeSnapshot(snapshot.State); err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot of user %s: %w", id, err)
+		}
+		user.Version = snapshot.Version
+	}
+
+	records, err := r.store.Load(ctx, id, user.Version)
+	if errors.Is(err, ErrStreamNotFound) {
+		return nil, repository.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events of user %s: %w", id, err)
+	}
+
+	if user, err = replay(user, records); err != nil {
+		return nil, err
+	}
+
+	hash, err := r.store.LoadPasswordHash(ctx, id)
+	if errors.Is(err, ErrStreamNotFound) {
+		return nil, repository.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load password of user %s: %w", id, err)
+	}
+	if user.Password, err = entity.PasswordFromHash(hash); err != nil {
+		return nil, fmt.Errorf("failed to restore password of user %s: %w", id, err)
+	}
+	return user, nil
+}
+
+// replay applies records to user in order and sets its version to theirs
+func replay(user *entity.User, records []Record) (*entity.User, error) {
+	for _, record := range records {
+		if err := user.Apply(record.Event); err != nil {
+			return nil, fmt.Errorf("failed to replay event %s: %w", record.Event.EventID(), err)
+		}
+		user.Version = record.Version
+	}
+	return user, nil
+}
+
+// checkEmailAvailable reports whether user's email is free or already owned
+// by user. An owner whose stream another repository has deleted is dropped
+// from the index. Callers must hold the lock.
+func (r *UserRepository) checkEmailAvailable(ctx context.Context, user *entity.User) error {
+	ownerID, taken := r.index.byEmail[user.CanonicalEmail]
+	if !taken || ownerID == user.ID {
+		return nil
+	}
+
+	// Loading past the last version reads no records, only whether the stream exists
+	_, err := r.store.Load(ctx, ownerID, math.MaxInt64)
+	if errors.Is(err, ErrStreamNotFound) {
+		r.index.remove(ownerID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check owner of email: %w", err)
+	}
+	return repository.ErrUserAlreadyExists
+}
@@ -0,0 +1,385 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+// testPassword is a cheaply hashed password shared by tests
+var testPassword, _ = entity.PasswordHasher{Iterations: 1000, SaltLength: 16, KeyLength: 32}.Hash("Secret-passw0rd")
+
+// newTestRepository creates a UserRepository over an empty MemoryStore
+func newTestRepository(t *testing.T, opts ...Option) (*UserRepository, *MemoryStore) {
+	t.Helper()
+
+	store := NewMemoryStore()
+	repo, err := NewUserRepository(context.Background(), store, opts...)
+	if err != nil {
+		t.Fatalf("NewUserRepository() unexpected error: %v", err)
+	}
+	return repo, store
+}
+
+func TestUserRepository_CreateAndFind(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+	user, _ := entity.NewUser("Test@Example.com", "Test User", testPassword)
+
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	if pending := user.Events(); len(pending) != 0 {
+		t.Errorf("Create() left %d pending events", len(pending))
+	}
+
+	byID, err := repo.FindByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("FindByID() unexpected error: %v", err)
+	}
+	if byID.Email != user.Email || byID.CanonicalEmail != user.CanonicalEmail || byID.Version != 1 ||
+		byID.Password.Hash() != user.Password.Hash() || !byID.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("FindByID() = %+v, want %+v", byID, user)
+	}
+
+	byEmail, err := repo.FindByEmail(ctx, user.CanonicalEmail)
+	if err != nil || byEmail.ID != user.ID {
+		t.Errorf("FindByEmail() = %v, %v, want user %s", byEmail, err, user.ID)
+	}
+
+	if err := repo.Create(ctx, user); err != repository.ErrUserAlreadyExists {
+		t.Errorf("Create() twice expected ErrUserAlreadyExists, got: %v", err)
+	}
+
+	other, _ := entity.NewUser("test@example.com", "Other", testPassword)
+	if err := repo.Create(ctx, other); err != repository.ErrUserAlreadyExists {
+		t.Errorf("Create() with taken email expected ErrUserAlreadyExists, got: %v", err)
+	}
+}
+
+func TestUserRepository_CreateWithoutEvents(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	user.PullEvents()
+
+	if err := repo.Create(context.Background(), user); !errors.Is(err, repository.ErrInvalidUser) {
+		t.Errorf("Create() expected ErrInvalidUser, got: %v", err)
+	}
+}
+
+func TestUserRepository_Update(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	_ = repo.Create(ctx, user)
+
+	first, _ := repo.FindByID(ctx, user.ID)
+	second, _ := repo.FindByID(ctx, user.ID)
+
+	_ = first.Update("updated@example.com", "First Admin")
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Update() Version = %d, want 2", first.Version)
+	}
+
+	_ = second.Update("test@example.com", "Second Admin")
+	var conflict *repository.VersionConflictError
+	if err := repo.Update(ctx, second); !errors.As(err, &conflict) || conflict.Actual != 2 {
+		t.Fatalf("Update() expected VersionConflictError at version 2, got: %v", err)
+	}
+
+	found, _ := repo.FindByEmail(ctx, "updated@example.com")
+	if found == nil || found.Name != "First Admin" || found.Version != 2 {
+		t.Errorf("FindByEmail() = %+v, want the first update", found)
+	}
+	if _, err := repo.FindByEmail(ctx, "test@example.com"); err != repository.ErrUserNotFound {
+		t.Errorf("FindByEmail() old email expected ErrUserNotFound, got: %v", err)
+	}
+
+	// Without pending events there is nothing to store
+	if err := repo.Update(ctx, found); err != nil || found.Version != 2 {
+		t.Errorf("Update() without changes = %v, version %d, want nil, 2", err, found.Version)
+	}
+}
+
+func TestUserRepository_Snapshots(t *testing.T) {
+	ctx := context.Background()
+	repo, store := newTestRepository(t, WithSnapshotInterval(3))
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	_ = repo.Create(ctx, user)
+	_ = user.SetTenant("acme", "admin")
+
+	for _, name := range []string{"Second", "Third"} {
+		_ = user.Update("test@example.com", name)
+		if err := repo.Update(ctx, user); err != nil {
+			t.Fatalf("Update() unexpected error: %v", err)
+		}
+	}
+
+	snapshot, err := store.LoadSnapshot(ctx, user.ID)
+	if err != nil || snapshot == nil || snapshot.Version != 3 {
+		t.Fatalf("LoadSnapshot() = %+v, %v, want a snapshot at version 3", snapshot, err)
+	}
+
+	// Loading starts from the snapshot and replays only the events after it,
+	// so a change made to the snapshot shows through
+	doctored, _ := decodeSnapshot(snapshot.State)
+	doctored.Name = "From Snapshot"
+	snapshot.State, _ = encodeSnapshot(doctored)
+	_ = store.SaveSnapshot(ctx, *snapshot)
+
+	_ = user.Update("updated@example.com", "Third")
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+
+	found, err := repo.FindByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("FindByID() unexpected error: %v", err)
+	}
+	if found.Name != "From Snapshot" || found.Email != "updated@example.com" || found.Tenant != "acme" || found.Version != 4 {
+		t.Errorf("FindByID() = %+v, want the snapshot plus the email change at version 4", found)
+	}
+}
+
+func TestUserRepository_RebuildsIndexFromStore(t *testing.T) {
+	ctx := context.Background()
+	repo, store := newTestRepository(t)
+
+	first, _ := entity.NewUser("first@example.com", "First", testPassword)
+	second, _ := entity.NewUser("second@example.com", "Second", testPassword)
+	_ = repo.Create(ctx, first)
+	_ = repo.Create(ctx, second)
+	_ = second.Update("renamed@example.com", "Second")
+	_ = repo.Update(ctx, second)
+
+	restarted, err := NewUserRepository(ctx, store)
+	if err != nil {
+		t.Fatalf("NewUserRepository() unexpected error: %v", err)
+	}
+
+	found, err := restarted.FindByEmail(ctx, "renamed@example.com")
+	if err != nil || found.ID != second.ID || found.Version != 2 {
+		t.Errorf("FindByEmail() = %+v, %v, want user %s at version 2", found, err, second.ID)
+	}
+
+	query, _ := repository.ListQuery{}.Normalize()
+	page, err := restarted.List(ctx, query)
+	if err != nil || len(page.Users) != 2 {
+		t.Fatalf("List() = %+v, %v, want 2 users", page, err)
+	}
+
+	dup, _ := entity.NewUser("first@example.com", "Duplicate", testPassword)
+	if err := restarted.Create(ctx, dup); err != repository.ErrUserAlreadyExists {
+		t.Errorf("Create() with taken email expected ErrUserAlreadyExists, got: %v", err)
+	}
+}
+
+func TestUserRepository_SharesStoreWithOtherRepositories(t *testing.T) {
+	ctx := context.Background()
+	repo, store := newTestRepository(t)
+	other, err := NewUserRepository(ctx, store)
+	if err != nil {
+		t.Fatalf("NewUserRepository() unexpected error: %v", err)
+	}
+
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+
+	// The other repository's index catches up with the new stream
+	dup, _ := entity.NewUser("test@example.com", "Duplicate", testPassword)
+	if err := other.Create(ctx, dup); err != repository.ErrUserAlreadyExists {
+		t.Errorf("Create() of an email taken through another repository expected ErrUserAlreadyExists, got: %v", err)
+	}
+	found, err := other.FindByEmail(ctx, "test@example.com")
+	if err != nil || found.ID != user.ID || found.Password.Hash() != testPassword.Hash() {
+		t.Fatalf("FindByEmail() through another repository = %+v, %v, want user %s with its password", found, err, user.ID)
+	}
+
+	_ = found.Update("test@example.com", "Renamed")
+	if err := other.Update(ctx, found); err != nil {
+		t.Fatalf("Update() through another repository unexpected error: %v", err)
+	}
+	_ = user.Update("test@example.com", "Stale")
+	var conflict *repository.VersionConflictError
+	if err := repo.Update(ctx, user); !errors.As(err, &conflict) || conflict.Actual != 2 {
+		t.Errorf("Update() from a stale copy expected VersionConflictError at version 2, got: %v", err)
+	}
+
+	// A stream deleted through the other repository frees the email
+	if err := other.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+	if err := repo.Create(ctx, dup); err != nil {
+		t.Errorf("Create() after the owner was deleted elsewhere unexpected error: %v", err)
+	}
+}
+
+// unreadableStore stops reading the log after the next append while
+// failReads is set
+type unreadableStore struct {
+	*MemoryStore
+	failReads bool
+	appended  bool
+}
+
+func (s *unreadableStore) Append(ctx context.Context, id entity.UserID, expected int64, events []entity.Event) error {
+	err := s.MemoryStore.Append(ctx, id, expected, events)
+	s.appended = err == nil
+	return err
+}
+
+func (s *unreadableStore) ReadAll(ctx context.Context, after int64, limit int) ([]Record, error) {
+	if s.failReads && s.appended {
+		return nil, errors.New("store unavailable")
+	}
+	return s.MemoryStore.ReadAll(ctx, after, limit)
+}
+
+func TestUserRepository_AppendSucceedsWhenIndexingFails(t *testing.T) {
+	ctx := context.Background()
+	store := &unreadableStore{MemoryStore: NewMemoryStore()}
+	repo, err := NewUserRepository(ctx, store)
+	if err != nil {
+		t.Fatalf("NewUserRepository() unexpected error: %v", err)
+	}
+
+	store.failReads = true
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Create() that was appended but not indexed unexpected error: %v", err)
+	}
+	if found, err := repo.FindByID(ctx, user.ID); err != nil || found.Version != 1 {
+		t.Errorf("FindByID() = %+v, %v, want the stored user", found, err)
+	}
+
+	// The index catches up once the store can be read again
+	store.failReads = false
+	if found, err := repo.FindByEmail(ctx, "test@example.com"); err != nil || found.ID != user.ID {
+		t.Errorf("FindByEmail() = %+v, %v, want user %s", found, err, user.ID)
+	}
+}
+
+func TestUserRepository_FindByIDAsOf(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword, entity.WithClock(fake))
+	_ = repo.Create(ctx, user)
+	fake.Advance(24 * time.Hour)
+	_ = user.Update("updated@example.com", "Test User")
+	_ = repo.Update(ctx, user)
+	fake.Advance(24 * time.Hour)
+	_ = user.Delete("requested", "admin")
+	_ = repo.Update(ctx, user)
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		email   entity.Email
+		version int64
+		deleted bool
+	}{
+		{"at registration", start, "test@example.com", 1, false},
+		{"after the email change", start.Add(36 * time.Hour), "updated@example.com", 2, false},
+		{"after deletion", start.Add(72 * time.Hour), "updated@example.com", 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := repo.FindByIDAsOf(ctx, user.ID, tt.at)
+			if err != nil {
+				t.Fatalf("FindByIDAsOf() unexpected error: %v", err)
+			}
+			if found.Email != tt.email || found.Version != tt.version || found.IsDeleted() != tt.deleted {
+				t.Errorf("FindByIDAsOf() = %+v", found)
+			}
+		})
+	}
+
+	if _, err := repo.FindByIDAsOf(ctx, user.ID, start.Add(-time.Second)); err != repository.ErrUserNotFound {
+		t.Errorf("FindByIDAsOf() before registration expected ErrUserNotFound, got: %v", err)
+	}
+}
+
+func TestUserRepository_SoftDeleteAndPurge(t *testing.T) {
+	ctx := context.Background()
+	repo, store := newTestRepository(t)
+	deletedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword, entity.WithClock(clock.NewFake(deletedAt)))
+	_ = repo.Create(ctx, user)
+	_ = user.Delete("", "admin")
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+
+	if _, err := repo.FindByID(ctx, user.ID); err != repository.ErrUserNotFound {
+		t.Errorf("FindByID() of deleted user expected ErrUserNotFound, got: %v", err)
+	}
+	if _, err := repo.FindDeletedByID(ctx, user.ID); err != nil {
+		t.Errorf("FindDeletedByID() unexpected error: %v", err)
+	}
+
+	if purged, _ := repo.PurgeDeleted(ctx, deletedAt.Add(time.Second)); purged != 1 {
+		t.Errorf("PurgeDeleted() purged %d, want 1", purged)
+	}
+	if _, err := store.Load(ctx, user.ID, 0); err != ErrStreamNotFound {
+		t.Errorf("Load() after purge expected ErrStreamNotFound, got: %v", err)
+	}
+
+	other, _ := entity.NewUser("test@example.com", "Other", testPassword)
+	if err := repo.Create(ctx, other); err != nil {
+		t.Errorf("Create() after purge unexpected error: %v", err)
+	}
+}
+
+func TestUserRepository_Outbox(t *testing.T) {
+	ctx := context.Background()
+	repo, store := newTestRepository(t)
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	_ = repo.Create(ctx, user)
+	_ = user.Activate("verified", "admin")
+	_ = repo.Update(ctx, user)
+
+	pending, err := repo.PendingEvents(ctx, 10)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("PendingEvents() = %v, %v, want 2 events", pending, err)
+	}
+
+	if registered := pending[0].(entity.UserRegistered); registered.PasswordHash != "" {
+		t.Error("PendingEvents() published the password hash")
+	}
+	if records, _ := store.Load(ctx, user.ID, 0); records[0].Event.(entity.UserRegistered).PasswordHash != "" {
+		t.Error("the stream stored the password hash")
+	}
+	if hash, err := store.LoadPasswordHash(ctx, user.ID); err != nil || hash != testPassword.Hash() {
+		t.Errorf("LoadPasswordHash() = %q, %v, want the registered hash", hash, err)
+	}
+
+	if err := repo.MarkEventsPublished(ctx, pending[0].EventID()); err != nil {
+		t.Fatalf("MarkEventsPublished() unexpected error: %v", err)
+	}
+	if left, _ := repo.PendingEvents(ctx, 10); len(left) != 1 || left[0].EventName() != entity.EventUserStatusChanged {
+		t.Errorf("PendingEvents() after publishing = %v", left)
+	}
+
+	restarted, err := NewUserRepository(ctx, store)
+	if err != nil {
+		t.Fatalf("NewUserRepository() unexpected error: %v", err)
+	}
+	if left, _ := restarted.PendingEvents(ctx, 10); len(left) != 1 || left[0].EventID() != pending[1].EventID() {
+		t.Errorf("PendingEvents() after restart = %v, want the unpublished event", left)
+	}
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+)
+
+// AuditRepository is a thread-safe in-memory implementation of audit.Repository
+type AuditRepository struct {
+	mu      sync.RWMutex
+	entries []audit.Entry
+}
+
+// NewAuditRepository creates a new empty in-memory AuditRepository
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+// Append seals entry after the last stored entry and stores a copy of it
+func (r *AuditRepository) Append(ctx context.Context, entry *audit.Entry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var prev *audit.Entry
+	if len(r.entries) > 0 {
+		prev = &r.entries[len(r.entries)-1]
+	}
+	entry.Seal(prev)
+
+	r.entries = append(r.entries, copyEntry(*entry))
+	return nil
+}
+
+// Query returns the entries matching the query in sequence order
+func (r *AuditRepository) Query(ctx context.Context, query audit.Query) ([]audit.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []audit.Entry
+	for _, entry := range r.entries {
+		if len(entries) == query.Limit {
+			break
+		}
+		if query.Matches(entry) {
+			entries = append(entries, copyEntry(entry))
+		}
+	}
+	return entries, nil
+}
+
+// copyEntry returns a copy of entry that shares no mutable state with the original
+func copyEntry(entry audit.Entry) audit.Entry {
+	entry.Changes = append([]audit.FieldChange(nil), entry.Changes...)
+	return entry
+}
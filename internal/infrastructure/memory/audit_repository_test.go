@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+)
+
+func TestAuditRepository_ConcurrentAppend(t *testing.T) {
+	ctx := context.Background()
+	repo := NewAuditRepository()
+	log := audit.NewLog(repo)
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = log.Record(ctx, audit.Entry{Actor: "admin", Action: audit.ActionUpdate, UserID: "user_1"})
+		}()
+	}
+	wg.Wait()
+
+	head, err := log.Verify(ctx)
+	if err != nil || head.Sequence != writers {
+		t.Errorf("Verify() = %v, %v, want %d entries and no error", head, err, writers)
+	}
+}
+
+func TestAuditRepository_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewAuditRepository()
+	entry := audit.Entry{Actor: "admin", Action: audit.ActionUpdate, Changes: []audit.FieldChange{{Field: "name", To: "New"}}}
+	_ = repo.Append(ctx, &entry)
+
+	// Mutating the appended or returned entries must not alter the log
+	entry.Changes[0].To = "Forged"
+	query, _ := audit.Query{}.Normalize()
+	entries, _ := repo.Query(ctx, query)
+	entries[0].Changes[0].From = "Forged"
+
+	if err := audit.NewLog(repo).Record(ctx, audit.Entry{Action: audit.ActionUpdate}); err != nil {
+		t.Fatalf("Record() unexpected error: %v", err)
+	}
+	if _, err := audit.NewLog(repo).Verify(ctx); err != nil {
+		t.Errorf("Verify() unexpected error: %v", err)
+	}
+}
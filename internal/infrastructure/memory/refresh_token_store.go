@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+)
+
+// RefreshTokenStore is a thread-safe in-memory implementation of auth.RefreshTokenStore
+type RefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]auth.RefreshToken
+}
+
+// NewRefreshTokenStore creates a new empty in-memory RefreshTokenStore
+func NewRefreshTokenStore() *RefreshTokenStore {
+	return &RefreshTokenStore{
+		tokens: make(map[string]auth.RefreshToken),
+	}
+}
+
+// Save stores a newly issued token
+func (s *RefreshTokenStore) Save(ctx context.Context, token auth.RefreshToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.Hash] = token
+	return nil
+}
+
+// Use marks the token as used unless it already is and returns it as it was before
+func (s *RefreshTokenStore) Use(ctx context.Context, hash string, at time.Time) (auth.RefreshToken, error) {
+	if err := ctx.Err(); err != nil {
+		return auth.RefreshToken{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[hash]
+	if !ok {
+		return auth.RefreshToken{}, auth.ErrRefreshTokenNotFound
+	}
+
+	if token.UsedAt.IsZero() {
+		used := token
+		used.UsedAt = at
+		s.tokens[hash] = used
+	}
+	return token, nil
+}
+
+// RevokeFamily revokes every token of a family that isn't revoked yet
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.FamilyID == familyID && token.RevokedAt.IsZero() {
+			token.RevokedAt = at
+			s.tokens[hash] = token
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+)
+
+func TestRefreshTokenStore_ConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	store := NewRefreshTokenStore()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_ = store.Save(ctx, auth.RefreshToken{Hash: "hash", UserID: "user_1", FamilyID: "family", IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	const callers = 50
+	var (
+		wg     sync.WaitGroup
+		unused atomic.Int32
+	)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := store.Use(ctx, "hash", now); err == nil && token.UsedAt.IsZero() {
+				unused.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := unused.Load(); got != 1 {
+		t.Errorf("Use() returned the token unused %d times, want 1", got)
+	}
+	if _, err := store.Use(ctx, "missing", now); !errors.Is(err, auth.ErrRefreshTokenNotFound) {
+		t.Errorf("Use() of an unknown token expected ErrRefreshTokenNotFound, got: %v", err)
+	}
+
+	_ = store.RevokeFamily(ctx, "family", now)
+	if token, _ := store.Use(ctx, "hash", now); token.RevokedAt.IsZero() {
+		t.Error("RevokeFamily() left the token usable")
+	}
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// SessionStore is a thread-safe in-memory implementation of auth.SessionStore
+type SessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]auth.Session // by ID
+}
+
+// NewSessionStore creates a new empty in-memory SessionStore
+func NewSessionStore() *SessionStore {
+	return &SessionStore{
+		sessions: make(map[string]auth.Session),
+	}
+}
+
+// Create stores a new session
+func (s *SessionStore) Create(ctx context.Context, session auth.Session) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = session
+	return nil
+}
+
+// FindByTokenHash returns the session with the given token hash
+func (s *SessionStore) FindByTokenHash(ctx context.Context, hash string) (auth.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return auth.Session{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.TokenHash == hash {
+			return session, nil
+		}
+	}
+	return auth.Session{}, auth.ErrSessionNotFound
+}
+
+// Touch records that the session was used at the given time
+func (s *SessionStore) Touch(ctx context.Context, id string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; ok {
+		session.LastSeenAt = at
+		s.sessions[id] = session
+	}
+	return nil
+}
+
+// ListByUser returns the sessions of a user, oldest first
+func (s *SessionStore) ListByUser(ctx context.Context, userID entity.UserID) ([]auth.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sessions []auth.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// Delete removes a session of the given user
+func (s *SessionStore) Delete(ctx context.Context, userID entity.UserID, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || session.UserID != userID {
+		return auth.ErrSessionNotFound
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+// DeleteByUser removes every session of a user
+func (s *SessionStore) DeleteByUser(ctx context.Context, userID entity.UserID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+)
+
+func TestSessionStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewSessionStore()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sessions := []auth.Session{
+		{ID: "second", TokenHash: "hash_second", UserID: "user_1", CreatedAt: now.Add(time.Minute)},
+		{ID: "first", TokenHash: "hash_first", UserID: "user_1", CreatedAt: now},
+		{ID: "other", TokenHash: "hash_other", UserID: "user_2", CreatedAt: now},
+	}
+	for _, session := range sessions {
+		_ = store.Create(ctx, session)
+	}
+
+	if session, err := store.FindByTokenHash(ctx, "hash_first"); err != nil || session.ID != "first" {
+		t.Errorf("FindByTokenHash() = %+v, %v, want the first session", session, err)
+	}
+	if _, err := store.FindByTokenHash(ctx, "missing"); !errors.Is(err, auth.ErrSessionNotFound) {
+		t.Errorf("FindByTokenHash() of an unknown hash expected ErrSessionNotFound, got: %v", err)
+	}
+
+	sessions, _ = store.ListByUser(ctx, "user_1")
+	if len(sessions) != 2 || sessions[0].ID != "first" || sessions[1].ID != "second" {
+		t.Errorf("ListByUser() = %+v, want user_1's sessions oldest first", sessions)
+	}
+
+	if err := store.Delete(ctx, "user_1", "other"); !errors.Is(err, auth.ErrSessionNotFound) {
+		t.Errorf("Delete() of another user's session expected ErrSessionNotFound, got: %v", err)
+	}
+	_ = store.DeleteByUser(ctx, "user_1")
+	if sessions, _ := store.ListByUser(ctx, "user_1"); len(sessions) != 0 {
+		t.Errorf("DeleteByUser() left %+v", sessions)
+	}
+	if sessions, _ := store.ListByUser(ctx, "user_2"); len(sessions) != 1 {
+		t.Errorf("DeleteByUser() removed another user's sessions")
+	}
+}
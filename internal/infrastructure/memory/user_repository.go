@@ -0,0 +1,256 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+// UserRepository is a thread-safe in-memory implementation of repository.UserRepository.
+// Users are stored and returned as copies, so callers can never mutate stored state
+// without going through the repository. Operations fail fast with ctx.Err() when
+// the context is already done. It is also the repository.Outbox for the events
+// of the users it stores.
+type UserRepository struct {
+	mu      sync.RWMutex
+	users   map[entity.UserID]*entity.User
+	byEmail map[entity.Email]entity.UserID
+	outbox  []entity.Event
+}
+
+// NewUserRepository creates a new empty in-memory UserRepository
+func NewUserRepository() *UserRepository {
+	return &UserRepository{
+		users:   make(map[entity.UserID]*entity.User),
+		byEmail: make(map[entity.Email]entity.UserID),
+	}
+}
+
+// Create stores a new user.
+// It fails with repository.ErrUserAlreadyExists if the ID or the email is taken.
+func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
+	if user == nil {
+		return repository.ErrInvalidUser
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[user.ID]; exists {
+		return repository.ErrUserAlreadyExists
+	}
+
+	if err := r.checkEmailAvailable(user); err != nil {
+		return err
+	}
+
+	r.store(user)
+	return nil
+}
+
+// FindByID retrieves a user by their ID
+func (r *UserRepository) FindByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, exists := r.users[id]
+	if !exists || user.IsDeleted() {
+		return nil, repository.ErrUserNotFound
+	}
+	return user.Clone(), nil
+}
+
+// FindDeletedByID retrieves a soft-deleted user by their ID
+func (r *UserRepository) FindDeletedByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, exists := r.users[id]
+	if !exists || !user.IsDeleted() {
+		return nil, repository.ErrUserNotFound
+	}
+	return user.Clone(), nil
+}
+
+// FindByEmail retrieves a user by their canonical email
+func (r *UserRepository) FindByEmail(ctx context.Context, email entity.Email) (*entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, exists := r.byEmail[email]
+	if !exists || r.users[id].IsDeleted() {
+		return nil, repository.ErrUserNotFound
+	}
+	return r.users[id].Clone(), nil
+}
+
+// Update replaces an existing user whose stored version matches user.Version.
+// It fails with repository.ErrUserAlreadyExists if the new email belongs to another user.
+func (r *UserRepository) Update(ctx context.Context, user *entity.User) error {
+	if user == nil {
+		return repository.ErrInvalidUser
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.users[user.ID]
+	if !exists {
+		return repository.ErrUserNotFound
+	}
+
+	if stored.Version != user.Version {
+		return &repository.VersionConflictError{ID: user.ID, Expected: user.Version, Actual: stored.Version}
+	}
+
+	if err := r.checkEmailAvailable(user); err != nil {
+		return err
+	}
+
+	user.Version++
+	r.store(user)
+	return nil
+}
+
+// Delete permanently removes a user by their ID
+func (r *UserRepository) Delete(ctx context.Context, id entity.UserID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return repository.ErrUserNotFound
+	}
+
+	delete(r.byEmail, user.CanonicalEmail)
+	delete(r.users, id)
+	return nil
+}
+
+// PurgeDeleted permanently removes users soft-deleted before cutoff
+func (r *UserRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, user := range r.users {
+		if user.IsDeleted() && user.DeletedAt.Before(cutoff) {
+			delete(r.byEmail, user.CanonicalEmail)
+			delete(r.users, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// List returns one page of users matching the query
+func (r *UserRepository) List(ctx context.Context, query repository.ListQuery) (*repository.UserPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	users := make([]*entity.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	page, err := query.Paginate(users)
+	if err == nil {
+		for i, user := range page.Users {
+			page.Users[i] = user.Clone()
+		}
+	}
+	r.mu.RUnlock()
+
+	return page, err
+}
+
+// PendingEvents returns up to limit unpublished events, oldest first
+func (r *UserRepository) PendingEvents(ctx context.Context, limit int) ([]entity.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if limit > len(r.outbox) {
+		limit = len(r.outbox)
+	}
+	return append([]entity.Event(nil), r.outbox[:limit]...), nil
+}
+
+// MarkEventsPublished removes the events with the given IDs from the outbox
+func (r *UserRepository) MarkEventsPublished(ctx context.Context, ids ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	published := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		published[id] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := r.outbox[:0]
+	for _, event := range r.outbox {
+		if !published[event.EventID()] {
+			pending = append(pending, event)
+		}
+	}
+	clear(r.outbox[len(pending):])
+	r.outbox = pending
+	return nil
+}
+
+// checkEmailAvailable reports whether user's email is free or already owned by user.
+// Callers must hold the write lock.
+func (r *UserRepository) checkEmailAvailable(user *entity.User) error {
+	if ownerID, taken := r.byEmail[user.CanonicalEmail]; taken && ownerID != user.ID {
+		return repository.ErrUserAlreadyExists
+	}
+	return nil
+}
+
+// store writes a copy of user, keeps the email index in sync and moves the
+// user's pending events to the outbox. Callers must hold the write lock.
+func (r *UserRepository) store(user *entity.User) {
+	if previous, exists := r.users[user.ID]; exists && previous.CanonicalEmail != user.CanonicalEmail {
+		delete(r.byEmail, previous.CanonicalEmail)
+	}
+
+	r.users[user.ID] = user.Clone()
+	r.byEmail[user.CanonicalEmail] = user.ID
+	r.outbox = append(r.outbox, entity.WithoutSecrets(user.PullEvents())...)
+}
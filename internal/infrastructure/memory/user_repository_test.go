@@ -0,0 +1,372 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/clock"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+// testPassword is a cheaply hashed password shared by tests
+var testPassword, _ = entity.PasswordHasher{Iterations: 1000, SaltLength: 16, KeyLength: 32}.Hash("Secret-passw0rd")
+
+func TestUserRepository_CreateAndFind(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+
+	byID, err := repo.FindByID(ctx, user.ID)
+	if err != nil {
+		t.Errorf("FindByID() unexpected error: %v", err)
+	}
+	if byID.Email != user.Email {
+		t.Errorf("FindByID() email mismatch, got: %s, want: %s", byID.Email, user.Email)
+	}
+
+	byEmail, err := repo.FindByEmail(ctx, user.CanonicalEmail)
+	if err != nil {
+		t.Errorf("FindByEmail() unexpected error: %v", err)
+	}
+	if byEmail.ID != user.ID {
+		t.Errorf("FindByEmail() ID mismatch, got: %s, want: %s", byEmail.ID, user.ID)
+	}
+}
+
+func TestUserRepository_CreateNilUser(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+
+	if err := repo.Create(ctx, nil); err != repository.ErrInvalidUser {
+		t.Errorf("Create() expected ErrInvalidUser, got: %v", err)
+	}
+}
+
+func TestUserRepository_CreateDuplicateEmail(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	first, _ := entity.NewUser("test@example.com", "First", testPassword)
+	second, _ := entity.NewUser("test@example.com", "Second", testPassword)
+	second.ID = "other-id"
+
+	if err := repo.Create(ctx, first); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+
+	if err := repo.Create(ctx, second); err != repository.ErrUserAlreadyExists {
+		t.Errorf("Create() expected ErrUserAlreadyExists, got: %v", err)
+	}
+}
+
+func TestUserRepository_CreateDuplicateID(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	first, _ := entity.NewUser("first@example.com", "First", testPassword)
+	second, _ := entity.NewUser("second@example.com", "Second", testPassword)
+	second.ID = first.ID
+
+	if err := repo.Create(ctx, first); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+
+	if err := repo.Create(ctx, second); err != repository.ErrUserAlreadyExists {
+		t.Errorf("Create() expected ErrUserAlreadyExists, got: %v", err)
+	}
+
+	found, _ := repo.FindByID(ctx, first.ID)
+	if found.Name != "First" {
+		t.Errorf("Create() overwrote the existing user, got name: %s", found.Name)
+	}
+}
+
+func TestUserRepository_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	_ = repo.Create(ctx, user)
+
+	// Mutating the saved instance must not leak into the store
+	user.Name = "Mutated"
+
+	found, _ := repo.FindByID(ctx, user.ID)
+	if found.Name != "Test User" {
+		t.Errorf("Create() stored a shared reference, got name: %s", found.Name)
+	}
+
+	// Mutating a returned instance must not leak into the store either
+	found.Name = "Mutated again"
+
+	again, _ := repo.FindByID(ctx, user.ID)
+	if again.Name != "Test User" {
+		t.Errorf("FindByID() returned a shared reference, got name: %s", again.Name)
+	}
+}
+
+func TestUserRepository_UpdateStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	_ = repo.Create(ctx, user)
+
+	first, _ := repo.FindByID(ctx, user.ID)
+	second, _ := repo.FindByID(ctx, user.ID)
+
+	first.Name = "First Admin"
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if first.Version != user.Version+1 {
+		t.Errorf("Update() Version = %d, want %d", first.Version, user.Version+1)
+	}
+
+	second.Name = "Second Admin"
+	err := repo.Update(ctx, second)
+
+	var conflict *repository.VersionConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("Update() expected VersionConflictError, got: %v", err)
+	}
+	if conflict.Expected != user.Version || conflict.Actual != first.Version {
+		t.Errorf("Update() conflict = %+v", conflict)
+	}
+
+	found, _ := repo.FindByID(ctx, user.ID)
+	if found.Name != "First Admin" {
+		t.Errorf("Update() stale write applied, got name: %s", found.Name)
+	}
+}
+
+func TestUserRepository_UpdateReindexesEmail(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	_ = repo.Create(ctx, user)
+
+	_ = user.Update("updated@example.com", "Test User")
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+
+	if _, err := repo.FindByEmail(ctx, "test@example.com"); err != repository.ErrUserNotFound {
+		t.Errorf("FindByEmail() old email expected ErrUserNotFound, got: %v", err)
+	}
+
+	if _, err := repo.FindByEmail(ctx, "updated@example.com"); err != nil {
+		t.Errorf("FindByEmail() new email unexpected error: %v", err)
+	}
+}
+
+func TestUserRepository_UpdateToTakenEmail(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	first, _ := entity.NewUser("first@example.com", "First", testPassword)
+	second, _ := entity.NewUser("second@example.com", "Second", testPassword)
+	second.ID = "other-id"
+	_ = repo.Create(ctx, first)
+	_ = repo.Create(ctx, second)
+
+	_ = second.Update("first@example.com", "Second")
+	if err := repo.Update(ctx, second); err != repository.ErrUserAlreadyExists {
+		t.Errorf("Update() expected ErrUserAlreadyExists, got: %v", err)
+	}
+}
+
+func TestUserRepository_UpdateNotFound(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+
+	if err := repo.Update(ctx, user); err != repository.ErrUserNotFound {
+		t.Errorf("Update() expected ErrUserNotFound, got: %v", err)
+	}
+}
+
+func TestUserRepository_Delete(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	_ = repo.Create(ctx, user)
+
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Delete() unexpected error: %v", err)
+	}
+
+	if _, err := repo.FindByEmail(ctx, user.CanonicalEmail); err != repository.ErrUserNotFound {
+		t.Errorf("FindByEmail() after delete expected ErrUserNotFound, got: %v", err)
+	}
+
+	if err := repo.Delete(ctx, user.ID); err != repository.ErrUserNotFound {
+		t.Errorf("Delete() expected ErrUserNotFound, got: %v", err)
+	}
+}
+
+func TestUserRepository_SoftDeleteAndPurge(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	deletedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword, entity.WithClock(clock.NewFake(deletedAt)))
+	_ = repo.Create(ctx, user)
+	_ = user.Delete("", "admin")
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+
+	if _, err := repo.FindByID(ctx, user.ID); err != repository.ErrUserNotFound {
+		t.Errorf("FindByID() of deleted user expected ErrUserNotFound, got: %v", err)
+	}
+	if _, err := repo.FindByEmail(ctx, user.CanonicalEmail); err != repository.ErrUserNotFound {
+		t.Errorf("FindByEmail() of deleted user expected ErrUserNotFound, got: %v", err)
+	}
+	if _, err := repo.FindDeletedByID(ctx, user.ID); err != nil {
+		t.Errorf("FindDeletedByID() unexpected error: %v", err)
+	}
+
+	// The email stays reserved while the user can still be restored
+	other, _ := entity.NewUser("test@example.com", "Other", testPassword)
+	if err := repo.Create(ctx, other); err != repository.ErrUserAlreadyExists {
+		t.Errorf("Create() with email of deleted user expected ErrUserAlreadyExists, got: %v", err)
+	}
+
+	if purged, _ := repo.PurgeDeleted(ctx, deletedAt); purged != 0 {
+		t.Errorf("PurgeDeleted() at deletion time purged %d, want 0", purged)
+	}
+	if purged, _ := repo.PurgeDeleted(ctx, deletedAt.Add(time.Second)); purged != 1 {
+		t.Errorf("PurgeDeleted() after deletion purged %d, want 1", purged)
+	}
+	if _, err := repo.FindDeletedByID(ctx, user.ID); err != repository.ErrUserNotFound {
+		t.Errorf("FindDeletedByID() after purge expected ErrUserNotFound, got: %v", err)
+	}
+	if err := repo.Create(ctx, other); err != nil {
+		t.Errorf("Create() after purge unexpected error: %v", err)
+	}
+}
+
+func TestUserRepository_Outbox(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	if len(user.Events()) != 0 {
+		t.Errorf("Create() left %d pending events on the user", len(user.Events()))
+	}
+
+	// A failed write adds nothing to the outbox and keeps the user's events
+	stale := *user
+	_ = stale.Update("test@example.com", "Stale")
+	stale.Version = 0
+	if err := repo.Update(ctx, &stale); err == nil {
+		t.Fatalf("Update() expected a version conflict")
+	}
+	if len(stale.Events()) != 1 {
+		t.Errorf("failed Update() cleared the user's events")
+	}
+
+	_ = user.Update("test@example.com", "Renamed")
+	_ = repo.Update(ctx, user)
+
+	events, err := repo.PendingEvents(ctx, 10)
+	if err != nil {
+		t.Fatalf("PendingEvents() unexpected error: %v", err)
+	}
+	if len(events) != 2 || events[0].EventName() != entity.EventUserRegistered || events[1].EventName() != entity.EventUserRenamed {
+		t.Fatalf("PendingEvents() = %d events", len(events))
+	}
+
+	if payload, _ := json.Marshal(events[0]); strings.Contains(string(payload), testPassword.Hash()) {
+		t.Errorf("PendingEvents() published the password hash: %s", payload)
+	}
+
+	if first, _ := repo.PendingEvents(ctx, 1); len(first) != 1 || first[0].EventID() != events[0].EventID() {
+		t.Errorf("PendingEvents(1) did not return the oldest event")
+	}
+
+	if err := repo.MarkEventsPublished(ctx, events[0].EventID(), "unknown"); err != nil {
+		t.Fatalf("MarkEventsPublished() unexpected error: %v", err)
+	}
+	if pending, _ := repo.PendingEvents(ctx, 10); len(pending) != 1 || pending[0].EventID() != events[1].EventID() {
+		t.Errorf("MarkEventsPublished() left %d events", len(pending))
+	}
+}
+
+func TestUserRepository_ConcurrentCreateSameEmail(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+
+	const workers = 100
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			user, _ := entity.NewUser("race@example.com", "Racer", testPassword)
+			user.ID = entity.UserID(fmt.Sprintf("user_%d", i))
+
+			if err := repo.Create(ctx, user); err == nil {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("Create() expected exactly one success, got: %d", successes)
+	}
+}
+
+func TestUserRepository_List(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+
+	for i := 0; i < 5; i++ {
+		user, _ := entity.NewUser(fmt.Sprintf("user%d@example.com", i), "User", testPassword)
+		user.ID = entity.UserID(fmt.Sprintf("user_%d", i))
+		_ = repo.Create(ctx, user)
+	}
+
+	query, _ := repository.ListQuery{Limit: 3}.Normalize()
+	first, err := repo.List(ctx, query)
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if len(first.Users) != 3 || first.NextCursor == "" {
+		t.Fatalf("List() first page has %d users, cursor %q", len(first.Users), first.NextCursor)
+	}
+
+	// Returned users must be copies
+	first.Users[0].Name = "Mutated"
+	if stored, _ := repo.FindByID(ctx, first.Users[0].ID); stored.Name != "User" {
+		t.Errorf("List() returned a shared reference")
+	}
+
+	query.Cursor = first.NextCursor
+	second, err := repo.List(ctx, query)
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if len(second.Users) != 2 || second.NextCursor != "" {
+		t.Errorf("List() second page has %d users, cursor %q", len(second.Users), second.NextCursor)
+	}
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+)
+
+// AuditRepository is a PostgreSQL implementation of audit.Repository
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new AuditRepository backed by db
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{
+		db: db,
+	}
+}
+
+// Append seals entry after the last stored entry and inserts it. The table
+// is locked against other appends for the duration of the transaction, so
+// the chain never forks; readers are not blocked.
+func (r *AuditRepository) Append(ctx context.Context, entry *audit.Entry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE audit_log IN EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+
+	var prev *audit.Entry
+	var last audit.Entry
+	err = tx.QueryRowContext(ctx, `SELECT sequence, hash FROM audit_log ORDER BY sequence DESC LIMIT 1`).
+		Scan(&last.Sequence, &last.Hash)
+	switch {
+	case err == nil:
+		prev = &last
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	sealed := *entry
+	sealed.Seal(prev)
+
+	changes, err := json.Marshal(nonNilChanges(sealed.Changes))
+	if err != nil {
+		return fmt.Errorf("failed to encode audit changes: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (sequence, at, actor, action, user_id, changes, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		sealed.Sequence, sealed.At, sealed.Actor, sealed.Action, sealed.UserID, changes, sealed.PrevHash, sealed.Hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	*entry = sealed
+	return nil
+}
+
+// Query returns the entries matching the query in sequence order
+func (r *AuditRepository) Query(ctx context.Context, query audit.Query) ([]audit.Entry, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	addArg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions = append(conditions, "sequence > "+addArg(query.AfterSequence))
+	if query.UserID != "" {
+		conditions = append(conditions, "user_id = "+addArg(query.UserID))
+	}
+	if !query.From.IsZero() {
+		conditions = append(conditions, "at >= "+addArg(query.From))
+	}
+	if !query.To.IsZero() {
+		conditions = append(conditions, "at < "+addArg(query.To))
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT sequence, at, actor, action, user_id, changes, prev_hash, hash
+		FROM audit_log
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY sequence
+		LIMIT `+addArg(query.Limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []audit.Entry
+	for rows.Next() {
+		var (
+			entry   audit.Entry
+			changes []byte
+		)
+		err := rows.Scan(&entry.Sequence, &entry.At, &entry.Actor, &entry.Action, &entry.UserID,
+			&changes, &entry.PrevHash, &entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, fmt.Errorf("audit entry %d: failed to decode changes: %w", entry.Sequence, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// nonNilChanges makes an entry without changes store an empty JSON array
+func nonNilChanges(changes []audit.FieldChange) []audit.FieldChange {
+	if changes == nil {
+		return []audit.FieldChange{}
+	}
+	return changes
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+)
+
+func TestAuditRepository_AppendQueryVerify(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	log := audit.NewLog(NewAuditRepository(db))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := audit.Entry{
+				At:      start.Add(time.Duration(i) * time.Hour),
+				Actor:   "admin",
+				Action:  audit.ActionUpdate,
+				UserID:  "user_1",
+				Changes: []audit.FieldChange{{Field: "name", From: "Old", To: "New"}},
+			}
+			if i%2 == 1 {
+				entry.UserID = "user_2"
+			}
+			if err := log.Record(ctx, entry); err != nil {
+				t.Errorf("Record() unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	head, err := log.Verify(ctx)
+	if err != nil || head.Sequence != writers {
+		t.Fatalf("Verify() = %v, %v, want %d entries and no error", head, err, writers)
+	}
+
+	byUser, err := log.Query(ctx, audit.Query{UserID: "user_2"})
+	if err != nil {
+		t.Fatalf("Query() unexpected error: %v", err)
+	}
+	if len(byUser) != writers/2 {
+		t.Errorf("Query(user_2) returned %d entries, want %d", len(byUser), writers/2)
+	}
+
+	inRange, _ := log.Query(ctx, audit.Query{From: start, To: start.Add(3 * time.Hour)})
+	if len(inRange) != 3 {
+		t.Errorf("Query(time range) returned %d entries, want 3", len(inRange))
+	}
+
+	// Stored entries can't be changed, even directly
+	if _, err := db.Exec(`UPDATE audit_log SET actor = 'someone' WHERE sequence = 1`); err == nil {
+		t.Errorf("UPDATE on audit_log expected to fail")
+	}
+	if _, err := db.Exec(`DELETE FROM audit_log WHERE sequence = 1`); err == nil {
+		t.Errorf("DELETE on audit_log expected to fail")
+	}
+
+	// Tampering that bypasses the trigger is caught by Verify
+	if _, err := db.Exec(`ALTER TABLE audit_log DISABLE TRIGGER audit_log_append_only`); err != nil {
+		t.Fatalf("failed to disable trigger: %v", err)
+	}
+	_, _ = db.Exec(`UPDATE audit_log SET actor = 'someone' WHERE sequence = 5`)
+	_, _ = db.Exec(`ALTER TABLE audit_log ENABLE TRIGGER audit_log_append_only`)
+
+	var chainErr *audit.ChainError
+	if _, err := log.Verify(ctx); !errors.As(err, &chainErr) || chainErr.Sequence != 5 {
+		t.Errorf("Verify() after tampering expected ChainError at 5, got: %v", err)
+	}
+}
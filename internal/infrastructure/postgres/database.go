@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	// Register the "postgres" driver with database/sql
+	_ "github.com/lib/pq"
+)
+
+// connectTimeout bounds the initial connectivity check in Open
+const connectTimeout = 5 * time.Second
+
+// DSNFromEnv returns the connection string from DATABASE_URL.
+// If it is not set, the DSN is assembled from the POSTGRES_* variables
+// used by docker-compose. An empty string means no database is configured.
+func DSNFromEnv() string {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn
+	}
+
+	host := os.Getenv("POSTGRES_HOST")
+	if host == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		getenv("POSTGRES_USER", "postgres"),
+		getenv("POSTGRES_PASSWORD", "postgres"),
+		host,
+		getenv("POSTGRES_PORT", "5432"),
+		getenv("POSTGRES_DB", "helloworld"),
+	)
+}
+
+// Open opens a connection pool and verifies that the database is reachable
+func Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(25)
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return db, nil
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/eventbus"
+)
+
+// DeadLetterStore is a PostgreSQL implementation of eventbus.DeadLetterStore
+type DeadLetterStore struct {
+	db *sql.DB
+}
+
+// NewDeadLetterStore creates a new DeadLetterStore backed by db
+func NewDeadLetterStore(db *sql.DB) *DeadLetterStore {
+	return &DeadLetterStore{
+		db: db,
+	}
+}
+
+// Add stores a dead letter
+func (s *DeadLetterStore) Add(ctx context.Context, letter eventbus.DeadLetter) error {
+	payload, err := json.Marshal(letter.Event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", letter.Event.EventName(), err)
+	}
+
+	var message string
+	if letter.Err != nil {
+		message = letter.Err.Error()
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO dead_letters (subscriber, event_id, event_name, payload, attempts, error, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		letter.Subscriber, letter.Event.EventID(), letter.Event.EventName(), payload,
+		letter.Attempts, message, letter.At,
+	)
+	return err
+}
+
+// List returns the stored dead letters, oldest first. Their errors keep only
+// the message.
+func (s *DeadLetterStore) List(ctx context.Context) ([]eventbus.DeadLetter, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT subscriber, event_name, payload, attempts, error, failed_at
+		FROM dead_letters
+		ORDER BY failed_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []eventbus.DeadLetter
+	for rows.Next() {
+		var (
+			letter  eventbus.DeadLetter
+			name    string
+			payload []byte
+			message string
+		)
+		if err := rows.Scan(&letter.Subscriber, &name, &payload, &letter.Attempts, &message, &letter.At); err != nil {
+			return nil, err
+		}
+
+		letter.Event, err = entity.UnmarshalEvent(name, payload)
+		if err != nil {
+			return nil, err
+		}
+		letter.Err = errors.New(message)
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/eventbus"
+)
+
+func TestDeadLetterStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewDeadLetterStore(openTestDB(t))
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	event := user.PullEvents()[0]
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	letter := eventbus.DeadLetter{Event: event, Subscriber: "mailer", Attempts: 3, Err: errors.New("smtp down"), At: at}
+	if err := store.Add(ctx, letter); err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+
+	letters, err := store.List(ctx)
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("List() = %+v, %v, want 1 letter", letters, err)
+	}
+	got := letters[0]
+	if got.Event.EventID() != event.EventID() || got.Subscriber != "mailer" || got.Attempts != 3 || !got.At.Equal(at) {
+		t.Errorf("List() = %+v, want %+v", got, letter)
+	}
+	if got.Err == nil || got.Err.Error() != "smtp down" {
+		t.Errorf("List() error = %v, want smtp down", got.Err)
+	}
+}
@@ -0,0 +1,288 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/eventstore"
+)
+
+// foreignKeyViolation is the PostgreSQL error code for foreign key violations
+const foreignKeyViolation = "23503"
+
+// appendLockID is the advisory lock key that serializes appends, so that
+// events become visible in position order
+const appendLockID = 72707370
+
+// EventStore is a PostgreSQL implementation of eventstore.Store. Its outbox
+// is the user_outbox table that UserRepository also uses.
+type EventStore struct {
+	db *sql.DB
+}
+
+// NewEventStore creates a new EventStore backed by db
+func NewEventStore(db *sql.DB) *EventStore {
+	return &EventStore{
+		db: db,
+	}
+}
+
+// Append adds events to the user's stream if it is at version expected. The
+// stream's row in user_streams is created or moved to the next version in
+// the same transaction as the events are inserted, so of two concurrent
+// appends at the same version only one succeeds. The events are written to
+// the outbox in that transaction too, and the password hash they set, if
+// any, to user_passwords rather than with them.
+//
+// Appends hold a lock from assigning positions until they commit, so a
+// record is never committed behind one a reader has already seen.
+func (s *EventStore) Append(ctx context.Context, id entity.UserID, expected int64, events []entity.Event) error {
+	if len(events) == 0 {
+		actual, err := s.streamVersion(ctx, id)
+		if err != nil {
+			return err
+		}
+		if actual != expected {
+			return &eventstore.ConflictError{UserID: id, Expected: expected, Actual: actual}
+		}
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	if expected == 0 {
+		result, err = tx.ExecContext(ctx, `
+			INSERT INTO user_streams (user_id, version)
+			VALUES ($1, 1)
+			ON CONFLICT (user_id) DO NOTHING`, id)
+	} else {
+		result, err = tx.ExecContext(ctx, `
+			UPDATE user_streams
+			SET version = version + 1
+			WHERE user_id = $1 AND version = $2`, id, expected)
+	}
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	} else if affected == 0 {
+		actual, err := s.streamVersion(ctx, id)
+		if err != nil {
+			return err
+		}
+		return &eventstore.ConflictError{UserID: id, Expected: expected, Actual: actual}
+	}
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, appendLockID); err != nil {
+		return fmt.Errorf("failed to acquire append lock: %w", err)
+	}
+
+	for _, event := range entity.WithoutSecrets(events) {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", event.EventName(), err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO user_events (id, user_id, version, event_name, payload, occurred_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			event.EventID(), id, expected+1, event.EventName(), payload, event.OccurredAt(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store %s event: %w", event.EventName(), err)
+		}
+	}
+	if err := insertEvents(ctx, tx, events); err != nil {
+		return err
+	}
+
+	if hash, ok := entity.PasswordHashIn(events); ok {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_passwords (user_id, password_hash)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET password_hash = EXCLUDED.password_hash`, id, hash)
+		if err != nil {
+			return fmt.Errorf("failed to store password hash: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadPasswordHash returns the user's current password hash
+func (s *EventStore) LoadPasswordHash(ctx context.Context, id entity.UserID) (string, error) {
+	var hash string
+	err := s.db.QueryRowContext(ctx, `SELECT password_hash FROM user_passwords WHERE user_id = $1`, id).Scan(&hash)
+	if err == nil {
+		return hash, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	// No hash may also mean there is no stream at all
+	version, err := s.streamVersion(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if version == 0 {
+		return "", eventstore.ErrStreamNotFound
+	}
+	return "", nil
+}
+
+// Load returns the user's records with a version above after
+func (s *EventStore) Load(ctx context.Context, id entity.UserID, after int64) ([]eventstore.Record, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT position, version, event_name, payload
+		FROM user_events
+		WHERE user_id = $1 AND version > $2
+		ORDER BY position`, id, after)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := scanRecords(rows)
+	if err != nil || len(records) > 0 {
+		return records, err
+	}
+
+	// No records may also mean there is no stream at all
+	version, err := s.streamVersion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		return nil, eventstore.ErrStreamNotFound
+	}
+	return nil, nil
+}
+
+// ReadAll returns up to limit records with a position above after.
+// Appends commit in position order, so reading on from the last position
+// seen never skips a record.
+func (s *EventStore) ReadAll(ctx context.Context, after int64, limit int) ([]eventstore.Record, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT position, version, event_name, payload
+		FROM user_events
+		WHERE position > $1
+		ORDER BY position
+		LIMIT $2`, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRecords(rows)
+}
+
+// PendingEvents returns up to limit unpublished events, oldest first
+func (s *EventStore) PendingEvents(ctx context.Context, limit int) ([]entity.Event, error) {
+	return pendingEvents(ctx, s.db, limit)
+}
+
+// MarkEventsPublished deletes the events with the given IDs from the outbox
+func (s *EventStore) MarkEventsPublished(ctx context.Context, ids ...string) error {
+	return markEventsPublished(ctx, s.db, ids)
+}
+
+// DeleteStream removes the user's stream; its events, snapshot and password
+// hash go with it
+func (s *EventStore) DeleteStream(ctx context.Context, id entity.UserID) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM user_streams WHERE user_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return eventstore.ErrStreamNotFound
+	}
+	return nil
+}
+
+// SaveSnapshot stores snapshot, replacing the user's previous one
+func (s *EventStore) SaveSnapshot(ctx context.Context, snapshot eventstore.Snapshot) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_snapshots (user_id, version, state)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET version = EXCLUDED.version, state = EXCLUDED.state`,
+		snapshot.UserID, snapshot.Version, snapshot.State,
+	)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return eventstore.ErrStreamNotFound
+	}
+	return err
+}
+
+// LoadSnapshot returns the user's latest snapshot, or nil if there is none
+func (s *EventStore) LoadSnapshot(ctx context.Context, id entity.UserID) (*eventstore.Snapshot, error) {
+	snapshot := eventstore.Snapshot{UserID: id}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT version, state
+		FROM user_snapshots
+		WHERE user_id = $1`, id).Scan(&snapshot.Version, &snapshot.State)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// streamVersion returns the version of the user's stream, 0 if it has none
+func (s *EventStore) streamVersion(ctx context.Context, id entity.UserID) (int64, error) {
+	var version int64
+	err := s.db.QueryRowContext(ctx, `SELECT version FROM user_streams WHERE user_id = $1`, id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// scanRecords reads and closes rows of position, version, event_name and payload
+func scanRecords(rows *sql.Rows) ([]eventstore.Record, error) {
+	defer rows.Close()
+
+	var records []eventstore.Record
+	for rows.Next() {
+		var (
+			record  eventstore.Record
+			name    string
+			payload []byte
+		)
+		if err := rows.Scan(&record.Position, &record.Version, &name, &payload); err != nil {
+			return nil, err
+		}
+
+		event, err := entity.UnmarshalEvent(name, payload)
+		if err != nil {
+			return nil, err
+		}
+		record.Event = event
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
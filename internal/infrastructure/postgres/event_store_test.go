@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/eventstore"
+)
+
+func TestEventStore_AppendLoadAndSnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := NewEventStore(openTestDB(t))
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+
+	if err := store.Append(ctx, user.ID, 0, user.PullEvents()); err != nil {
+		t.Fatalf("Append() unexpected error: %v", err)
+	}
+
+	_ = user.Activate("verified", "admin")
+	var conflict *eventstore.ConflictError
+	if err := store.Append(ctx, user.ID, 0, user.Events()); !errors.As(err, &conflict) || conflict.Actual != 1 {
+		t.Fatalf("Append() at a stale version expected ConflictError, got: %v", err)
+	}
+	if err := store.Append(ctx, user.ID, 1, user.PullEvents()); err != nil {
+		t.Fatalf("Append() unexpected error: %v", err)
+	}
+
+	records, err := store.Load(ctx, user.ID, 0)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Load() = %v, %v, want 2 records", records, err)
+	}
+	if registered, ok := records[0].Event.(entity.UserRegistered); !ok || registered.PasswordHash != "" || records[1].Version != 2 {
+		t.Errorf("Load() = %+v, want the records without the password hash", records)
+	}
+	if hash, err := store.LoadPasswordHash(ctx, user.ID); err != nil || hash != testPassword.Hash() {
+		t.Errorf("LoadPasswordHash() = %q, %v, want the registered hash", hash, err)
+	}
+
+	all, err := store.ReadAll(ctx, records[0].Position, 10)
+	if err != nil || len(all) != 1 || all[0].Event.EventID() != records[1].Event.EventID() {
+		t.Errorf("ReadAll() = %+v, %v, want the second record", all, err)
+	}
+
+	if err := store.SaveSnapshot(ctx, eventstore.Snapshot{UserID: user.ID, Version: 2, State: []byte(`{}`)}); err != nil {
+		t.Fatalf("SaveSnapshot() unexpected error: %v", err)
+	}
+	if snapshot, err := store.LoadSnapshot(ctx, user.ID); err != nil || snapshot == nil || snapshot.Version != 2 {
+		t.Errorf("LoadSnapshot() = %+v, %v", snapshot, err)
+	}
+
+	if err := store.DeleteStream(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteStream() unexpected error: %v", err)
+	}
+	if _, err := store.Load(ctx, user.ID, 0); err != eventstore.ErrStreamNotFound {
+		t.Errorf("Load() after delete expected ErrStreamNotFound, got: %v", err)
+	}
+	if snapshot, _ := store.LoadSnapshot(ctx, user.ID); snapshot != nil {
+		t.Errorf("LoadSnapshot() after delete = %+v, want nil", snapshot)
+	}
+	if _, err := store.LoadPasswordHash(ctx, user.ID); err != eventstore.ErrStreamNotFound {
+		t.Errorf("LoadPasswordHash() after delete expected ErrStreamNotFound, got: %v", err)
+	}
+}
+
+func TestEventStore_BacksUserRepository(t *testing.T) {
+	ctx := context.Background()
+	store := NewEventStore(openTestDB(t))
+	repo, err := eventstore.NewUserRepository(ctx, store, eventstore.WithSnapshotInterval(2))
+	if err != nil {
+		t.Fatalf("NewUserRepository() unexpected error: %v", err)
+	}
+
+	user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+	_ = repo.Create(ctx, user)
+	_ = user.Update("updated@example.com", "Updated Name")
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+
+	restarted, err := eventstore.NewUserRepository(ctx, store)
+	if err != nil {
+		t.Fatalf("NewUserRepository() unexpected error: %v", err)
+	}
+	found, err := restarted.FindByEmail(ctx, "updated@example.com")
+	if err != nil || found.Name != "Updated Name" || found.Version != 2 {
+		t.Errorf("FindByEmail() = %+v, %v", found, err)
+	}
+	if pending, err := restarted.PendingEvents(ctx, 10); err != nil || len(pending) != 2 {
+		t.Errorf("PendingEvents() after restart = %v, %v, want 2 events", pending, err)
+	}
+}
+
+func TestEventStore_ReadAllDuringConcurrentAppends(t *testing.T) {
+	ctx := context.Background()
+	store := NewEventStore(openTestDB(t))
+
+	const appends = 50
+	var wg sync.WaitGroup
+	for i := 0; i < appends; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, _ := entity.NewUser("test@example.com", "Test User", testPassword)
+			if err := store.Append(ctx, user.ID, 0, user.PullEvents()); err != nil {
+				t.Errorf("Append() unexpected error: %v", err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Keep reading from the last position seen while appends commit, and
+	// until nothing is left once they are all done
+	var read int
+	var after int64
+	for {
+		finished := false
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+
+		records, err := store.ReadAll(ctx, after, 10)
+		if err != nil {
+			t.Fatalf("ReadAll() unexpected error: %v", err)
+		}
+		if finished && len(records) == 0 {
+			break
+		}
+		for _, record := range records {
+			read++
+			after = record.Position
+		}
+	}
+
+	if read != appends {
+		t.Errorf("ReadAll() from the last position seen read %d records, want %d", read, appends)
+	}
+}
@@ -0,0 +1,23 @@
+package postgres
+
+import "testing"
+
+func TestMigrations_Ordered(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() unexpected error: %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatalf("Migrations() returned no migrations")
+	}
+
+	for i, m := range migrations {
+		if m.SQL == "" {
+			t.Errorf("Migrations() migration %d has empty SQL", m.Version)
+		}
+		if i > 0 && migrations[i-1].Version >= m.Version {
+			t.Errorf("Migrations() not strictly ordered: %d before %d", migrations[i-1].Version, m.Version)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+CREATE TABLE users (
+    id         TEXT PRIMARY KEY,
+    email      TEXT        NOT NULL,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT users_email_key UNIQUE (email)
+);
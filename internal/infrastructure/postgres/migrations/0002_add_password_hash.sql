@@ -0,0 +1,3 @@
+-- Users created before passwords existed get an empty hash and cannot log in
+-- until a password is set for them.
+ALTER TABLE users ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
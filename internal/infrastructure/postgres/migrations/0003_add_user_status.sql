@@ -0,0 +1,6 @@
+-- Users that existed before statuses were introduced were all considered active.
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE users ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE users ADD CONSTRAINT users_status_check
+    CHECK (status IN ('pending', 'active', 'suspended', 'deactivated', 'deleted'));
+ALTER TABLE users ADD COLUMN status_history JSONB NOT NULL DEFAULT '[]';
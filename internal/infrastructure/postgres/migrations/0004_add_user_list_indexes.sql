@@ -0,0 +1,7 @@
+-- Keyset pagination orders by (<sort column>, id); names use the C collation so
+-- the database ordering matches byte-wise string comparison in Go.
+CREATE INDEX users_created_at_id_idx ON users (created_at, id);
+CREATE INDEX users_updated_at_id_idx ON users (updated_at, id);
+CREATE INDEX users_name_id_idx ON users ((name COLLATE "C"), id);
+CREATE INDEX users_status_idx ON users (status);
+CREATE INDEX users_email_domain_idx ON users ((lower(split_part(email, '@', 2))));
@@ -0,0 +1,9 @@
+-- Uniqueness moves from the email as typed to its canonical form. Existing
+-- addresses passed the old ASCII-only validation, so lowercasing them gives the
+-- canonical form under the default normalizer. If two users differ only in
+-- case this migration fails and the duplicates must be merged by hand.
+ALTER TABLE users ADD COLUMN email_canonical TEXT;
+UPDATE users SET email_canonical = lower(email);
+ALTER TABLE users ALTER COLUMN email_canonical SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_email_canonical_key UNIQUE (email_canonical);
+ALTER TABLE users DROP CONSTRAINT users_email_key;
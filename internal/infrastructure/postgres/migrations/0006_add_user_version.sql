@@ -0,0 +1,2 @@
+-- Optimistic concurrency: every update must name the version it was based on.
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
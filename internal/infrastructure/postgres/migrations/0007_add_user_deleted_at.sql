@@ -0,0 +1,4 @@
+-- Soft delete: deleted users keep their row, with deleted_at set, until purged.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+UPDATE users SET deleted_at = updated_at WHERE status = 'deleted';
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,10 @@
+-- Transactional outbox: user events are inserted in the same transaction as the
+-- user change and deleted once the relay has published them.
+CREATE TABLE user_outbox (
+    position    BIGSERIAL   PRIMARY KEY,
+    id          TEXT        NOT NULL UNIQUE,
+    user_id     TEXT        NOT NULL,
+    event_name  TEXT        NOT NULL,
+    payload     JSONB       NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL
+);
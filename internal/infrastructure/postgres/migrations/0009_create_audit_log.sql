@@ -0,0 +1,24 @@
+-- Hash-chained audit trail of user changes. Rows can only be inserted.
+CREATE TABLE audit_log (
+    sequence  BIGINT      PRIMARY KEY,
+    at        TIMESTAMPTZ NOT NULL,
+    actor     TEXT        NOT NULL,
+    action    TEXT        NOT NULL,
+    user_id   TEXT        NOT NULL,
+    changes   JSONB       NOT NULL,
+    prev_hash TEXT        NOT NULL,
+    hash      TEXT        NOT NULL UNIQUE
+);
+
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id, sequence);
+CREATE INDEX audit_log_at_idx ON audit_log (at);
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
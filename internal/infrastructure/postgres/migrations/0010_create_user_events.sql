@@ -0,0 +1,24 @@
+-- Event store: each user is a stream of events. user_streams holds the
+-- version of every stream so concurrent appends can be detected.
+CREATE TABLE user_streams (
+    user_id TEXT   PRIMARY KEY,
+    version BIGINT NOT NULL
+);
+
+CREATE TABLE user_events (
+    position    BIGSERIAL   PRIMARY KEY,
+    id          TEXT        NOT NULL UNIQUE,
+    user_id     TEXT        NOT NULL REFERENCES user_streams (user_id) ON DELETE CASCADE,
+    version     BIGINT      NOT NULL,
+    event_name  TEXT        NOT NULL,
+    payload     JSONB       NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX user_events_stream_idx ON user_events (user_id, version);
+
+CREATE TABLE user_snapshots (
+    user_id TEXT   PRIMARY KEY REFERENCES user_streams (user_id) ON DELETE CASCADE,
+    version BIGINT NOT NULL,
+    state   JSONB  NOT NULL
+);
@@ -0,0 +1,13 @@
+-- Refresh tokens, stored by the SHA-256 hash of the token. Tokens rotated
+-- from the same login share a family_id so they can be revoked together.
+CREATE TABLE refresh_tokens (
+    hash       TEXT        PRIMARY KEY,
+    user_id    TEXT        NOT NULL,
+    family_id  TEXT        NOT NULL,
+    issued_at  TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family_id);
@@ -0,0 +1,15 @@
+-- Server-side sessions, found by the SHA-256 hash of the session token.
+-- The idle timeout is applied to last_seen_at by the application;
+-- expires_at is the absolute end of the session.
+CREATE TABLE sessions (
+    id           TEXT        PRIMARY KEY,
+    token_hash   TEXT        NOT NULL UNIQUE,
+    user_id      TEXT        NOT NULL,
+    user_agent   TEXT        NOT NULL DEFAULT '',
+    ip           TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sessions_user_idx ON sessions (user_id, created_at);
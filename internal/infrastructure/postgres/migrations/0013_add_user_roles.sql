@@ -0,0 +1,3 @@
+-- Roles granting a user permissions over other users, as a JSON array of
+-- role names
+ALTER TABLE users ADD COLUMN roles JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+-- Tenant a user belongs to; empty for users that belong to none
+ALTER TABLE users ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
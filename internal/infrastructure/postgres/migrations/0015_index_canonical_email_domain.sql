@@ -0,0 +1,4 @@
+-- Listings filter by the domain of the canonical email, which is in punycode
+-- and has provider rules applied, rather than by the domain as typed
+DROP INDEX users_email_domain_idx;
+CREATE INDEX users_email_canonical_domain_idx ON users ((split_part(email_canonical, '@', 2)));
@@ -0,0 +1,14 @@
+-- Events an async event bus subscriber failed to handle after its retries,
+-- kept so they can be inspected and redelivered after a restart
+CREATE TABLE dead_letters (
+    id         BIGSERIAL   PRIMARY KEY,
+    subscriber TEXT        NOT NULL,
+    event_id   TEXT        NOT NULL,
+    event_name TEXT        NOT NULL,
+    payload    JSONB       NOT NULL,
+    attempts   INTEGER     NOT NULL,
+    error      TEXT        NOT NULL,
+    failed_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX dead_letters_failed_at_idx ON dead_letters (failed_at, id);
@@ -0,0 +1,16 @@
+-- Event store: password hashes are kept apart from the events that set them,
+-- so that user_events and user_snapshots hold no secrets. Hashes stored so far
+-- are moved over from the latest event that set one.
+CREATE TABLE user_passwords (
+    user_id       TEXT PRIMARY KEY REFERENCES user_streams (user_id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL
+);
+
+INSERT INTO user_passwords (user_id, password_hash)
+SELECT DISTINCT ON (user_id) user_id, payload->>'password_hash'
+FROM user_events
+WHERE payload ? 'password_hash'
+ORDER BY user_id, position DESC;
+
+UPDATE user_events SET payload = payload - 'password_hash' WHERE payload ? 'password_hash';
+UPDATE user_snapshots SET state = state - 'password_hash' WHERE state ? 'password_hash';
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+)
+
+// RefreshTokenStore is a PostgreSQL implementation of auth.RefreshTokenStore
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+// NewRefreshTokenStore creates a new RefreshTokenStore backed by db
+func NewRefreshTokenStore(db *sql.DB) *RefreshTokenStore {
+	return &RefreshTokenStore{
+		db: db,
+	}
+}
+
+// Save stores a newly issued token
+func (s *RefreshTokenStore) Save(ctx context.Context, token auth.RefreshToken) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (hash, user_id, family_id, issued_at, expires_at, used_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		token.Hash, token.UserID, token.FamilyID, token.IssuedAt, token.ExpiresAt,
+		nullTime(token.UsedAt), nullTime(token.RevokedAt),
+	)
+	return err
+}
+
+// Use marks the token as used unless it already is and returns it as it was
+// before. The row is locked while it is read and marked, so of two
+// concurrent calls only the first sees the token unused.
+func (s *RefreshTokenStore) Use(ctx context.Context, hash string, at time.Time) (auth.RefreshToken, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return auth.RefreshToken{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		token     = auth.RefreshToken{Hash: hash}
+		usedAt    sql.NullTime
+		revokedAt sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, family_id, issued_at, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE hash = $1
+		FOR UPDATE`, hash,
+	).Scan(&token.UserID, &token.FamilyID, &token.IssuedAt, &token.ExpiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.RefreshToken{}, auth.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return auth.RefreshToken{}, err
+	}
+	token.UsedAt = usedAt.Time
+	token.RevokedAt = revokedAt.Time
+
+	if !usedAt.Valid {
+		if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = $2 WHERE hash = $1`, hash, at); err != nil {
+			return auth.RefreshToken{}, err
+		}
+	}
+
+	return token, tx.Commit()
+}
+
+// RevokeFamily revokes every token of a family that isn't revoked yet
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE family_id = $1 AND revoked_at IS NULL`, familyID, at)
+	return err
+}
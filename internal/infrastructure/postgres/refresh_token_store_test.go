@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+)
+
+func TestRefreshTokenStore_UseAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	store := NewRefreshTokenStore(openTestDB(t))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, hash := range []string{"first", "second"} {
+		token := auth.RefreshToken{Hash: hash, UserID: "user_1", FamilyID: "family", IssuedAt: now, ExpiresAt: now.Add(time.Hour)}
+		if err := store.Save(ctx, token); err != nil {
+			t.Fatalf("Save() unexpected error: %v", err)
+		}
+	}
+
+	token, err := store.Use(ctx, "first", now)
+	if err != nil || !token.UsedAt.IsZero() || token.UserID != "user_1" {
+		t.Fatalf("Use() = %+v, %v, want the unused token", token, err)
+	}
+	if token, _ := store.Use(ctx, "first", now); !token.UsedAt.Equal(now) {
+		t.Errorf("Use() again = %+v, want it marked used", token)
+	}
+	if _, err := store.Use(ctx, "missing", now); !errors.Is(err, auth.ErrRefreshTokenNotFound) {
+		t.Errorf("Use() of an unknown token expected ErrRefreshTokenNotFound, got: %v", err)
+	}
+
+	if err := store.RevokeFamily(ctx, "family", now); err != nil {
+		t.Fatalf("RevokeFamily() unexpected error: %v", err)
+	}
+	if token, _ := store.Use(ctx, "second", now); token.RevokedAt.IsZero() {
+		t.Errorf("RevokeFamily() left %+v usable", token)
+	}
+}
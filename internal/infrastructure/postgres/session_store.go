@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+)
+
+// sessionColumns are the columns scanned by scanSession, in order
+const sessionColumns = `id, token_hash, user_id, user_agent, ip, created_at, last_seen_at, expires_at`
+
+// SessionStore is a PostgreSQL implementation of auth.SessionStore
+type SessionStore struct {
+	db *sql.DB
+}
+
+// NewSessionStore creates a new SessionStore backed by db
+func NewSessionStore(db *sql.DB) *SessionStore {
+	return &SessionStore{
+		db: db,
+	}
+}
+
+// Create stores a new session
+func (s *SessionStore) Create(ctx context.Context, session auth.Session) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sessions (`+sessionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID, session.TokenHash, session.UserID, session.UserAgent, session.IP,
+		session.CreatedAt, session.LastSeenAt, session.ExpiresAt,
+	)
+	return err
+}
+
+// FindByTokenHash returns the session with the given token hash
+func (s *SessionStore) FindByTokenHash(ctx context.Context, hash string) (auth.Session, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE token_hash = $1`, hash)
+
+	session, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Session{}, auth.ErrSessionNotFound
+	}
+	return session, err
+}
+
+// Touch records that the session was used at the given time
+func (s *SessionStore) Touch(ctx context.Context, id string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE sessions SET last_seen_at = $2 WHERE id = $1`, id, at)
+	return err
+}
+
+// ListByUser returns the sessions of a user, oldest first
+func (s *SessionStore) ListByUser(ctx context.Context, userID entity.UserID) ([]auth.Session, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []auth.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Delete removes a session of the given user
+func (s *SessionStore) Delete(ctx context.Context, userID entity.UserID, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return auth.ErrSessionNotFound
+	}
+	return nil
+}
+
+// DeleteByUser removes every session of a user
+func (s *SessionStore) DeleteByUser(ctx context.Context, userID entity.UserID) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID)
+	return err
+}
+
+// scanSession reads a session from a row holding sessionColumns
+func scanSession(row rowScanner) (auth.Session, error) {
+	var session auth.Session
+	err := row.Scan(
+		&session.ID, &session.TokenHash, &session.UserID, &session.UserAgent, &session.IP,
+		&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt,
+	)
+	return session, err
+}
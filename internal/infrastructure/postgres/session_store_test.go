@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+)
+
+func TestSessionStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewSessionStore(openTestDB(t))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, id := range []string{"first", "second"} {
+		created := now.Add(time.Duration(i) * time.Minute)
+		session := auth.Session{ID: id, TokenHash: "hash_" + id, UserID: "user_1", UserAgent: "curl", IP: "192.0.2.1", CreatedAt: created, LastSeenAt: created, ExpiresAt: created.Add(time.Hour)}
+		if err := store.Create(ctx, session); err != nil {
+			t.Fatalf("Create() unexpected error: %v", err)
+		}
+	}
+
+	session, err := store.FindByTokenHash(ctx, "hash_first")
+	if err != nil || session.ID != "first" || session.UserAgent != "curl" {
+		t.Fatalf("FindByTokenHash() = %+v, %v, want the first session", session, err)
+	}
+	if _, err := store.FindByTokenHash(ctx, "missing"); !errors.Is(err, auth.ErrSessionNotFound) {
+		t.Errorf("FindByTokenHash() of an unknown hash expected ErrSessionNotFound, got: %v", err)
+	}
+
+	if err := store.Touch(ctx, "first", now.Add(10*time.Minute)); err != nil {
+		t.Fatalf("Touch() unexpected error: %v", err)
+	}
+	sessions, err := store.ListByUser(ctx, "user_1")
+	if err != nil || len(sessions) != 2 || sessions[0].ID != "first" {
+		t.Fatalf("ListByUser() = %+v, %v, want both sessions oldest first", sessions, err)
+	}
+	if !sessions[0].LastSeenAt.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("Touch() last seen = %v", sessions[0].LastSeenAt)
+	}
+
+	if err := store.Delete(ctx, "user_2", "first"); !errors.Is(err, auth.ErrSessionNotFound) {
+		t.Errorf("Delete() of another user's session expected ErrSessionNotFound, got: %v", err)
+	}
+	if err := store.Delete(ctx, "user_1", "first"); err != nil {
+		t.Errorf("Delete() unexpected error: %v", err)
+	}
+	if err := store.DeleteByUser(ctx, "user_1"); err != nil {
+		t.Fatalf("DeleteByUser() unexpected error: %v", err)
+	}
+	if sessions, _ := store.ListByUser(ctx, "user_1"); len(sessions) != 0 {
+		t.Errorf("DeleteByUser() left %+v", sessions)
+	}
+}
@@ -0,0 +1,476 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+)
+
+// uniqueViolation is the PostgreSQL error code for unique constraint violations
+const uniqueViolation = "23505"
+
+// UserRepository is a PostgreSQL implementation of repository.UserRepository
+type UserRepository struct {
+	db *sql.DB
+}
+
+// NewUserRepository creates a new UserRepository backed by db
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{
+		db: db,
+	}
+}
+
+// Create inserts a new user and its pending events. The primary key and the
+// unique canonical email make the insert fail with
+// repository.ErrUserAlreadyExists on duplicates.
+func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
+	if user == nil {
+		return repository.ErrInvalidUser
+	}
+
+	history, err := marshalStatusHistory(user)
+	if err != nil {
+		return err
+	}
+	roles, err := marshalRoles(user)
+	if err != nil {
+		return err
+	}
+
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO users (id, email, email_canonical, name, password_hash, status, status_history,
+			                   roles, tenant, created_at, updated_at, deleted_at, version)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			user.ID, user.Email, user.CanonicalEmail, user.Name, user.Password.Hash(), user.Status, history,
+			roles, user.Tenant, user.CreatedAt, user.UpdatedAt, nullTime(user.DeletedAt), user.Version,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+
+		return insertEvents(ctx, tx, user.Events())
+	})
+	if err != nil {
+		return err
+	}
+
+	user.PullEvents()
+	return nil
+}
+
+// FindByID retrieves a user by their ID
+func (r *UserRepository) FindByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL`, id)
+
+	return scanUser(row)
+}
+
+// FindDeletedByID retrieves a soft-deleted user by their ID
+func (r *UserRepository) FindDeletedByID(ctx context.Context, id entity.UserID) (*entity.User, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+
+	return scanUser(row)
+}
+
+// FindByEmail retrieves a user by their canonical email
+func (r *UserRepository) FindByEmail(ctx context.Context, email entity.Email) (*entity.User, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE email_canonical = $1 AND deleted_at IS NULL`, email)
+
+	return scanUser(row)
+}
+
+// Update updates an existing user if its stored version matches user.Version,
+// and inserts its pending events in the same transaction
+func (r *UserRepository) Update(ctx context.Context, user *entity.User) error {
+	if user == nil {
+		return repository.ErrInvalidUser
+	}
+
+	history, err := marshalStatusHistory(user)
+	if err != nil {
+		return err
+	}
+	roles, err := marshalRoles(user)
+	if err != nil {
+		return err
+	}
+
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE users
+			SET email = $2, email_canonical = $3, name = $4, password_hash = $5, status = $6,
+			    status_history = $7, roles = $8, tenant = $9, updated_at = $10, deleted_at = $11,
+			    version = version + 1
+			WHERE id = $1 AND version = $12`,
+			user.ID, user.Email, user.CanonicalEmail, user.Name, user.Password.Hash(), user.Status, history,
+			roles, user.Tenant, user.UpdatedAt, nullTime(user.DeletedAt), user.Version,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+
+		if err := expectAffected(result); err != nil {
+			return r.versionConflict(ctx, tx, user, err)
+		}
+
+		return insertEvents(ctx, tx, user.Events())
+	})
+	if err != nil {
+		return err
+	}
+
+	user.Version++
+	user.PullEvents()
+	return nil
+}
+
+// versionConflict explains why an update touched no rows: either the user is
+// gone, or it was changed since user was read
+func (r *UserRepository) versionConflict(ctx context.Context, tx *sql.Tx, user *entity.User, notFound error) error {
+	var actual int64
+	err := tx.QueryRowContext(ctx, `SELECT version FROM users WHERE id = $1`, user.ID).Scan(&actual)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	if err != nil {
+		return mapError(err)
+	}
+
+	return &repository.VersionConflictError{ID: user.ID, Expected: user.Version, Actual: actual}
+}
+
+// Delete permanently removes a user by their ID
+func (r *UserRepository) Delete(ctx context.Context, id entity.UserID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return mapError(err)
+	}
+
+	return expectAffected(result)
+}
+
+// PurgeDeleted permanently removes users soft-deleted before cutoff
+func (r *UserRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	return purged, nil
+}
+
+// PendingEvents returns up to limit unpublished events, oldest first
+func (r *UserRepository) PendingEvents(ctx context.Context, limit int) ([]entity.Event, error) {
+	return pendingEvents(ctx, r.db, limit)
+}
+
+// MarkEventsPublished deletes the events with the given IDs from the outbox
+func (r *UserRepository) MarkEventsPublished(ctx context.Context, ids ...string) error {
+	return markEventsPublished(ctx, r.db, ids)
+}
+
+// sortColumns maps sort fields to the SQL expression used for ordering
+var sortColumns = map[repository.SortField]string{
+	repository.SortByCreatedAt: "created_at",
+	repository.SortByUpdatedAt: "updated_at",
+	repository.SortByName:      `(name COLLATE "C")`,
+}
+
+// List returns one page of users matching the query using keyset pagination
+func (r *UserRepository) List(ctx context.Context, query repository.ListQuery) (*repository.UserPage, error) {
+	column, ok := sortColumns[query.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported sort field %q", repository.ErrInvalidListQuery, query.SortBy)
+	}
+
+	var (
+		conditions []string
+		args       []any
+	)
+	addArg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	filter := query.Filter
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		conditions = append(conditions, "status = ANY("+addArg(pq.Array(statuses))+")")
+	}
+	if filter.EmailDomain != "" {
+		conditions = append(conditions, "split_part(email_canonical, '@', 2) = "+addArg(strings.ToLower(filter.EmailDomain)))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= "+addArg(filter.CreatedAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+addArg(filter.CreatedBefore))
+	}
+
+	direction, comparison := "ASC", ">"
+	if query.Order == repository.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if query.Cursor != "" {
+		cursor, err := query.DecodeCursor()
+		if err != nil {
+			return nil, err
+		}
+
+		var value any = cursor.Time
+		if query.SortBy == repository.SortByName {
+			value = cursor.Name
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			column, comparison, addArg(value), addArg(cursor.ID)))
+	}
+
+	statement := "SELECT " + userColumns + " FROM users"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", column, direction, direction, addArg(query.Limit+1))
+
+	rows, err := r.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	page := &repository.UserPage{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Users = append(page.Users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	if len(page.Users) > query.Limit {
+		page.Users = page.Users[:query.Limit]
+		page.NextCursor = query.CursorAfter(page.Users[query.Limit-1])
+	}
+
+	return page, nil
+}
+
+// userColumns lists the columns read by scanUser, in order
+const userColumns = "id, email, email_canonical, name, password_hash, status, status_history, roles, tenant, created_at, updated_at, deleted_at, version"
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser reads a single user row
+func scanUser(row rowScanner) (*entity.User, error) {
+	var (
+		user         entity.User
+		passwordHash string
+		history      []byte
+		roles        []byte
+		deletedAt    sql.NullTime
+	)
+	err := row.Scan(&user.ID, &user.Email, &user.CanonicalEmail, &user.Name, &passwordHash,
+		&user.Status, &history, &roles, &user.Tenant, &user.CreatedAt, &user.UpdatedAt, &deletedAt, &user.Version)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	if deletedAt.Valid {
+		user.DeletedAt = deletedAt.Time
+	}
+
+	if err := json.Unmarshal(history, &user.StatusHistory); err != nil {
+		return nil, fmt.Errorf("user %s: failed to decode status history: %w", user.ID, err)
+	}
+	if err := json.Unmarshal(roles, &user.Roles); err != nil {
+		return nil, fmt.Errorf("user %s: failed to decode roles: %w", user.ID, err)
+	}
+	if len(user.Roles) == 0 {
+		user.Roles = nil
+	}
+
+	user.Password, err = entity.PasswordFromHash(passwordHash)
+	if err != nil {
+		return nil, fmt.Errorf("user %s: %w", user.ID, err)
+	}
+
+	return &user, nil
+}
+
+// inTx runs fn in a transaction, committing if it returns nil
+func (r *UserRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+// insertEvents adds events to the outbox within tx, without their secrets
+func insertEvents(ctx context.Context, tx *sql.Tx, events []entity.Event) error {
+	for _, event := range entity.WithoutSecrets(events) {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", event.EventName(), err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO user_outbox (id, user_id, event_name, payload, occurred_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			event.EventID(), event.AggregateID(), event.EventName(), payload, event.OccurredAt(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to write %s event to outbox: %w", event.EventName(), err)
+		}
+	}
+	return nil
+}
+
+// pendingEvents reads up to limit events from the outbox, oldest first
+func pendingEvents(ctx context.Context, db *sql.DB, limit int) ([]entity.Event, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT event_name, payload
+		FROM user_outbox
+		ORDER BY position
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []entity.Event
+	for rows.Next() {
+		var (
+			name    string
+			payload []byte
+		)
+		if err := rows.Scan(&name, &payload); err != nil {
+			return nil, err
+		}
+
+		event, err := entity.UnmarshalEvent(name, payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// markEventsPublished deletes the events with the given IDs from the outbox
+func markEventsPublished(ctx context.Context, db *sql.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `DELETE FROM user_outbox WHERE id = ANY($1)`, pq.Array(ids))
+	return err
+}
+
+// nullTime maps the zero time to NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// marshalStatusHistory encodes the status history for the JSONB column
+func marshalStatusHistory(user *entity.User) ([]byte, error) {
+	history := user.StatusHistory
+	if history == nil {
+		history = []entity.StatusTransition{}
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode status history: %w", err)
+	}
+	return data, nil
+}
+
+// expectAffected returns repository.ErrUserNotFound if no row was changed
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return repository.ErrUserNotFound
+	}
+	return nil
+}
+
+// mapError translates driver errors into repository errors
+func mapError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrUserNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return repository.ErrUserAlreadyExists
+	}
+
+	return err
+}
+
+// marshalRoles encodes the user's roles for the JSONB column
+func marshalRoles(user *entity.User) ([]byte, error) {
+	roles := user.Roles
+	if roles == nil {
+		roles = []entity.Role{}
+	}
+
+	data, err := json.Marshal(roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode roles: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+)
+
+// AuditHandler exposes the audit log over HTTP, through the user service so
+// that only callers allowed to read or verify it can
+type AuditHandler struct {
+	service *service.UserService
+}
+
+// NewAuditHandler creates a new AuditHandler instance
+func NewAuditHandler(service *service.UserService) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the audit endpoints on the given mux
+func (h *AuditHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/audit", h.ListEntries)
+	mux.HandleFunc("GET /api/v1/audit/verify", h.Verify)
+}
+
+// auditEntryResponse is the JSON representation of an audit entry
+type auditEntryResponse struct {
+	Sequence int64               `json:"sequence"`
+	At       time.Time           `json:"at"`
+	Actor    string              `json:"actor"`
+	Action   string              `json:"action"`
+	UserID   string              `json:"user_id,omitempty"`
+	Changes  []audit.FieldChange `json:"changes"`
+	PrevHash string              `json:"prev_hash"`
+	Hash     string              `json:"hash"`
+}
+
+func newAuditEntryResponse(entry audit.Entry) auditEntryResponse {
+	changes := entry.Changes
+	if changes == nil {
+		changes = []audit.FieldChange{}
+	}
+
+	return auditEntryResponse{
+		Sequence: entry.Sequence,
+		At:       entry.At,
+		Actor:    entry.Actor,
+		Action:   string(entry.Action),
+		UserID:   entry.UserID.String(),
+		Changes:  changes,
+		PrevHash: entry.PrevHash,
+		Hash:     entry.Hash,
+	}
+}
+
+// listAuditEntriesResponse is the JSON representation of a page of entries
+type listAuditEntriesResponse struct {
+	Entries []auditEntryResponse `json:"entries"`
+	// NextAfter is passed back as after to get the next page
+	NextAfter int64 `json:"next_after,omitempty"`
+}
+
+// verifyResponse reports the result of checking the audit chain. HeadHash
+// is the hash of the last valid entry, to compare with one recorded earlier.
+type verifyResponse struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	HeadHash string `json:"head_hash,omitempty"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ListEntries handles GET /api/v1/audit.
+// Supported query parameters: user_id, from and to (RFC 3339), after and limit.
+func (h *AuditHandler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAuditQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	entries, err := h.service.QueryAuditLog(r.Context(), query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := listAuditEntriesResponse{Entries: make([]auditEntryResponse, 0, len(entries))}
+	for _, entry := range entries {
+		resp.Entries = append(resp.Entries, newAuditEntryResponse(entry))
+	}
+	if len(entries) > 0 && len(entries) == query.Limit {
+		resp.NextAfter = entries[len(entries)-1].Sequence
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseAuditQuery builds an audit.Query from URL query parameters
+func parseAuditQuery(values url.Values) (audit.Query, error) {
+	var query audit.Query
+
+	if raw := values.Get("user_id"); raw != "" {
+		id, err := entity.ParseUserID(raw)
+		if err != nil {
+			return query, err
+		}
+		query.UserID = id
+	}
+
+	for param, dst := range map[string]*time.Time{
+		"from": &query.From,
+		"to":   &query.To,
+	} {
+		if raw := values.Get(param); raw != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, raw)
+			if err != nil {
+				return query, invalidTimestamp(param)
+			}
+			*dst = parsed
+		}
+	}
+
+	if raw := values.Get("after"); raw != "" {
+		after, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || after < 0 {
+			return query, invalidField("after", "invalid_parameter", "after must be a non-negative integer")
+		}
+		query.AfterSequence = after
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return query, invalidField("limit", "invalid_parameter", "limit must be a positive integer")
+		}
+		query.Limit = limit
+	}
+
+	return query.Normalize()
+}
+
+// Verify handles GET /api/v1/audit/verify. It walks the whole chain and
+// reports its head and the first entry that has been tampered with, if any.
+func (h *AuditHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	head, err := h.service.VerifyAuditLog(r.Context())
+
+	var chainErr *audit.ChainError
+	switch {
+	case errors.As(err, &chainErr):
+		writeJSON(w, http.StatusOK, verifyResponse{
+			Checked:  head.Sequence,
+			HeadHash: head.Hash,
+			BrokenAt: chainErr.Sequence,
+			Error:    chainErr.Error(),
+		})
+	case err != nil:
+		writeError(w, err)
+	default:
+		writeJSON(w, http.StatusOK, verifyResponse{Valid: true, Checked: head.Sequence, HeadHash: head.Hash})
+	}
+}
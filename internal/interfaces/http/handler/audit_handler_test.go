@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAuditHandler_ListEntries(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+	doRequest(mux, http.MethodPut, "/api/v1/users/"+user.ID, `{"email":"test@example.com","name":"Renamed"}`)
+	other := doRequest(mux, http.MethodPost, "/api/v1/users", `{"email":"other@example.com","name":"Other","password":"Secret-passw0rd"}`)
+	if other.Code != http.StatusCreated {
+		t.Fatalf("CreateUser() status = %d", other.Code)
+	}
+
+	rec := doRequest(mux, http.MethodGet, "/api/v1/audit?user_id="+user.ID+"&limit=1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListEntries() status = %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	var page listAuditEntriesResponse
+	_ = json.NewDecoder(rec.Body).Decode(&page)
+	if len(page.Entries) != 1 || page.Entries[0].Action != "create" || page.NextAfter != page.Entries[0].Sequence {
+		t.Fatalf("ListEntries() first page = %+v", page)
+	}
+
+	rec = doRequest(mux, http.MethodGet, "/api/v1/audit?user_id="+user.ID+"&after=1", "")
+	page = listAuditEntriesResponse{}
+	_ = json.NewDecoder(rec.Body).Decode(&page)
+	if len(page.Entries) != 1 || page.Entries[0].Action != "update" || page.NextAfter != 0 {
+		t.Fatalf("ListEntries() second page = %+v", page)
+	}
+	if changes := page.Entries[0].Changes; len(changes) != 1 || changes[0].To != "Renamed" {
+		t.Errorf("ListEntries() update changes = %+v", changes)
+	}
+
+	for _, query := range []string{"user_id=bogus", "from=yesterday", "after=-1", "limit=0", "limit=5000",
+		"from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z"} {
+		if rec := doRequest(mux, http.MethodGet, "/api/v1/audit?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("ListEntries(%s) status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAuditHandler_Verify(t *testing.T) {
+	mux := newTestServer()
+	createTestUser(t, mux)
+
+	rec := doRequest(mux, http.MethodGet, "/api/v1/audit/verify", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Verify() status = %d", rec.Code)
+	}
+
+	var resp verifyResponse
+	_ = json.NewDecoder(rec.Body).Decode(&resp)
+	if !resp.Valid || resp.Checked != 1 || resp.HeadHash == "" {
+		t.Errorf("Verify() = %+v, want a valid chain of 1 entry", resp)
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+)
+
+// AuthHandler exposes AuthService over HTTP
+type AuthHandler struct {
+	service *auth.AuthService
+}
+
+// NewAuthHandler creates a new AuthHandler instance
+func NewAuthHandler(service *auth.AuthService) *AuthHandler {
+	return &AuthHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the authentication endpoints on the given mux
+func (h *AuthHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/auth/login", h.Login)
+	mux.HandleFunc("POST /api/v1/auth/refresh", h.Refresh)
+	mux.HandleFunc("POST /api/v1/auth/logout", h.Logout)
+}
+
+// loginRequest is the JSON body accepted by login
+type loginRequest struct {
+	Email    *string `json:"email"`
+	Password *string `json:"password"`
+}
+
+// validate checks that all required fields are present
+func (r loginRequest) validate() error {
+	if r.Email == nil {
+		return missingField("email")
+	}
+	if r.Password == nil {
+		return missingField("password")
+	}
+	return nil
+}
+
+// refreshTokenRequest is the JSON body accepted by refresh and logout
+type refreshTokenRequest struct {
+	RefreshToken *string `json:"refresh_token"`
+}
+
+// validate checks that all required fields are present
+func (r refreshTokenRequest) validate() error {
+	if r.RefreshToken == nil {
+		return missingField("refresh_token")
+	}
+	return nil
+}
+
+// tokenResponse is the JSON representation of a token pair, shaped like an
+// OAuth 2.0 token response
+type tokenResponse struct {
+	AccessToken           string    `json:"access_token"`
+	TokenType             string    `json:"token_type"`
+	ExpiresIn             int64     `json:"expires_in"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+func newTokenResponse(pair *auth.TokenPair) tokenResponse {
+	return tokenResponse{
+		AccessToken:           pair.AccessToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             int64(pair.AccessTokenExpiresAt.Sub(pair.IssuedAt).Seconds()),
+		RefreshToken:          pair.RefreshToken,
+		RefreshTokenExpiresAt: pair.RefreshTokenExpiresAt,
+	}
+}
+
+// Login handles POST /api/v1/auth/login
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	pair, err := h.service.Login(r.Context(), *req.Email, *req.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeTokens(w, pair)
+}
+
+// Refresh handles POST /api/v1/auth/refresh. The refresh token is used up;
+// the response carries its replacement.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshTokenRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	pair, err := h.service.Refresh(r.Context(), *req.RefreshToken)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeTokens(w, pair)
+}
+
+// Logout handles POST /api/v1/auth/logout
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req refreshTokenRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), *req.RefreshToken); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeTokens writes a token pair, keeping it out of caches
+func writeTokens(w http.ResponseWriter, pair *auth.TokenPair) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, newTokenResponse(pair))
+}
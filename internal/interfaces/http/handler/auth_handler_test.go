@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/memory"
+)
+
+// newAPITestServer serves every endpoint behind the Authenticate and
+// AuthenticateSession middleware, like cmd/api does. The user service is
+// configured with opts on top of a fast password hasher and the session
+// store, so that suspending or deleting users ends their sessions.
+func newAPITestServer(t *testing.T, opts ...service.Option) (http.Handler, *service.UserService) {
+	t.Helper()
+
+	sessions := memory.NewSessionStore()
+	opts = append([]service.Option{
+		service.WithPasswordHasher(entity.PasswordHasher{Iterations: 1000, SaltLength: 16, KeyLength: 32}),
+		service.WithSessions(sessions),
+	}, opts...)
+	userService := service.NewUserService(memory.NewUserRepository(), opts...)
+
+	key, err := auth.NewHS256Key("test", []byte(strings.Repeat("k", auth.MinHS256SecretLength)))
+	if err != nil {
+		t.Fatalf("NewHS256Key() unexpected error: %v", err)
+	}
+	authService := auth.NewAuthService(userService, auth.NewKeyring(key), memory.NewRefreshTokenStore())
+	sessionService := auth.NewSessionService(userService, sessions)
+
+	mux := http.NewServeMux()
+	NewUserHandler(userService).RegisterRoutes(mux)
+	NewAuditHandler(userService).RegisterRoutes(mux)
+	NewAuthHandler(authService).RegisterRoutes(mux)
+	NewSessionHandler(sessionService).RegisterRoutes(mux)
+	NewAuthzHandler(userService).RegisterRoutes(mux)
+	return Authenticate(authService)(AuthenticateSession(sessionService)(mux)), userService
+}
+
+// newAuthTestServer serves every endpoint with an audit log and no authorizer
+func newAuthTestServer(t *testing.T) http.Handler {
+	t.Helper()
+
+	server, _ := newAPITestServer(t, service.WithAuditLog(audit.NewLog(memory.NewAuditRepository())))
+	return server
+}
+
+func doAuthorizedRequest(server http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+func login(t *testing.T, server http.Handler, body string) tokenResponse {
+	t.Helper()
+
+	rec := doAuthorizedRequest(server, http.MethodPost, "/api/v1/auth/login", body, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Login() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var tokens tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&tokens); err != nil {
+		t.Fatalf("Login() failed to decode response: %v", err)
+	}
+	return tokens
+}
+
+func TestAuthHandler_Login(t *testing.T) {
+	server := newAuthTestServer(t)
+	doAuthorizedRequest(server, http.MethodPost, "/api/v1/users",
+		`{"email":"test@example.com","name":"Test User","password":"Secret-passw0rd"}`, "")
+
+	tokens := login(t, server, `{"email":"test@example.com","password":"Secret-passw0rd"}`)
+	if tokens.TokenType != "Bearer" || tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.ExpiresIn != 900 {
+		t.Errorf("Login() = %+v", tokens)
+	}
+
+	rec := doAuthorizedRequest(server, http.MethodPost, "/api/v1/auth/login", `{"email":"test@example.com","password":"wrong"}`, "")
+	if rec.Code != http.StatusUnauthorized || decodeProblem(t, rec).Code != "login_failed" {
+		t.Errorf("Login() with a wrong password status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = doAuthorizedRequest(server, http.MethodPost, "/api/v1/auth/login", `{"email":"test@example.com"}`, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Login() without a password status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAuthHandler_RefreshAndLogout(t *testing.T) {
+	server := newAuthTestServer(t)
+	doAuthorizedRequest(server, http.MethodPost, "/api/v1/users",
+		`{"email":"test@example.com","name":"Test User","password":"Secret-passw0rd"}`, "")
+	tokens := login(t, server, `{"email":"test@example.com","password":"Secret-passw0rd"}`)
+
+	rec := doAuthorizedRequest(server, http.MethodPost, "/api/v1/auth/refresh", `{"refresh_token":"`+tokens.RefreshToken+`"}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Refresh() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var refreshed tokenResponse
+	_ = json.NewDecoder(rec.Body).Decode(&refreshed)
+
+	rec = doAuthorizedRequest(server, http.MethodPost, "/api/v1/auth/logout", `{"refresh_token":"`+refreshed.RefreshToken+`"}`, "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Logout() status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	rec = doAuthorizedRequest(server, http.MethodPost, "/api/v1/auth/refresh", `{"refresh_token":"`+refreshed.RefreshToken+`"}`, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh() after logout status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthenticate_Middleware(t *testing.T) {
+	server := newAuthTestServer(t)
+	rec := doAuthorizedRequest(server, http.MethodPost, "/api/v1/users",
+		`{"email":"test@example.com","name":"Test User","password":"Secret-passw0rd"}`, "")
+	var user userResponse
+	_ = json.NewDecoder(rec.Body).Decode(&user)
+	tokens := login(t, server, `{"email":"test@example.com","password":"Secret-passw0rd"}`)
+
+	path := "/api/v1/users/" + user.ID
+	rec = doAuthorizedRequest(server, http.MethodPut, path, `{"email":"test@example.com","name":"Renamed"}`, tokens.AccessToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateUser() with a token status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	// The caller is recorded as the actor of the change, and the anonymous
+	// registration as made by no one in particular
+	rec = doAuthorizedRequest(server, http.MethodGet, path+"/history", "", "")
+	var history userHistoryResponse
+	_ = json.NewDecoder(rec.Body).Decode(&history)
+	if len(history.Revisions) != 2 || history.Revisions[1].Actor != user.ID {
+		t.Errorf("GetUserHistory() = %+v, want the update made by %s", history.Revisions, user.ID)
+	} else if history.Revisions[0].Actor != service.AnonymousActor {
+		t.Errorf("GetUserHistory() registration actor = %q, want %q", history.Revisions[0].Actor, service.AnonymousActor)
+	}
+
+	for name, header := range map[string]string{
+		"bad token":     "Bearer not-a-token",
+		"wrong scheme":  "Basic dXNlcjpwYXNz",
+		"missing token": "Bearer ",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", header)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code == http.StatusOK || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: status = %d, WWW-Authenticate %q, want a rejection", name, rec.Code, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
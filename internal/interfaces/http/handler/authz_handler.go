@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+)
+
+// AuthzHandler exposes dry runs of authorization decisions over HTTP
+type AuthzHandler struct {
+	service *service.UserService
+}
+
+// NewAuthzHandler creates a new AuthzHandler instance
+func NewAuthzHandler(service *service.UserService) *AuthzHandler {
+	return &AuthzHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the authorization endpoints on the given mux
+func (h *AuthzHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/authz/check", h.CheckAccess)
+}
+
+// accessCheckRequest is the JSON body accepted by CheckAccess. Subject and
+// resource are optional: without them the check is for an anonymous caller,
+// or for an action such as create or list.
+type accessCheckRequest struct {
+	SubjectID  string     `json:"subject_id"`
+	Action     *string    `json:"action"`
+	ResourceID string     `json:"resource_id"`
+	At         *time.Time `json:"at"`
+}
+
+// toAccessCheck checks the request and converts it for the service
+func (r accessCheckRequest) toAccessCheck() (service.AccessCheck, error) {
+	if r.Action == nil {
+		return service.AccessCheck{}, missingField("action")
+	}
+
+	check := service.AccessCheck{Action: authz.Action(*r.Action)}
+	for _, field := range []struct {
+		raw string
+		dst *entity.UserID
+	}{
+		{r.SubjectID, &check.SubjectID},
+		{r.ResourceID, &check.ResourceID},
+	} {
+		if field.raw == "" {
+			continue
+		}
+		id, err := entity.ParseUserID(field.raw)
+		if err != nil {
+			return service.AccessCheck{}, err
+		}
+		*field.dst = id
+	}
+	if r.At != nil {
+		check.At = *r.At
+	}
+	return check, nil
+}
+
+// decisionResponse is the JSON representation of an explained decision
+type decisionResponse struct {
+	Allowed     bool                     `json:"allowed"`
+	Rule        string                   `json:"rule,omitempty"`
+	Reason      string                   `json:"reason"`
+	Evaluations []ruleEvaluationResponse `json:"evaluations"`
+}
+
+// ruleEvaluationResponse is the JSON representation of how a rule fared
+type ruleEvaluationResponse struct {
+	Rule       string                    `json:"rule"`
+	Effect     string                    `json:"effect"`
+	Matched    bool                      `json:"matched"`
+	Conditions []conditionResultResponse `json:"conditions"`
+}
+
+// conditionResultResponse is the JSON representation of how a condition fared
+type conditionResultResponse struct {
+	Condition string `json:"condition"`
+	Actual    any    `json:"actual"`
+	Expected  any    `json:"expected"`
+	Matched   bool   `json:"matched"`
+}
+
+func newDecisionResponse(decision authz.Decision) decisionResponse {
+	evaluations := make([]ruleEvaluationResponse, 0, len(decision.Evaluations))
+	for _, evaluation := range decision.Evaluations {
+		conditions := make([]conditionResultResponse, 0, len(evaluation.Conditions))
+		for _, result := range evaluation.Conditions {
+			conditions = append(conditions, conditionResultResponse{
+				Condition: result.Condition,
+				Actual:    result.Actual,
+				Expected:  result.Expected,
+				Matched:   result.Matched,
+			})
+		}
+		evaluations = append(evaluations, ruleEvaluationResponse{
+			Rule:       evaluation.Rule,
+			Effect:     string(evaluation.Effect),
+			Matched:    evaluation.Matched,
+			Conditions: conditions,
+		})
+	}
+
+	return decisionResponse{
+		Allowed:     decision.Allowed,
+		Rule:        decision.Rule,
+		Reason:      decision.Reason,
+		Evaluations: evaluations,
+	}
+}
+
+// CheckAccess handles POST /api/v1/authz/check, explaining whether a caller
+// may perform an action without performing it
+func (h *AuthzHandler) CheckAccess(w http.ResponseWriter, r *http.Request) {
+	var req accessCheckRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	check, err := req.toAccessCheck()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	decision, err := h.service.CheckAccess(r.Context(), check)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newDecisionResponse(decision))
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+)
+
+// newPolicyTestServer serves every endpoint with the example policy enforced
+func newPolicyTestServer(t *testing.T) (http.Handler, *service.UserService) {
+	t.Helper()
+
+	policy, err := authz.LoadPolicy("../../../domain/authz/testdata/policy.json")
+	if err != nil {
+		t.Fatalf("LoadPolicy() unexpected error: %v", err)
+	}
+	return newAPITestServer(t, service.WithAuthorizer(authz.NewPolicyAuthorizer(policy)))
+}
+
+func TestAuthzHandler_CheckAccess(t *testing.T) {
+	server, userService := newPolicyTestServer(t)
+	customer, customerToken := registerAndLogin(t, server, "customer@acme.com")
+	support, _ := registerAndLogin(t, server, "support@acme.com")
+	admin, adminToken := registerAndLogin(t, server, "admin@acme.com")
+	system := service.ContextWithSystem(context.Background())
+	if err := userService.AssignRoles(system, entity.UserID(support.ID), []entity.Role{entity.RoleSupport}); err != nil {
+		t.Fatalf("AssignRoles() unexpected error: %v", err)
+	}
+	if err := userService.AssignRoles(system, entity.UserID(admin.ID), []entity.Role{entity.RoleAdmin}); err != nil {
+		t.Fatalf("AssignRoles() unexpected error: %v", err)
+	}
+	for _, id := range []string{support.ID, customer.ID} {
+		rec := doAuthorizedRequest(server, http.MethodPut, "/api/v1/users/"+id+"/tenant", `{"tenant":"acme"}`, adminToken)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("AssignTenant() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+	}
+
+	// 19:00 on a Wednesday in Belgrade, after business hours
+	body := `{"subject_id":"` + support.ID + `","action":"user.suspend","resource_id":"` + customer.ID + `","at":"2024-05-08T17:00:00Z"}`
+	rec := doAuthorizedRequest(server, http.MethodPost, "/api/v1/authz/check", body, adminToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("CheckAccess() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var decision decisionResponse
+	_ = json.NewDecoder(rec.Body).Decode(&decision)
+	if decision.Allowed || decision.Reason != "no rule allows user.suspend" || len(decision.Evaluations) != 3 {
+		t.Fatalf("CheckAccess() = %+v", decision)
+	}
+	hours := decision.Evaluations[1].Conditions[5]
+	if hours.Condition != "env.hour lt 17" || hours.Actual != float64(19) || hours.Matched {
+		t.Errorf("CheckAccess() hour condition = %+v", hours)
+	}
+
+	body = `{"subject_id":"` + support.ID + `","action":"user.suspend","resource_id":"` + customer.ID + `","at":"2024-05-08T08:00:00Z"}`
+	rec = doAuthorizedRequest(server, http.MethodPost, "/api/v1/authz/check", body, adminToken)
+	_ = json.NewDecoder(rec.Body).Decode(&decision)
+	if !decision.Allowed || decision.Rule != "support-edit-own-tenant" {
+		t.Errorf("CheckAccess() in business hours = %+v", decision)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		token      string
+		wantStatus int
+	}{
+		{"anonymous", `{"action":"user.read"}`, "", http.StatusUnauthorized},
+		{"without the permission", `{"action":"user.read"}`, customerToken, http.StatusForbidden},
+		{"missing action", `{}`, adminToken, http.StatusBadRequest},
+		{"unknown action", `{"action":"user.fly"}`, adminToken, http.StatusUnprocessableEntity},
+		{"malformed subject", `{"action":"user.read","subject_id":"bob"}`, adminToken, http.StatusBadRequest},
+		{"missing resource", `{"action":"user.read","resource_id":"user_01HV6X5Q9Z3K8M2N4P7R0T1W2Y"}`, adminToken, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doAuthorizedRequest(server, http.MethodPost, "/api/v1/authz/check", tt.body, tt.token)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("CheckAccess() status = %d, want %d, body: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
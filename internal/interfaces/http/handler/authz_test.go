@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/memory"
+)
+
+// newAuthzTestServer serves every endpoint with an audit log and the
+// default role grants enforced
+func newAuthzTestServer(t *testing.T) (http.Handler, *service.UserService) {
+	t.Helper()
+
+	return newAPITestServer(t,
+		service.WithAuthorizer(authz.NewRoleAuthorizer(authz.DefaultGrants)),
+		service.WithAuditLog(audit.NewLog(memory.NewAuditRepository())),
+	)
+}
+
+// registerAndLogin creates a user and returns it with an access token
+func registerAndLogin(t *testing.T, server http.Handler, email string) (userResponse, string) {
+	t.Helper()
+
+	rec := doAuthorizedRequest(server, http.MethodPost, "/api/v1/users",
+		`{"email":"`+email+`","name":"Test User","password":"Secret-passw0rd"}`, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateUser() status = %d, want %d, body: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var user userResponse
+	if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+		t.Fatalf("CreateUser() failed to decode response: %v", err)
+	}
+	return user, login(t, server, `{"email":"`+email+`","password":"Secret-passw0rd"}`).AccessToken
+}
+
+func TestUserHandler_Authorization(t *testing.T) {
+	server, userService := newAuthzTestServer(t)
+	alice, aliceToken := registerAndLogin(t, server, "alice@example.com")
+	bob, _ := registerAndLogin(t, server, "bob@example.com")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		token      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"anonymous read", http.MethodGet, "/api/v1/users/" + alice.ID, "", "", http.StatusUnauthorized, "authentication_required"},
+		{"read own record", http.MethodGet, "/api/v1/users/" + alice.ID, "", aliceToken, http.StatusOK, ""},
+		{"read another user", http.MethodGet, "/api/v1/users/" + bob.ID, "", aliceToken, http.StatusForbidden, "forbidden"},
+		{"list users", http.MethodGet, "/api/v1/users", "", aliceToken, http.StatusForbidden, "forbidden"},
+		{"suspend another user", http.MethodPost, "/api/v1/users/" + bob.ID + "/suspend", `{"reason":"abuse"}`, aliceToken, http.StatusForbidden, "forbidden"},
+		{"grant own roles", http.MethodPut, "/api/v1/users/" + alice.ID + "/roles", `{"roles":["admin"]}`, aliceToken, http.StatusForbidden, "forbidden"},
+		{"change own tenant", http.MethodPut, "/api/v1/users/" + alice.ID + "/tenant", `{"tenant":"acme"}`, aliceToken, http.StatusForbidden, "forbidden"},
+		{"anonymous audit read", http.MethodGet, "/api/v1/audit", "", "", http.StatusUnauthorized, "authentication_required"},
+		{"anonymous audit verify", http.MethodGet, "/api/v1/audit/verify", "", "", http.StatusUnauthorized, "authentication_required"},
+		{"audit read", http.MethodGet, "/api/v1/audit", "", aliceToken, http.StatusForbidden, "forbidden"},
+		{"audit verify", http.MethodGet, "/api/v1/audit/verify", "", aliceToken, http.StatusForbidden, "forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doAuthorizedRequest(server, tt.method, tt.path, tt.body, tt.token)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantCode != "" && decodeProblem(t, rec).Code != tt.wantCode {
+				t.Errorf("problem code != %q, body: %s", tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+
+	// An admin may grant roles; support staff may then read any user
+	admin, adminToken := registerAndLogin(t, server, "admin@example.com")
+	system := service.ContextWithSystem(context.Background())
+	if err := userService.AssignRoles(system, entity.UserID(admin.ID), []entity.Role{entity.RoleAdmin}); err != nil {
+		t.Fatalf("AssignRoles() unexpected error: %v", err)
+	}
+
+	for _, path := range []string{"/api/v1/audit", "/api/v1/audit/verify"} {
+		if rec := doAuthorizedRequest(server, http.MethodGet, path, "", adminToken); rec.Code != http.StatusOK {
+			t.Errorf("GET %s by admin status = %d, want %d, body: %s", path, rec.Code, http.StatusOK, rec.Body.String())
+		}
+	}
+
+	rec := doAuthorizedRequest(server, http.MethodPut, "/api/v1/users/"+alice.ID+"/roles", `{"roles":["support"]}`, adminToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("AssignRoles() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var updated userResponse
+	_ = json.NewDecoder(rec.Body).Decode(&updated)
+	if len(updated.Roles) != 1 || updated.Roles[0] != "support" {
+		t.Errorf("AssignRoles() roles = %v, want [support]", updated.Roles)
+	}
+
+	rec = doAuthorizedRequest(server, http.MethodGet, "/api/v1/users/"+bob.ID, "", aliceToken)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GetUser() by support status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := doAuthorizedRequest(server, http.MethodGet, "/api/v1/audit", "", aliceToken); rec.Code != http.StatusForbidden {
+		t.Errorf("ListEntries() by support status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	rec = doAuthorizedRequest(server, http.MethodPut, "/api/v1/users/"+alice.ID+"/roles", `{"roles":["root"]}`, adminToken)
+	if rec.Code != http.StatusUnprocessableEntity || decodeProblem(t, rec).Code != "invalid_role" {
+		t.Errorf("AssignRoles() with an unknown role status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec = doAuthorizedRequest(server, http.MethodPut, "/api/v1/users/"+alice.ID+"/roles", `{}`, adminToken)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("AssignRoles() without roles status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	// An admin may move users between tenants
+	rec = doAuthorizedRequest(server, http.MethodPut, "/api/v1/users/"+bob.ID+"/tenant", `{"tenant":"acme"}`, adminToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("AssignTenant() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	_ = json.NewDecoder(rec.Body).Decode(&updated)
+	if updated.Tenant != "acme" {
+		t.Errorf("AssignTenant() tenant = %q, want %q", updated.Tenant, "acme")
+	}
+
+	rec = doAuthorizedRequest(server, http.MethodPut, "/api/v1/users/"+bob.ID+"/tenant", `{"tenant":"Acme Corp"}`, adminToken)
+	if rec.Code != http.StatusUnprocessableEntity || decodeProblem(t, rec).Code != "invalid_tenant" {
+		t.Errorf("AssignTenant() with an invalid name status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec = doAuthorizedRequest(server, http.MethodPut, "/api/v1/users/"+bob.ID+"/tenant", `{}`, adminToken)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("AssignTenant() without a tenant status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+	"github.com/darkonikolic/try_golang/internal/domain/authz"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+)
+
+// TokenAuthenticator resolves an access token to the user it was issued to.
+// *auth.AuthService implements it.
+type TokenAuthenticator interface {
+	AuthenticateToken(ctx context.Context, accessToken string) (entity.UserID, error)
+}
+
+// Authenticate returns middleware that identifies the caller from an
+// "Authorization: Bearer <token>" header and puts them into the request
+// context. Requests without the header pass through anonymously; a bad or
+// expired token is rejected with 401.
+func Authenticate(tokens TokenAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scheme, token, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") || token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
+				writeError(w, invalidField("Authorization", "invalid_header", "Authorization must be a Bearer token"))
+				return
+			}
+
+			id, err := tokens.AuthenticateToken(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				writeError(w, err)
+				return
+			}
+
+			ctx := service.ContextWithCaller(r.Context(), id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// SessionCookieName is the cookie that carries a session token
+const SessionCookieName = "session"
+
+// SessionAuthenticator resolves a session token to its session.
+// *auth.SessionService implements it.
+type SessionAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (*auth.Session, error)
+}
+
+// AuthenticateSession returns middleware that identifies the caller from the
+// session cookie. Like Authenticate it puts the caller into the request
+// context, along with the session's ID for auth.SessionIDFromContext.
+// Requests without the cookie pass through anonymously; an unknown or
+// expired session is rejected with 401 and the cookie is cleared. A request
+// with an Authorization header is left to Authenticate and its cookie is
+// ignored: a bearer token is always sent on purpose, whereas browsers send
+// whatever cookie they still hold.
+func AuthenticateSession(sessions SessionAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cookie, err := r.Cookie(SessionCookieName)
+			if err != nil || cookie.Value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			session, err := sessions.Authenticate(r.Context(), cookie.Value)
+			if err != nil {
+				clearSessionCookie(w, r)
+				writeError(w, err)
+				return
+			}
+
+			ctx := service.ContextWithCaller(r.Context(), session.UserID)
+			ctx = auth.ContextWithSessionID(ctx, session.ID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// callerID returns the authenticated caller, failing with
+// authz.ErrAuthenticationRequired for anonymous requests
+func callerID(r *http.Request) (entity.UserID, error) {
+	id, ok := service.CallerFromContext(r.Context())
+	if !ok {
+		return "", authz.ErrAuthenticationRequired
+	}
+	return id, nil
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+)
+
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// kindStatus maps each kind of domain error to its HTTP status code
+var kindStatus = map[domainerr.Kind]int{
+	domainerr.KindInvalid:         http.StatusBadRequest,
+	domainerr.KindValidation:      http.StatusUnprocessableEntity,
+	domainerr.KindNotFound:        http.StatusNotFound,
+	domainerr.KindConflict:        http.StatusConflict,
+	domainerr.KindPrecondition:    http.StatusPreconditionFailed,
+	domainerr.KindUnauthenticated: http.StatusUnauthorized,
+	domainerr.KindForbidden:       http.StatusForbidden,
+	domainerr.KindUnavailable:     http.StatusServiceUnavailable,
+	domainerr.KindTimeout:         http.StatusGatewayTimeout,
+	domainerr.KindInternal:        http.StatusInternalServerError,
+}
+
+// Errors without a domain error in their chain
+var (
+	errTimeout  = domainerr.New(domainerr.KindTimeout, "timeout", "request timed out")
+	errInternal = domainerr.New(domainerr.KindInternal, "internal", "internal server error")
+)
+
+// problemResponse is the RFC 7807 problem details body returned for failed
+// requests. Code is the domain error's stable code; Errors lists the
+// rejected fields, if any.
+type problemResponse struct {
+	Type   string                 `json:"type"`
+	Title  string                 `json:"title"`
+	Status int                    `json:"status"`
+	Detail string                 `json:"detail"`
+	Code   string                 `json:"code"`
+	Errors []domainerr.FieldError `json:"errors,omitempty"`
+}
+
+// writeJSON encodes v as JSON with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if v == nil {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// writeError writes err as problem details. Domain errors are reported
+// with the status of their kind; anything else is logged and hidden behind
+// a generic internal error.
+func writeError(w http.ResponseWriter, err error) {
+	domainErr, ok := domainerr.As(err)
+	switch {
+	case ok && domainErr.Kind != domainerr.KindInternal:
+	case errors.Is(err, context.DeadlineExceeded):
+		domainErr = errTimeout
+	default:
+		log.Printf("Internal error: %v", err)
+		domainErr = errInternal
+	}
+	writeProblem(w, domainErr)
+}
+
+// writeProblem encodes a domain error as application/problem+json
+func writeProblem(w http.ResponseWriter, domainErr *domainerr.Error) {
+	status, ok := kindStatus[domainErr.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(problemResponse{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: domainErr.Message,
+		Code:   domainErr.Code,
+		Errors: domainErr.Fields,
+	})
+	if err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// invalidRequest creates an error for a request that can't be understood
+func invalidRequest(format string, args ...any) *domainerr.Error {
+	return domainerr.New(domainerr.KindInvalid, "invalid_request", fmt.Sprintf(format, args...))
+}
+
+// invalidField creates an error for a missing or malformed body field,
+// query parameter or header
+func invalidField(field, code, message string) *domainerr.Error {
+	return domainerr.New(domainerr.KindInvalid, code, message,
+		domainerr.FieldError{Field: field, Code: code, Message: message})
+}
+
+// missingField creates an error for a required body field that is absent
+func missingField(field string) *domainerr.Error {
+	return invalidField(field, "missing_field", field+" is required")
+}
+
+// invalidTimestamp creates an error for a query parameter that isn't an RFC 3339 timestamp
+func invalidTimestamp(param string) *domainerr.Error {
+	return invalidField(param, "invalid_parameter", param+" must be an RFC 3339 timestamp")
+}
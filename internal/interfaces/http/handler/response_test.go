@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/darkonikolic/try_golang/internal/domain/domainerr"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"invalid", invalidRequest("bad"), http.StatusBadRequest, "invalid_request"},
+		{"validation", domainerr.Validation("invalid_email", "email", "invalid email"), http.StatusUnprocessableEntity, "invalid_email"},
+		{"not found", domainerr.New(domainerr.KindNotFound, "user_not_found", "user not found"), http.StatusNotFound, "user_not_found"},
+		{"conflict", domainerr.New(domainerr.KindConflict, "email_taken", "email taken"), http.StatusConflict, "email_taken"},
+		{"precondition", domainerr.New(domainerr.KindPrecondition, "version_mismatch", "stale"), http.StatusPreconditionFailed, "version_mismatch"},
+		{"unauthenticated", domainerr.New(domainerr.KindUnauthenticated, "authentication_required", "log in"), http.StatusUnauthorized, "authentication_required"},
+		{"forbidden", domainerr.New(domainerr.KindForbidden, "forbidden", "no"), http.StatusForbidden, "forbidden"},
+		{"unavailable", domainerr.New(domainerr.KindUnavailable, "audit_log_unavailable", "no audit log"), http.StatusServiceUnavailable, "audit_log_unavailable"},
+		{"wrapped", fmt.Errorf("failed to get user: %w", domainerr.New(domainerr.KindNotFound, "user_not_found", "user not found")), http.StatusNotFound, "user_not_found"},
+		{"timeout", fmt.Errorf("failed to list users: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "timeout"},
+		{"internal", errors.New("connection refused"), http.StatusInternalServerError, "internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, tt.err)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			problem := decodeProblem(t, rec)
+			if problem.Status != tt.wantStatus || problem.Code != tt.wantCode {
+				t.Errorf("problem = %+v, want status %d and code %q", problem, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
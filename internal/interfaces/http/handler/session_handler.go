@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/auth"
+)
+
+// SessionHandler exposes SessionService over HTTP
+type SessionHandler struct {
+	service *auth.SessionService
+}
+
+// NewSessionHandler creates a new SessionHandler instance
+func NewSessionHandler(service *auth.SessionService) *SessionHandler {
+	return &SessionHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the session endpoints on the given mux
+func (h *SessionHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/sessions", h.Login)
+	mux.HandleFunc("GET /api/v1/sessions", h.ListSessions)
+	mux.HandleFunc("DELETE /api/v1/sessions", h.RevokeAllSessions)
+	mux.HandleFunc("DELETE /api/v1/sessions/{id}", h.RevokeSession)
+}
+
+// sessionResponse is the JSON representation of a session. The token is
+// never part of it.
+type sessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// newSessionResponse converts a session; current marks the session the
+// request was made with
+func (h *SessionHandler) newSessionResponse(session auth.Session, current bool) sessionResponse {
+	return sessionResponse{
+		ID:         session.ID,
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.Expiry(h.service.IdleTimeout()),
+		Current:    current,
+	}
+}
+
+// sessionListResponse is the JSON body returned when listing sessions
+type sessionListResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+// Login handles POST /api/v1/sessions. It takes the same body as
+// POST /api/v1/auth/login and sets the session cookie.
+func (h *SessionHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	device := auth.Device{UserAgent: r.UserAgent(), IP: clientIP(r)}
+	token, session, err := h.service.Login(r.Context(), *req.Email, *req.Password, device)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, h.newSessionResponse(*session, true))
+}
+
+// ListSessions handles GET /api/v1/sessions, listing the caller's active sessions
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := callerID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	sessions, err := h.service.ListSessions(r.Context(), userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	currentID, _ := auth.SessionIDFromContext(r.Context())
+	resp := sessionListResponse{Sessions: make([]sessionResponse, 0, len(sessions))}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, h.newSessionResponse(session, session.ID == currentID))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeSession handles DELETE /api/v1/sessions/{id}, ending one of the
+// caller's sessions
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := callerID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	if err := h.service.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if currentID, ok := auth.SessionIDFromContext(r.Context()); ok && currentID == sessionID {
+		clearSessionCookie(w, r)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAllSessions handles DELETE /api/v1/sessions, ending every session of
+// the caller, including the current one
+func (h *SessionHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := callerID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.service.RevokeAllSessions(r.Context(), userID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if _, ok := auth.SessionIDFromContext(r.Context()); ok {
+		clearSessionCookie(w, r)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearSessionCookie tells the client to drop its session cookie
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// clientIP returns the address of the peer that sent the request.
+// Forwarding headers are not trusted.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newSessionTestServer serves every endpoint, with a user already created
+func newSessionTestServer(t *testing.T) (http.Handler, userResponse) {
+	t.Helper()
+
+	server, _ := newAPITestServer(t)
+	user, _ := registerAndLogin(t, server, "test@example.com")
+	return server, user
+}
+
+// openSession logs in from the given user agent and returns the session cookie
+func openSession(t *testing.T, server http.Handler, userAgent string) *http.Cookie {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions",
+		strings.NewReader(`{"email":"test@example.com","password":"Secret-passw0rd"}`))
+	req.Header.Set("User-Agent", userAgent)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Login() status = %d, want %d, body: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == SessionCookieName && cookie.HttpOnly {
+			return cookie
+		}
+	}
+	t.Fatalf("Login() set no HttpOnly session cookie")
+	return nil
+}
+
+func doSessionRequest(server http.Handler, method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+func listSessions(t *testing.T, server http.Handler, cookie *http.Cookie) []sessionResponse {
+	t.Helper()
+
+	rec := doSessionRequest(server, http.MethodGet, "/api/v1/sessions", cookie)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListSessions() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var list sessionListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("ListSessions() failed to decode response: %v", err)
+	}
+	return list.Sessions
+}
+
+func TestSessionHandler_ListAndRevoke(t *testing.T) {
+	server, _ := newSessionTestServer(t)
+
+	laptop := openSession(t, server, "laptop")
+	phone := openSession(t, server, "phone")
+
+	sessions := listSessions(t, server, laptop)
+	if len(sessions) != 2 || sessions[0].UserAgent != "laptop" || !sessions[0].Current || sessions[1].Current {
+		t.Fatalf("ListSessions() = %+v, want both devices with the laptop current", sessions)
+	}
+	if sessions[0].IP == "" || sessions[0].ExpiresAt.IsZero() {
+		t.Errorf("ListSessions() = %+v, want the IP and expiry recorded", sessions[0])
+	}
+
+	rec := doSessionRequest(server, http.MethodDelete, "/api/v1/sessions/"+sessions[1].ID, laptop)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("RevokeSession() status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	rec = doSessionRequest(server, http.MethodGet, "/api/v1/sessions", phone)
+	if rec.Code != http.StatusUnauthorized || decodeProblem(t, rec).Code != "invalid_session" {
+		t.Errorf("ListSessions() with a revoked session status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = doSessionRequest(server, http.MethodDelete, "/api/v1/sessions/unknown", laptop)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("RevokeSession() of an unknown session status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	openSession(t, server, "tablet")
+	rec = doSessionRequest(server, http.MethodDelete, "/api/v1/sessions", laptop)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("RevokeAllSessions() status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := doSessionRequest(server, http.MethodGet, "/api/v1/sessions", laptop); rec.Code != http.StatusUnauthorized {
+		t.Errorf("ListSessions() after RevokeAllSessions() status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = doSessionRequest(server, http.MethodGet, "/api/v1/sessions", nil)
+	if rec.Code != http.StatusUnauthorized || decodeProblem(t, rec).Code != "authentication_required" {
+		t.Errorf("ListSessions() without a session status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSessionHandler_SuspensionEndsSessions(t *testing.T) {
+	server, user := newSessionTestServer(t)
+	path := "/api/v1/users/" + user.ID
+
+	doAuthorizedRequest(server, http.MethodPost, path+"/activate", `{"reason":"verified"}`, "")
+	cookie := openSession(t, server, "laptop")
+	token := login(t, server, `{"email":"test@example.com","password":"Secret-passw0rd"}`).AccessToken
+
+	rec := doAuthorizedRequest(server, http.MethodPost, path+"/suspend", `{"reason":"abuse"}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SuspendUser() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	if rec := doSessionRequest(server, http.MethodGet, "/api/v1/sessions", cookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("ListSessions() after suspension status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	rec = doAuthorizedRequest(server, http.MethodGet, "/api/v1/sessions", "", token)
+	if rec.Code != http.StatusForbidden || decodeProblem(t, rec).Code != "user_inactive" {
+		t.Errorf("ListSessions() with the token of a suspended user status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestAuthenticateSession_BearerTokenTakesPrecedence(t *testing.T) {
+	server, _ := newSessionTestServer(t)
+	cookie := openSession(t, server, "laptop")
+	doAuthorizedRequest(server, http.MethodPost, "/api/v1/users",
+		`{"email":"other@example.com","name":"Other User","password":"Secret-passw0rd"}`, "")
+	token := login(t, server, `{"email":"other@example.com","password":"Secret-passw0rd"}`).AccessToken
+
+	listWithBoth := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+		req.AddCookie(cookie)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The token's user has no sessions of their own
+	rec := listWithBoth()
+	var list sessionListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("ListSessions() failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(list.Sessions) != 0 {
+		t.Errorf("ListSessions() with a token and a cookie = %d %+v, want the token's user's sessions", rec.Code, list.Sessions)
+	}
+
+	// A stale cookie doesn't spoil a valid token
+	doSessionRequest(server, http.MethodDelete, "/api/v1/sessions", cookie)
+	if rec := listWithBoth(); rec.Code != http.StatusOK {
+		t.Errorf("ListSessions() with a token and a revoked cookie status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
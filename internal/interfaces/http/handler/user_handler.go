@@ -0,0 +1,612 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/repository"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+)
+
+// maxBodyBytes limits the size of accepted request bodies
+const maxBodyBytes = 1 << 20
+
+// UserHandler exposes UserService over HTTP
+type UserHandler struct {
+	service *service.UserService
+}
+
+// NewUserHandler creates a new UserHandler instance
+func NewUserHandler(service *service.UserService) *UserHandler {
+	return &UserHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the user endpoints on the given mux
+func (h *UserHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/v1/users", h.CreateUser)
+	mux.HandleFunc("GET /api/v1/users", h.ListUsers)
+	mux.HandleFunc("GET /api/v1/users/{id}", h.GetUser)
+	mux.HandleFunc("GET /api/v1/users/{id}/history", h.GetUserHistory)
+	mux.HandleFunc("PUT /api/v1/users/{id}", h.UpdateUser)
+	mux.HandleFunc("DELETE /api/v1/users/{id}", h.DeleteUser)
+	mux.HandleFunc("PUT /api/v1/users/{id}/password", h.ChangePassword)
+	mux.HandleFunc("PUT /api/v1/users/{id}/roles", h.AssignRoles)
+	mux.HandleFunc("PUT /api/v1/users/{id}/tenant", h.AssignTenant)
+	mux.HandleFunc("POST /api/v1/users/{id}/activate", h.changeStatus(h.service.ActivateUser))
+	mux.HandleFunc("POST /api/v1/users/{id}/suspend", h.changeStatus(h.service.SuspendUser))
+	mux.HandleFunc("POST /api/v1/users/{id}/reactivate", h.changeStatus(h.service.ReactivateUser))
+	mux.HandleFunc("POST /api/v1/users/{id}/deactivate", h.changeStatus(h.service.DeactivateUser))
+	mux.HandleFunc("POST /api/v1/users/{id}/restore", h.changeStatus(h.service.RestoreUser))
+}
+
+// userRequest is the JSON body accepted by update.
+// Fields are pointers so that a missing field can be told apart from an
+// empty one: the former is a malformed request, the latter a domain error.
+type userRequest struct {
+	Email *string `json:"email"`
+	Name  *string `json:"name"`
+}
+
+// validate checks that all required fields are present
+func (r userRequest) validate() error {
+	if r.Email == nil {
+		return missingField("email")
+	}
+	if r.Name == nil {
+		return missingField("name")
+	}
+	return nil
+}
+
+// createUserRequest is the JSON body accepted by create
+type createUserRequest struct {
+	userRequest
+	Password *string `json:"password"`
+}
+
+// validate checks that all required fields are present
+func (r createUserRequest) validate() error {
+	if err := r.userRequest.validate(); err != nil {
+		return err
+	}
+	if r.Password == nil {
+		return missingField("password")
+	}
+	return nil
+}
+
+// changePasswordRequest is the JSON body accepted by the password endpoint
+type changePasswordRequest struct {
+	CurrentPassword *string `json:"current_password"`
+	NewPassword     *string `json:"new_password"`
+}
+
+// validate checks that all required fields are present
+func (r changePasswordRequest) validate() error {
+	if r.CurrentPassword == nil {
+		return missingField("current_password")
+	}
+	if r.NewPassword == nil {
+		return missingField("new_password")
+	}
+	return nil
+}
+
+// rolesRequest is the JSON body accepted by the roles endpoint
+type rolesRequest struct {
+	Roles *[]entity.Role `json:"roles"`
+}
+
+// validate checks that all required fields are present
+func (r rolesRequest) validate() error {
+	if r.Roles == nil {
+		return missingField("roles")
+	}
+	return nil
+}
+
+// tenantRequest is the JSON body accepted by the tenant endpoint
+type tenantRequest struct {
+	Tenant *entity.Tenant `json:"tenant"`
+}
+
+// validate checks that all required fields are present
+func (r tenantRequest) validate() error {
+	if r.Tenant == nil {
+		return missingField("tenant")
+	}
+	return nil
+}
+
+// statusRequest is the JSON body accepted by the status endpoints. The
+// body may be left out, since the reason is optional.
+type statusRequest struct {
+	Reason string `json:"reason"`
+}
+
+// userResponse is the JSON representation of a user
+type userResponse struct {
+	ID        string     `json:"id"`
+	Email     string     `json:"email"`
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	Roles     []string   `json:"roles"`
+	Tenant    string     `json:"tenant"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int64      `json:"version"`
+}
+
+func newUserResponse(user *entity.User) userResponse {
+	resp := userResponse{
+		ID:        user.ID.String(),
+		Email:     user.Email.String(),
+		Name:      user.Name,
+		Status:    user.Status.String(),
+		Roles:     make([]string, len(user.Roles)),
+		Tenant:    user.Tenant.String(),
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Version:   user.Version,
+	}
+	for i, role := range user.Roles {
+		resp.Roles[i] = role.String()
+	}
+	if !user.DeletedAt.IsZero() {
+		deletedAt := user.DeletedAt
+		resp.DeletedAt = &deletedAt
+	}
+	return resp
+}
+
+// listUsersResponse is the JSON representation of a page of users
+type listUsersResponse struct {
+	Users      []userResponse `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// revisionResponse is the JSON representation of one recorded change to a user
+type revisionResponse struct {
+	Sequence int64               `json:"sequence"`
+	At       time.Time           `json:"at"`
+	Actor    string              `json:"actor"`
+	Action   string              `json:"action"`
+	Changes  []audit.FieldChange `json:"changes"`
+}
+
+func newRevisionResponse(entry audit.Entry) revisionResponse {
+	changes := entry.Changes
+	if changes == nil {
+		changes = []audit.FieldChange{}
+	}
+
+	return revisionResponse{
+		Sequence: entry.Sequence,
+		At:       entry.At,
+		Actor:    entry.Actor,
+		Action:   string(entry.Action),
+		Changes:  changes,
+	}
+}
+
+// userHistoryResponse is the JSON representation of a user's history, oldest first
+type userHistoryResponse struct {
+	Revisions []revisionResponse `json:"revisions"`
+}
+
+// CreateUser handles POST /api/v1/users
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user, err := h.service.CreateUser(r.Context(), *req.Email, *req.Name, *req.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/users/"+user.ID.String())
+	writeUser(w, http.StatusCreated, user)
+}
+
+// GetUser handles GET /api/v1/users/{id}.
+// With an as_of query parameter (RFC 3339) it returns the user as it was at
+// that time, without an ETag since the result can't be updated.
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if raw := r.URL.Query().Get("as_of"); raw != "" {
+		at, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			writeError(w, invalidTimestamp("as_of"))
+			return
+		}
+
+		user, err := h.service.GetUserAsOf(r.Context(), id, at)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newUserResponse(user))
+		return
+	}
+
+	user, err := h.service.GetUserByID(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeUser(w, http.StatusOK, user)
+}
+
+// GetUserHistory handles GET /api/v1/users/{id}/history
+func (h *UserHandler) GetUserHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := h.service.GetUserHistory(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := userHistoryResponse{Revisions: make([]revisionResponse, 0, len(entries))}
+	for _, entry := range entries {
+		resp.Revisions = append(resp.Revisions, newRevisionResponse(entry))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ListUsers handles GET /api/v1/users.
+// Supported query parameters: status (comma separated), email_domain,
+// created_after and created_before (RFC 3339), sort (created_at, updated_at,
+// name), order (asc, desc), limit and cursor.
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	page, err := h.service.ListUsers(r.Context(), query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := listUsersResponse{
+		Users:      make([]userResponse, 0, len(page.Users)),
+		NextCursor: page.NextCursor,
+	}
+	for _, user := range page.Users {
+		resp.Users = append(resp.Users, newUserResponse(user))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseListQuery builds a ListQuery from URL query parameters
+func parseListQuery(values url.Values) (repository.ListQuery, error) {
+	query := repository.ListQuery{
+		SortBy: repository.SortField(values.Get("sort")),
+		Order:  repository.SortOrder(values.Get("order")),
+		Cursor: values.Get("cursor"),
+	}
+
+	if statuses := values.Get("status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			query.Filter.Statuses = append(query.Filter.Statuses, entity.UserStatus(strings.TrimSpace(status)))
+		}
+	}
+
+	query.Filter.EmailDomain = values.Get("email_domain")
+
+	for param, dst := range map[string]*time.Time{
+		"created_after":  &query.Filter.CreatedAfter,
+		"created_before": &query.Filter.CreatedBefore,
+	} {
+		if raw := values.Get(param); raw != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, raw)
+			if err != nil {
+				return query, invalidTimestamp(param)
+			}
+			*dst = parsed
+		}
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return query, invalidField("limit", "invalid_parameter", "limit must be a positive integer")
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+// UpdateUser handles PUT /api/v1/users/{id}.
+// An If-Match header with the user's ETag makes the update conditional:
+// it fails with 412 if the user changed since that ETag was issued.
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req userRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	expectedVersion, err := parseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.service.UpdateUser(r.Context(), id, *req.Email, *req.Name, expectedVersion); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user, err := h.service.GetUserByID(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeUser(w, http.StatusOK, user)
+}
+
+// DeleteUser handles DELETE /api/v1/users/{id}, taking an optional
+// {"reason": "..."} like the status endpoints. The user is soft-deleted and
+// can be restored via POST /api/v1/users/{id}/restore.
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req statusRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.service.DeleteUser(r.Context(), id, req.Reason); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePassword handles PUT /api/v1/users/{id}/password
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req changePasswordRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.service.ChangePassword(r.Context(), id, *req.CurrentPassword, *req.NewPassword); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AssignRoles handles PUT /api/v1/users/{id}/roles, replacing the user's
+// roles and responding with the updated user
+func (h *UserHandler) AssignRoles(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req rolesRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.service.AssignRoles(r.Context(), id, *req.Roles); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user, err := h.service.GetUserByID(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeUser(w, http.StatusOK, user)
+}
+
+// AssignTenant handles PUT /api/v1/users/{id}/tenant, moving the user to
+// another tenant and responding with the updated user
+func (h *UserHandler) AssignTenant(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req tenantRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.service.AssignTenant(r.Context(), id, *req.Tenant); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user, err := h.service.GetUserByID(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeUser(w, http.StatusOK, user)
+}
+
+// changeStatus returns a handler for POST /api/v1/users/{id}/<transition>
+// that applies the given status operation and responds with the updated user
+func (h *UserHandler) changeStatus(
+	transition func(ctx context.Context, id entity.UserID, reason string) error,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathUserID(w, r)
+		if !ok {
+			return
+		}
+
+		var req statusRequest
+		if err := decodeOptionalJSON(w, r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err := transition(r.Context(), id, req.Reason); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		user, err := h.service.GetUserByID(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeUser(w, http.StatusOK, user)
+	}
+}
+
+// writeUser writes user as JSON with its version as the ETag
+func writeUser(w http.ResponseWriter, status int, user *entity.User) {
+	w.Header().Set("ETag", etag(user.Version))
+	writeJSON(w, status, newUserResponse(user))
+}
+
+// etag formats a user version as a strong entity tag
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch returns the version named by an If-Match header.
+// A missing header or "*" means any version.
+func parseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return service.AnyVersion, nil
+	}
+
+	unquoted, ok := strings.CutPrefix(header, `"`)
+	if ok {
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if !ok || err != nil || version <= 0 {
+		return 0, invalidField("If-Match", "invalid_header", "If-Match must be a single ETag returned by this API")
+	}
+	return version, nil
+}
+
+// pathUserID parses the {id} path segment, responding with 400 if it is malformed
+func pathUserID(w http.ResponseWriter, r *http.Request) (entity.UserID, bool) {
+	id, err := entity.ParseUserID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return "", false
+	}
+	return id, true
+}
+
+// decodeJSON decodes a single JSON object from the request body into dst
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	return decodeBody(w, r, dst, false)
+}
+
+// decodeOptionalJSON is decodeJSON for requests whose fields are all
+// optional: an empty body leaves dst untouched
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	return decodeBody(w, r, dst, true)
+}
+
+// decodeBody decodes a single JSON object from the request body into dst,
+// accepting an empty body if allowEmpty is set
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any, allowEmpty bool) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		if errors.Is(err, io.EOF) {
+			if allowEmpty {
+				return nil
+			}
+			return invalidRequest("request body is empty")
+		}
+		return invalidRequest("invalid request body: %v", err)
+	}
+
+	if decoder.More() {
+		return invalidRequest("request body must contain a single JSON object")
+	}
+
+	return nil
+}
@@ -0,0 +1,493 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darkonikolic/try_golang/internal/domain/audit"
+	"github.com/darkonikolic/try_golang/internal/domain/user/entity"
+	"github.com/darkonikolic/try_golang/internal/domain/user/service"
+	"github.com/darkonikolic/try_golang/internal/infrastructure/memory"
+)
+
+func newTestServer() *http.ServeMux {
+	auditLog := audit.NewLog(memory.NewAuditRepository())
+	userService := service.NewUserService(
+		memory.NewUserRepository(),
+		service.WithPasswordHasher(entity.PasswordHasher{Iterations: 1000, SaltLength: 16, KeyLength: 32}),
+		service.WithAuditLog(auditLog),
+	)
+	mux := http.NewServeMux()
+	NewUserHandler(userService).RegisterRoutes(mux)
+	NewAuditHandler(userService).RegisterRoutes(mux)
+	return mux
+}
+
+// unknownUserID is well-formed but never assigned by the tests
+const unknownUserID = "user_01ARZ3NDEKTSV4RRFFQ69G5FAV"
+
+func doRequest(mux *http.ServeMux, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func createTestUser(t *testing.T, mux *http.ServeMux) userResponse {
+	t.Helper()
+
+	rec := doRequest(mux, http.MethodPost, "/api/v1/users", `{"email":"test@example.com","name":"Test User","password":"Secret-passw0rd"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateUser() status = %d, want %d, body: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	var user userResponse
+	if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+		t.Fatalf("CreateUser() failed to decode response: %v", err)
+	}
+	return user
+}
+
+func TestUserHandler_CreateUser(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+
+	if user.ID == "" {
+		t.Errorf("CreateUser() returned empty ID")
+	}
+
+	if user.Email != "test@example.com" {
+		t.Errorf("CreateUser() email mismatch, got: %s", user.Email)
+	}
+}
+
+func TestUserHandler_CreateUserErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{"malformed json", `{"email":`, http.StatusBadRequest, "invalid_request", ""},
+		{"empty body", ``, http.StatusBadRequest, "invalid_request", ""},
+		{"unknown field", `{"email":"a@example.com","name":"A","password":"Secret-passw0rd","role":"admin"}`, http.StatusBadRequest, "invalid_request", ""},
+		{"missing email", `{"name":"Test User","password":"Secret-passw0rd"}`, http.StatusBadRequest, "missing_field", "email"},
+		{"missing name", `{"email":"a@example.com","password":"Secret-passw0rd"}`, http.StatusBadRequest, "missing_field", "name"},
+		{"missing password", `{"email":"a@example.com","name":"Test User"}`, http.StatusBadRequest, "missing_field", "password"},
+		{"invalid email", `{"email":"invalid-email","name":"Test User","password":"Secret-passw0rd"}`, http.StatusUnprocessableEntity, "invalid_email", "email"},
+		{"empty name", `{"email":"a@example.com","name":"","password":"Secret-passw0rd"}`, http.StatusUnprocessableEntity, "empty_name", "name"},
+		{"weak password", `{"email":"a@example.com","name":"Test User","password":"short"}`, http.StatusUnprocessableEntity, "weak_password", "password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newTestServer()
+			rec := doRequest(mux, http.MethodPost, "/api/v1/users", tt.body)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("CreateUser() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			problem := decodeProblem(t, rec)
+			if problem.Status != tt.wantStatus || problem.Code != tt.wantCode {
+				t.Errorf("CreateUser() problem = %+v, want status %d and code %s", problem, tt.wantStatus, tt.wantCode)
+			}
+			if tt.wantField != "" && (len(problem.Errors) != 1 || problem.Errors[0].Field != tt.wantField) {
+				t.Errorf("CreateUser() field errors = %+v, want one for %s", problem.Errors, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestUserHandler_CreateUserReportsEveryViolation(t *testing.T) {
+	mux := newTestServer()
+	rec := doRequest(mux, http.MethodPost, "/api/v1/users", `{"email":"invalid-email","name":"","password":"short"}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("CreateUser() status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	problem := decodeProblem(t, rec)
+	if problem.Code != "validation_failed" || len(problem.Errors) != 3 {
+		t.Fatalf("CreateUser() problem = %+v, want three field errors", problem)
+	}
+	for i, field := range []string{"email", "name", "password"} {
+		if problem.Errors[i].Field != field {
+			t.Errorf("field error %d = %+v, want one for %s", i, problem.Errors[i], field)
+		}
+	}
+}
+
+// decodeProblem decodes an application/problem+json response
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) problemResponse {
+	t.Helper()
+
+	if got := rec.Header().Get("Content-Type"); got != problemContentType {
+		t.Errorf("Content-Type = %q, want %q", got, problemContentType)
+	}
+
+	var problem problemResponse
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatalf("failed to decode problem details: %v", err)
+	}
+	return problem
+}
+
+func TestUserHandler_CreateUserDuplicate(t *testing.T) {
+	mux := newTestServer()
+	createTestUser(t, mux)
+
+	rec := doRequest(mux, http.MethodPost, "/api/v1/users", `{"email":"test@example.com","name":"Other","password":"Secret-passw0rd"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("CreateUser() status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	rec = doRequest(mux, http.MethodPost, "/api/v1/users", `{"email":"Test@EXAMPLE.com","name":"Other","password":"Secret-passw0rd"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("CreateUser() different case status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestUserHandler_GetUser(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+
+	rec := doRequest(mux, http.MethodGet, "/api/v1/users/"+user.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("GetUser() status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec = doRequest(mux, http.MethodGet, "/api/v1/users/"+unknownUserID, "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GetUser() status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = doRequest(mux, http.MethodGet, "/api/v1/users/non-existent-id", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GetUser() malformed ID status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUserHandler_UpdateUser(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+
+	rec := doRequest(mux, http.MethodPut, "/api/v1/users/"+user.ID, `{"email":"updated@example.com","name":"Updated Name"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateUser() status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var updated userResponse
+	if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+		t.Fatalf("UpdateUser() failed to decode response: %v", err)
+	}
+
+	if updated.Name != "Updated Name" {
+		t.Errorf("UpdateUser() name not updated, got: %s", updated.Name)
+	}
+
+	rec = doRequest(mux, http.MethodPut, "/api/v1/users/"+user.ID, `{"email":"updated@example.com","name":""}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("UpdateUser() status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec = doRequest(mux, http.MethodPut, "/api/v1/users/"+unknownUserID, `{"email":"updated@example.com","name":"Updated Name"}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("UpdateUser() status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestUserHandler_UpdateUserIfMatch(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+	path := "/api/v1/users/" + user.ID
+
+	rec := doRequest(mux, http.MethodGet, path, "")
+	etag := rec.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("GetUser() ETag = %q, want %q", etag, `"1"`)
+	}
+
+	put := func(ifMatch string, name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"email":"test@example.com","name":"`+name+`"}`))
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec = put(etag, "First Admin")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateUser() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if got := rec.Header().Get("ETag"); got != `"2"` {
+		t.Errorf("UpdateUser() ETag = %q, want %q", got, `"2"`)
+	}
+
+	// A second admin still holding the old ETag must not overwrite the change
+	rec = put(etag, "Second Admin")
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("UpdateUser() stale If-Match status = %d, want %d", rec.Code, http.StatusPreconditionFailed)
+	}
+
+	rec = put("*", "Any Version")
+	if rec.Code != http.StatusOK {
+		t.Errorf("UpdateUser() If-Match * status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	for _, malformed := range []string{"2", `W/"2"`, `"abc"`, `"2", "3"`} {
+		rec = put(malformed, "Malformed")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("UpdateUser() If-Match %s status = %d, want %d", malformed, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUserHandler_DeleteUser(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+
+	rec := doRequest(mux, http.MethodDelete, "/api/v1/users/"+user.ID, `{"unknown":"field"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("DeleteUser() with a malformed body status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = doRequest(mux, http.MethodDelete, "/api/v1/users/"+user.ID, `{"reason":"duplicate account"}`)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DeleteUser() status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	rec = doRequest(mux, http.MethodDelete, "/api/v1/users/"+user.ID, "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("DeleteUser() status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestUserHandler_RestoreUser(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+	path := "/api/v1/users/" + user.ID
+
+	rec := doRequest(mux, http.MethodPost, path+"/restore", `{}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("RestoreUser() of live user status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	doRequest(mux, http.MethodDelete, path, "")
+
+	rec = doRequest(mux, http.MethodGet, "/api/v1/users?status=deleted", "")
+	var list listUsersResponse
+	_ = json.NewDecoder(rec.Body).Decode(&list)
+	if len(list.Users) != 1 || list.Users[0].DeletedAt == nil {
+		t.Fatalf("ListUsers() status=deleted = %+v, want the deleted user with deleted_at", list.Users)
+	}
+
+	rec = doRequest(mux, http.MethodPost, path+"/restore", `{"reason":"deleted by mistake"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("RestoreUser() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var restored userResponse
+	_ = json.NewDecoder(rec.Body).Decode(&restored)
+	if restored.Status != string(entity.StatusPending) || restored.DeletedAt != nil {
+		t.Errorf("RestoreUser() = %+v, want pending without deleted_at", restored)
+	}
+
+	if rec := doRequest(mux, http.MethodGet, path, ""); rec.Code != http.StatusOK {
+		t.Errorf("GetUser() after restore status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestUserHandler_History(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+	path := "/api/v1/users/" + user.ID
+
+	doRequest(mux, http.MethodPut, path, `{"email":"updated@example.com","name":"Updated Name"}`)
+	doRequest(mux, http.MethodDelete, path, "")
+
+	rec := doRequest(mux, http.MethodGet, path+"/history", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetUserHistory() status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var history userHistoryResponse
+	_ = json.NewDecoder(rec.Body).Decode(&history)
+	if len(history.Revisions) != 3 {
+		t.Fatalf("GetUserHistory() = %+v, want 3 revisions", history)
+	}
+	update := history.Revisions[1]
+	if update.Action != string(audit.ActionUpdate) || update.At.IsZero() || len(update.Changes) != 2 ||
+		update.Changes[0] != (audit.FieldChange{Field: "email", From: "test@example.com", To: "updated@example.com"}) {
+		t.Errorf("GetUserHistory() update revision = %+v", update)
+	}
+
+	// As of the update the user was live and already renamed
+	rec = doRequest(mux, http.MethodGet, path+"?as_of="+update.At.Format(time.RFC3339Nano), "")
+	var asOf userResponse
+	_ = json.NewDecoder(rec.Body).Decode(&asOf)
+	if rec.Code != http.StatusOK || asOf.Name != "Updated Name" || asOf.DeletedAt != nil || rec.Header().Get("ETag") != "" {
+		t.Errorf("GetUser() as_of = %d %+v, ETag %q", rec.Code, asOf, rec.Header().Get("ETag"))
+	}
+
+	if rec := doRequest(mux, http.MethodGet, path+"?as_of=yesterday", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("GetUser() with bad as_of status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := doRequest(mux, http.MethodGet, "/api/v1/users/"+unknownUserID+"/history", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GetUserHistory() of unknown user status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestUserHandler_ChangePassword(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+	path := "/api/v1/users/" + user.ID + "/password"
+
+	rec := doRequest(mux, http.MethodPut, path, `{"current_password":"wrong","new_password":"Another-passw0rd"}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("ChangePassword() wrong current status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if problem := decodeProblem(t, rec); problem.Code != "invalid_credentials" {
+		t.Errorf("ChangePassword() wrong current code = %q, want invalid_credentials", problem.Code)
+	}
+
+	rec = doRequest(mux, http.MethodPut, path, `{"current_password":"Secret-passw0rd","new_password":"weak"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("ChangePassword() weak password status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec = doRequest(mux, http.MethodPut, path, `{"current_password":"Secret-passw0rd","new_password":"Another-passw0rd"}`)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("ChangePassword() status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestUserHandler_ResponseOmitsPassword(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+
+	rec := doRequest(mux, http.MethodGet, "/api/v1/users/"+user.ID, "")
+	if strings.Contains(strings.ToLower(rec.Body.String()), "password") {
+		t.Errorf("GetUser() response leaks password: %s", rec.Body.String())
+	}
+}
+
+func TestUserHandler_StatusTransitions(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+	base := "/api/v1/users/" + user.ID
+
+	if user.Status != "pending" {
+		t.Errorf("CreateUser() status = %s, want pending", user.Status)
+	}
+
+	steps := []struct {
+		action     string
+		wantCode   int
+		wantStatus string
+	}{
+		{"suspend", http.StatusConflict, ""},
+		{"activate", http.StatusOK, "active"},
+		{"suspend", http.StatusOK, "suspended"},
+		{"reactivate", http.StatusOK, "active"},
+		{"deactivate", http.StatusOK, "deactivated"},
+		{"activate", http.StatusConflict, ""},
+	}
+
+	for _, step := range steps {
+		rec := doRequest(mux, http.MethodPost, base+"/"+step.action, `{"reason":"test"}`)
+		if rec.Code != step.wantCode {
+			t.Fatalf("%s status code = %d, want %d, body: %s", step.action, rec.Code, step.wantCode, rec.Body.String())
+		}
+
+		if step.wantStatus == "" {
+			problem := decodeProblem(t, rec)
+			if problem.Code != "invalid_status_transition" || !strings.Contains(problem.Detail, "cannot change status from") {
+				t.Errorf("%s problem = %+v, want the rejected transition", step.action, problem)
+			}
+			continue
+		}
+
+		var got userResponse
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("%s failed to decode response: %v", step.action, err)
+		}
+		if got.Status != step.wantStatus {
+			t.Errorf("%s user status = %s, want %s", step.action, got.Status, step.wantStatus)
+		}
+	}
+}
+
+func TestUserHandler_StatusTransitionWithoutBody(t *testing.T) {
+	mux := newTestServer()
+	user := createTestUser(t, mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/"+user.ID+"/activate", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("activate without a body status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec = doRequest(mux, http.MethodPost, "/api/v1/users/"+user.ID+"/suspend", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("suspend with an empty body status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec = doRequest(mux, http.MethodPost, "/api/v1/users/"+user.ID+"/reactivate", `{"reason":`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("reactivate with a malformed body status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUserHandler_ListUsers(t *testing.T) {
+	mux := newTestServer()
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@other.org"} {
+		body := `{"email":"` + email + `","name":"User","password":"Secret-passw0rd"}`
+		if rec := doRequest(mux, http.MethodPost, "/api/v1/users", body); rec.Code != http.StatusCreated {
+			t.Fatalf("CreateUser() status = %d", rec.Code)
+		}
+	}
+
+	var seen []string
+	path := "/api/v1/users?email_domain=example.com&limit=2&sort=created_at&order=desc"
+	for path != "" {
+		rec := doRequest(mux, http.MethodGet, path, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ListUsers() status = %d, body: %s", rec.Code, rec.Body.String())
+		}
+
+		var page listUsersResponse
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatalf("ListUsers() failed to decode response: %v", err)
+		}
+		for _, user := range page.Users {
+			seen = append(seen, user.Email)
+		}
+
+		path = ""
+		if page.NextCursor != "" {
+			path = "/api/v1/users?email_domain=example.com&limit=2&sort=created_at&order=desc&cursor=" + page.NextCursor
+		}
+	}
+
+	if len(seen) != 3 {
+		t.Errorf("ListUsers() returned %v, want 3 example.com users", seen)
+	}
+}
+
+func TestUserHandler_ListUsersBadQuery(t *testing.T) {
+	mux := newTestServer()
+
+	for _, query := range []string{"limit=abc", "limit=1000", "sort=password", "created_after=yesterday", "cursor=garbage", "status=banned"} {
+		rec := doRequest(mux, http.MethodGet, "/api/v1/users?"+query, "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("ListUsers(%s) status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}